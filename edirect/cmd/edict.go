@@ -31,15 +31,19 @@
 package main
 
 import (
+	"bufio"
 	"eutils"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // network server for EDirect local PubMed archive and search system
@@ -51,9 +55,21 @@ import (
 
 // nquire -edict is a convenience shortcut for -url "localhost:8080"
 
+// run "edict -remote" to fall back on the PubMed citmatch network service
+// when a citation cannot be matched against the local archive
+
 // export NQUIRE_EDICT_SERVER to override nquire -edict address when
 // connecting to a remote server instance
 
+// run "edict -keys keys.txt -rate 10" before exposing the server beyond
+// localhost, requests then need an API key, and each key is rate limited
+
+// without -keys, rate limits apply per client address, forwarding headers are
+// only honored for reverse proxies listed by "edict -proxies 10.0.0.1,10.0.0.2"
+
+// /preload only reads files inside the -datadir directory, which defaults
+// to the Data folder under EDIRECT_PUBMED_MASTER
+
 var edictHelp = `
 PubMed Local Archive Term Queries
 
@@ -77,6 +93,12 @@ PubMed Local Archive Term Queries
 
   nquire -edict search -query "vitamin c ~ ~ common cold"
 
+Ranked Relevance Search
+
+ Results are ordered by BM25 score, with title words weighted over abstract words:
+
+  nquire -edict search -query "catabolite repression" -rank bm25 -top 20
+
 PubMed Record Retrieval
 
   nquire -edict fetch -id 6275390 13970600
@@ -124,7 +146,7 @@ Citation Matching
   gbf2ref | transmute -format compact | grep CITATION |
   grep -e published -e inpress | tr '\n' '\0' |
   xargs -0 -n 50 nquire -edict match -citation |
-  xtract -pattern CITATION -def "-" -element ACCN REF PMID FAUT TITL
+  xtract -pattern CITATION -def "-" -element ACCN REF PMID CONF FAUT TITL
 
  From ASN.1 Sequence Record:
 
@@ -137,6 +159,64 @@ Journal Name Lookup
 
   nquire -edict journal -query "biorxiv"
 
+Versioned JSON Interface
+
+ Search results include total count, parsed query clauses, and elapsed seconds:
+
+  nquire -edict v2/search -query "catabolite repress* [TIAB]" -retstart 0 -retmax 20
+
+  nquire -edict v2/search -query "catabolite repression" -rank bm25 -retmax 10
+
+ Records are returned individually, with missing identifiers listed separately:
+
+  nquire -edict v2/fetch -id 6275390,13970600
+
+  nquire -edict v2/journal -query "pnas"
+
+ Failures use HTTP status codes, with a JSON error object:
+
+  400 malformed query or parameter
+  404 no records found or unknown endpoint
+
+E-utilities Compatibility
+
+ The esearch.fcgi, efetch.fcgi, esummary.fcgi, and epost.fcgi endpoints accept the
+ standard db, term, id, retstart, retmax, rettype, usehistory, WebEnv, and query_key
+ parameters, so EDirect scripts can use the local server by changing the base URL:
+
+  esearch -base "http://localhost:8080/" -db pubmed -query "catabolite repress* [TIAB]" |
+  efetch -base "http://localhost:8080/" -format xml
+
+  epost -base "http://localhost:8080/" -db pubmed -id 6275390,13970600 |
+  esummary -base "http://localhost:8080/"
+
+ The same endpoints are also served under /entrez/eutils/ for other E-utilities clients:
+
+  nquire -get "localhost:8080/entrez/eutils/esearch.fcgi" -db pubmed -term "tn3" -retmode json
+
+ Only the pubmed database is available, efetch returns xml or uilist, esummary returns
+ version 1 or version=2.0 XML, and sort=relevance orders results by BM25 score
+
+Citation Cache Preload
+
+ Files are read from the server -datadir directory, by default the Data folder:
+
+  nquire -edict preload -file citations.xml
+
+Access Control
+
+ Servers started with -keys require an API key, and -rate limits requests per second:
+
+  edict -keys keys.txt -rate 10 -datadir /Volumes/cachet/Data
+
+ Keys are sent as a bearer token, an X-API-Key header, or an api_key parameter:
+
+  nquire -edict search -query "tn3 transposition" -api_key "$EDICT_API_KEY"
+
+ Without keys, limits apply per client address, forwarded addresses are only used from trusted proxies:
+
+  edict -rate 10 -proxies 10.0.0.1,10.0.0.2 -datadir /Volumes/cachet/Data
+
 Documentation
 
   nquire -edict help
@@ -155,6 +235,240 @@ var pmaSetTail = `</PubmedArticleSet>
 
 var streamContentType = "application/octet-stream"
 
+// API KEY AUTHENTICATION AND RATE LIMITING
+
+// rateBucket is a token bucket, refilled continuously at the allowed requests per second
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps one bucket per API key, or per client address when keys are not required
+type rateLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*rateBucket
+	swept   time.Time
+}
+
+// idle buckets have long since refilled, removing them bounds the map by recent callers
+const rateIdle = 10 * time.Minute
+
+// allow takes a token from the caller's bucket, returning seconds to wait if none remain
+func (rl *rateLimiter) allow(caller string, rate float64) (bool, int) {
+
+	if rate <= 0 {
+		return true, 0
+	}
+
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := time.Now()
+
+	// periodically discard buckets that have not been used recently
+	if now.Sub(rl.swept) > time.Minute {
+		for caller, bkt := range rl.buckets {
+			if now.Sub(bkt.last) > rateIdle {
+				delete(rl.buckets, caller)
+			}
+		}
+		rl.swept = now
+	}
+
+	// allow bursts of up to one second of requests, minimum of one
+	burst := math.Max(rate, 1)
+
+	bkt, ok := rl.buckets[caller]
+	if !ok {
+		bkt = &rateBucket{tokens: burst, last: now}
+		rl.buckets[caller] = bkt
+	}
+
+	bkt.tokens = math.Min(burst, bkt.tokens+now.Sub(bkt.last).Seconds()*rate)
+	bkt.last = now
+
+	if bkt.tokens < 1 {
+		wait := int(math.Ceil((1 - bkt.tokens) / rate))
+		return false, wait
+	}
+
+	bkt.tokens--
+
+	return true, 0
+}
+
+// readAPIKeys reads one key per line, optionally followed by a tab and a per-key
+// requests-per-second limit, ignoring blank lines and # comments
+func readAPIKeys(fname string, rate float64) map[string]float64 {
+
+	keys := make(map[string]float64)
+
+	inFile, err := os.Open(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to open API key file '%s'\n", fname)
+		os.Exit(1)
+	}
+
+	defer inFile.Close()
+
+	scanr := bufio.NewScanner(inFile)
+
+	for scanr.Scan() {
+
+		line := strings.TrimSpace(scanr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, lmt := eutils.SplitInTwoLeft(line, "\t")
+		key = strings.TrimSpace(key)
+		lmt = strings.TrimSpace(lmt)
+
+		keys[key] = rate
+		if lmt != "" {
+			val, err := strconv.ParseFloat(lmt, 64)
+			if err != nil || val < 0 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized rate limit '%s' for API key\n", lmt)
+				os.Exit(1)
+			}
+			keys[key] = val
+		}
+	}
+
+	if len(keys) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: No API keys found in '%s'\n", fname)
+		os.Exit(1)
+	}
+
+	return keys
+}
+
+// redactAPIKey hides the value of an api_key query parameter before a request path is logged
+func redactAPIKey(path string) string {
+
+	pth, qry := eutils.SplitInTwoLeft(path, "?")
+	if qry == "" {
+		return path
+	}
+
+	parts := strings.Split(qry, "&")
+	for i, item := range parts {
+		if strings.HasPrefix(item, "api_key=") {
+			parts[i] = "api_key=REDACTED"
+		}
+	}
+
+	return pth + "?" + strings.Join(parts, "&")
+}
+
+// requestLogger follows the gin default log format, but never records API keys
+func requestLogger(param gin.LogFormatterParams) string {
+
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactAPIKey(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// requestAPIKey accepts a bearer token, an X-API-Key header, or an api_key parameter
+func requestAPIKey(c *gin.Context) string {
+
+	auth := c.GetHeader("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	key := c.GetHeader("X-API-Key")
+	if key != "" {
+		return key
+	}
+
+	key = c.Query("api_key")
+	if key != "" {
+		return key
+	}
+
+	return c.PostForm("api_key")
+}
+
+// JSON ENVELOPES FOR VERSIONED INTERFACE
+
+// v2Version identifies the JSON envelope layout, independent of EDirect release
+const v2Version = "2"
+
+// v2MaxRet is the largest number of identifiers returned in one request
+const v2MaxRet = 10000
+
+type v2Problem struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type v2Error struct {
+	Version string    `json:"version"`
+	Error   v2Problem `json:"error"`
+}
+
+type v2Search struct {
+	Version  string    `json:"version"`
+	Query    string    `json:"query"`
+	Clauses  []string  `json:"clauses"`
+	Count    int       `json:"count"`
+	RetStart int       `json:"retstart"`
+	RetMax   int       `json:"retmax"`
+	IDs      []int32   `json:"ids"`
+	Scores   []float64 `json:"scores,omitempty"`
+	Elapsed  float64   `json:"elapsed"`
+}
+
+type v2Record struct {
+	ID  string `json:"id"`
+	XML string `json:"xml"`
+}
+
+type v2Fetch struct {
+	Version string     `json:"version"`
+	Count   int        `json:"count"`
+	Records []v2Record `json:"records"`
+	Missing []string   `json:"missing"`
+	Elapsed float64    `json:"elapsed"`
+}
+
+type v2Journal struct {
+	Version string  `json:"version"`
+	Query   string  `json:"query"`
+	Journal string  `json:"journal"`
+	Elapsed float64 `json:"elapsed"`
+}
+
+// v2Fail sends an HTTP error status with a JSON error object
+func v2Fail(c *gin.Context, code int, msg string) {
+
+	c.JSON(code, v2Error{Version: v2Version, Error: v2Problem{Code: code, Message: msg}})
+}
+
+// v2Elapsed reports seconds since the request started, rounded to milliseconds
+func v2Elapsed(start time.Time) float64 {
+
+	return float64(time.Since(start).Milliseconds()) / 1000
+}
+
 func main() {
 
 	// skip past executable name
@@ -185,6 +499,15 @@ func main() {
 	doStem := false
 	deStop := true
 
+	// citation matching uses the local archive, network service is an optional fallback
+	doRemote := false
+
+	// optional API keys, requests per second for each key or client, and preload directory
+	keyFile := ""
+	rateLimit := 0.0
+	var proxies []string
+	dataDir := ""
+
 	// do these first because -defcpu and -maxcpu can be sent from wrapper before other arguments
 
 	ncpu := runtime.NumCPU()
@@ -249,6 +572,36 @@ func main() {
 				goGc = eutils.GetNumericArg(args, "Garbage collection percentage", 0, 50, 1000)
 				args = args[1:]
 
+			// citation matching flag
+			case "-remote":
+				doRemote = true
+
+			// access control arguments
+			case "-keys":
+				keyFile = eutils.GetStringArg(args, "API key file")
+				args = args[1:]
+			case "-rate":
+				str := eutils.GetStringArg(args, "Requests per second")
+				val, err := strconv.ParseFloat(str, 64)
+				if err != nil || val < 0 {
+					fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -rate value '%s'\n", str)
+					os.Exit(1)
+				}
+				rateLimit = val
+				args = args[1:]
+			case "-proxies":
+				str := eutils.GetStringArg(args, "Trusted proxy addresses")
+				for _, item := range strings.Split(str, ",") {
+					item = strings.TrimSpace(item)
+					if item != "" {
+						proxies = append(proxies, item)
+					}
+				}
+				args = args[1:]
+			case "-datadir":
+				dataDir = eutils.GetStringArg(args, "Preload data directory")
+				args = args[1:]
+
 			default:
 				// set flag to break out of for loop
 				inSwitch = false
@@ -322,8 +675,78 @@ func main() {
 
 	// CREATE GIN ROUTER
 
-	// create gin router with default middleware
-	r := gin.Default()
+	// create gin router with recovery middleware and a logger that omits API keys
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(requestLogger), gin.Recovery())
+
+	// ignore X-Forwarded-For and X-Real-IP unless sent by a trusted reverse proxy
+	err = r.SetTrustedProxies(proxies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -proxies value, %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	// REQUIRE API KEY AND LIMIT REQUEST RATE
+
+	var apiKeys map[string]float64
+	if keyFile != "" {
+		apiKeys = readAPIKeys(keyFile, rateLimit)
+	}
+
+	limiter := &rateLimiter{buckets: make(map[string]*rateBucket)}
+
+	// deny sends plain text, or a JSON error object for the versioned interface
+	deny := func(c *gin.Context, code int, msg string) {
+		if strings.HasPrefix(c.Request.URL.Path, "/v2/") {
+			v2Fail(c, code, msg)
+		} else {
+			c.String(code, msg+"\n")
+		}
+		c.Abort()
+	}
+
+	r.Use(func(c *gin.Context) {
+
+		// help and version are always available
+		path := strings.TrimSuffix(c.Request.URL.Path, "/")
+		if path == "/help" || path == "/version" || path == "/v2/version" {
+			c.Next()
+			return
+		}
+
+		// the connection address cannot be spoofed, forwarded addresses only come from trusted proxies
+		caller := c.RemoteIP()
+		if len(proxies) > 0 {
+			caller = c.ClientIP()
+		}
+		rate := rateLimit
+
+		if apiKeys != nil {
+			key := requestAPIKey(c)
+			if key == "" {
+				c.Header("WWW-Authenticate", "Bearer")
+				deny(c, http.StatusUnauthorized, "API key required")
+				return
+			}
+			lmt, ok := apiKeys[key]
+			if !ok {
+				c.Header("WWW-Authenticate", "Bearer")
+				deny(c, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+			caller = "key:" + key
+			rate = lmt
+		}
+
+		ok, wait := limiter.allow(caller, rate)
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(wait))
+			deny(c, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	})
 
 	// PRINT HELP TEXT
 
@@ -524,13 +947,47 @@ func main() {
 	// PMID LOOKUP FROM PUBMED PHRASE AND INDEXED FIELD SEARCH
 
 	// common search function
-	pubmedSearch := func(c *gin.Context, query string) {
-
-		uids := eutils.ProcessQuery(postingsBase, "pubmed", query, false, false, false, false, deStop)
+	pubmedSearch := func(c *gin.Context, query, rank, top string) {
 
 		// use buffer to speed up uid printing
 		var buffer strings.Builder
 
+		if rank != "" {
+			if rank != "bm25" {
+				c.String(http.StatusBadRequest, "Unrecognized rank method '"+rank+"'\n")
+				return
+			}
+
+			num := 0
+			if top != "" {
+				val, err := strconv.Atoi(top)
+				if err != nil || val < 0 {
+					c.String(http.StatusBadRequest, "Unrecognized top value '"+top+"'\n")
+					return
+				}
+				num = val
+			}
+
+			ranked := eutils.ProcessRanked(postingsBase, "pubmed", query, false, false, false, deStop, num)
+
+			// PMID and relevance score, highest score first
+			for _, res := range ranked {
+				buffer.WriteString(strconv.Itoa(int(res.UID)))
+				buffer.WriteString("\t")
+				buffer.WriteString(strconv.FormatFloat(res.Score, 'f', 3, 64))
+				buffer.WriteString("\n")
+			}
+
+			txt := buffer.String()
+			if txt != "" {
+				c.String(http.StatusOK, txt)
+			}
+
+			return
+		}
+
+		uids := eutils.ProcessQuery(postingsBase, "pubmed", query, false, false, false, false, deStop)
+
 		for _, uid := range uids {
 			val := strconv.Itoa(int(uid))
 			buffer.WriteString(val[:])
@@ -545,14 +1002,15 @@ func main() {
 	}
 
 	// nquire -get "localhost:8080/search" -query "tn3 transposition immunity [TIAB] AND 1988:1993 [YEAR]"
+	// nquire -get "localhost:8080/search" -query "catabolite repression" -rank bm25 -top 20
 	r.GET("/search", func(c *gin.Context) {
 		query := c.Query("query")
-		pubmedSearch(c, query)
+		pubmedSearch(c, query, c.Query("rank"), c.Query("top"))
 	})
 	// nquire -url "localhost:8080/search" -query "(literacy AND numeracy) NOT (adolescent OR child)"
 	r.POST("/search", func(c *gin.Context) {
 		query := c.PostForm("query")
-		pubmedSearch(c, query)
+		pubmedSearch(c, query, c.PostForm("rank"), c.PostForm("top"))
 	})
 
 	// POPULATE JOURNAL TITLE LOOKUP MAP
@@ -573,13 +1031,68 @@ func main() {
 
 	// PMID CACHE PRELOAD
 
+	// preload files must be inside the data directory, after resolving symbolic links
+	if dataDir == "" {
+		dataDir = dataBase
+	}
+	preloadAbs, err := filepath.Abs(dataDir)
+	preloadDir := preloadAbs
+	if err == nil {
+		preloadDir, err = filepath.EvalSymlinks(preloadAbs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to find preload data directory '%s'\n", dataDir)
+		os.Exit(1)
+	}
+
 	preloadCache := func(c *gin.Context, fileName string) {
 
 		if fileName == "" {
+			c.String(http.StatusBadRequest, "Missing file parameter\n")
+			return
+		}
+
+		// relative names are resolved in the data directory
+		fpath := fileName
+		if !filepath.IsAbs(fpath) {
+			fpath = filepath.Join(preloadDir, fpath)
+		}
+
+		insideDir := func(fpath string) bool {
+			for _, dir := range []string{preloadDir, preloadAbs} {
+				rel, err := filepath.Rel(dir, fpath)
+				if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					return true
+				}
+			}
+			return false
+		}
+
+		// check before and after resolving links, so paths outside are not probed
+		fpath = filepath.Clean(fpath)
+		if !insideDir(fpath) {
+			c.String(http.StatusForbidden, "Preload file must be in data directory\n")
+			return
+		}
+
+		fpath, err := filepath.EvalSymlinks(fpath)
+		if err != nil {
+			c.String(http.StatusNotFound, "Preload file not found\n")
+			return
+		}
+
+		if !insideDir(fpath) {
+			c.String(http.StatusForbidden, "Preload file must be in data directory\n")
+			return
+		}
+
+		fi, err := os.Stat(fpath)
+		if err != nil || !fi.Mode().IsRegular() {
+			c.String(http.StatusNotFound, "Preload file not found\n")
 			return
 		}
 
-		eutils.PreloadCitCache(fileName, cache)
+		eutils.PreloadCitCache(fpath, cache)
 
 		c.String(http.StatusOK, "")
 	}
@@ -661,7 +1174,12 @@ func main() {
 		sgr := strings.NewReader(cit)
 		rdr := eutils.CreateXMLStreamer(sgr)
 		xmlq := eutils.CreateXMLProducer("CITATION", "", false, rdr)
-		ctmq := eutils.CreateCitMatchers(xmlq, []string{"strict,remote,verify"}, deStop, doStem, cache, jtaMap)
+		options := "strict,verify"
+		if doRemote {
+			options += ",remote"
+		}
+
+		ctmq := eutils.CreateCitMatchers(xmlq, []string{options}, deStop, doStem, cache, jtaMap)
 		unsq := eutils.CreateXMLUnshuffler(ctmq)
 
 		if sgr == nil || rdr == nil || xmlq == nil || ctmq == nil || unsq == nil {
@@ -737,6 +1255,683 @@ func main() {
 		lookupJournal(c, query)
 	})
 
+	// VERSIONED JSON INTERFACE
+
+	v2 := r.Group("/v2")
+
+	// v2Int reads an optional non-negative integer parameter
+	v2Int := func(c *gin.Context, get func(string) string, name string, def, max int) (int, bool) {
+
+		str := strings.TrimSpace(get(name))
+		if str == "" {
+			return def, true
+		}
+		val, err := strconv.Atoi(str)
+		if err != nil || val < 0 {
+			v2Fail(c, http.StatusBadRequest, "Parameter "+name+" must be a non-negative integer")
+			return 0, false
+		}
+		if max > 0 && val > max {
+			v2Fail(c, http.StatusBadRequest, "Parameter "+name+" must not exceed "+strconv.Itoa(max))
+			return 0, false
+		}
+		return val, true
+	}
+
+	// search returns one page of PMIDs from the full result, in PMID or relevance order
+	searchJSON := func(c *gin.Context, get func(string) string) {
+
+		start := time.Now()
+
+		query := strings.TrimSpace(get("query"))
+		if query == "" {
+			v2Fail(c, http.StatusBadRequest, "Missing query parameter")
+			return
+		}
+
+		rank := get("rank")
+		if rank != "" && rank != "bm25" {
+			v2Fail(c, http.StatusBadRequest, "Unrecognized rank method '"+rank+"'")
+			return
+		}
+
+		retstart, ok := v2Int(c, get, "retstart", 0, 0)
+		if !ok {
+			return
+		}
+		retmax, ok := v2Int(c, get, "retmax", 20, v2MaxRet)
+		if !ok {
+			return
+		}
+		top, ok := v2Int(c, get, "top", 0, 0)
+		if !ok {
+			return
+		}
+
+		res, err := eutils.SearchQuery(postingsBase, "pubmed", query, rank != "", top, deStop)
+		if err != nil {
+			v2Fail(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		out := v2Search{
+			Version:  v2Version,
+			Query:    query,
+			Clauses:  res.Clauses,
+			Count:    len(res.UIDs),
+			RetStart: retstart,
+			RetMax:   retmax,
+			IDs:      []int32{},
+		}
+
+		if rank != "" {
+			// count reflects ranked list after any top limit
+			out.Count = len(res.Ranked)
+			out.Scores = []float64{}
+			for i := retstart; i < len(res.Ranked) && i < retstart+retmax; i++ {
+				out.IDs = append(out.IDs, res.Ranked[i].UID)
+				out.Scores = append(out.Scores, res.Ranked[i].Score)
+			}
+		} else if retstart < len(res.UIDs) {
+			stop := retstart + retmax
+			if stop > len(res.UIDs) {
+				stop = len(res.UIDs)
+			}
+			out.IDs = res.UIDs[retstart:stop]
+		}
+
+		out.Elapsed = v2Elapsed(start)
+
+		c.JSON(http.StatusOK, out)
+	}
+
+	// nquire -get "localhost:8080/v2/search" -query "tn3 transposition immunity" -retmax 100
+	v2.GET("/search", func(c *gin.Context) {
+		searchJSON(c, c.Query)
+	})
+	// nquire -url "localhost:8080/v2/search" -query "tn3 transposition immunity" -retmax 100
+	v2.POST("/search", func(c *gin.Context) {
+		searchJSON(c, c.PostForm)
+	})
+
+	// fetch returns each record separately, so missing PMIDs can be reported
+	fetchJSON := func(c *gin.Context, uids string) {
+
+		start := time.Now()
+
+		var ids []string
+		for _, item := range strings.Split(uids, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			// remove version suffix
+			item, _, _ = strings.Cut(item, ".")
+			if !eutils.IsAllDigits(item) {
+				v2Fail(c, http.StatusBadRequest, "Unrecognized PMID '"+item+"'")
+				return
+			}
+			ids = append(ids, item)
+		}
+
+		if len(ids) < 1 {
+			v2Fail(c, http.StatusBadRequest, "Missing id parameter")
+			return
+		}
+		if len(ids) > v2MaxRet {
+			v2Fail(c, http.StatusBadRequest, "Number of identifiers must not exceed "+strconv.Itoa(v2MaxRet))
+			return
+		}
+
+		uidq := eutils.ReadsUIDsFromString(strings.Join(ids, ","))
+		strq := eutils.CreateFetchers(archiveBase, "pubmed", "", ".xml", true, uidq)
+		unsq := eutils.CreateXMLUnshuffler(strq)
+
+		if uidq == nil || strq == nil || unsq == nil {
+			v2Fail(c, http.StatusInternalServerError, "Unable to create archive reader")
+			return
+		}
+
+		out := v2Fetch{
+			Version: v2Version,
+			Records: []v2Record{},
+			Missing: []string{},
+		}
+
+		// unshuffler restores input order, index is 1-based position in id list
+		for curr := range unsq {
+
+			if curr.Index < 1 || curr.Index > len(ids) {
+				continue
+			}
+			id := ids[curr.Index-1]
+
+			if curr.Text == "" {
+				out.Missing = append(out.Missing, id)
+				continue
+			}
+
+			out.Records = append(out.Records, v2Record{ID: id, XML: curr.Text})
+		}
+
+		out.Count = len(out.Records)
+		out.Elapsed = v2Elapsed(start)
+
+		if out.Count < 1 {
+			v2Fail(c, http.StatusNotFound, "No records found")
+			return
+		}
+
+		// keep angle brackets in XML readable instead of escaping as \u003c
+		c.PureJSON(http.StatusOK, out)
+	}
+
+	// nquire -get "localhost:8080/v2/fetch" -id "2539356,1937004"
+	v2.GET("/fetch", func(c *gin.Context) {
+		fetchJSON(c, c.Query("id"))
+	})
+	// nquire -url "localhost:8080/v2/fetch" -id "2539356,1937004"
+	v2.POST("/fetch", func(c *gin.Context) {
+		fetchJSON(c, c.PostForm("id"))
+	})
+	// nquire -get "localhost:8080/v2/fetch/2539356,1937004"
+	v2.GET("/fetch/:id", func(c *gin.Context) {
+		fetchJSON(c, c.Param("id"))
+	})
+
+	// journal returns the indexed journal abbreviation
+	journalJSON := func(c *gin.Context, query string) {
+
+		start := time.Now()
+
+		key := strings.ToLower(eutils.CleanJournal(query))
+		if key == "" {
+			v2Fail(c, http.StatusBadRequest, "Missing query parameter")
+			return
+		}
+
+		jta, ok := jtaMap[key]
+		if !ok || jta == "" {
+			v2Fail(c, http.StatusNotFound, "Journal not found")
+			return
+		}
+
+		c.JSON(http.StatusOK, v2Journal{Version: v2Version, Query: query, Journal: jta, Elapsed: v2Elapsed(start)})
+	}
+
+	// nquire -get "localhost:8080/v2/journal" -query "journal of immunology"
+	v2.GET("/journal", func(c *gin.Context) {
+		journalJSON(c, c.Query("query"))
+	})
+	// nquire -url "localhost:8080/v2/journal" -query "pnas"
+	v2.POST("/journal", func(c *gin.Context) {
+		journalJSON(c, c.PostForm("query"))
+	})
+
+	// nquire -get "localhost:8080/v2/version"
+	v2.GET("/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"version": v2Version, "edirect": eutils.EDirectVersion})
+	})
+
+	// E-UTILITIES COMPATIBLE INTERFACE
+
+	// esearch, efetch, esummary, and epost accept the E-utilities parameters, so
+	// EDirect scripts and other clients only need to change the base URL
+
+	history := eutils.NewHistoryServer(1000)
+
+	// entrezParams reads a parameter from the POST form, falling back on the URL query
+	entrezParams := func(c *gin.Context) func(string) string {
+		return func(name string) string {
+			if val, ok := c.GetPostForm(name); ok {
+				return strings.TrimSpace(val)
+			}
+			return strings.TrimSpace(c.Query(name))
+		}
+	}
+
+	// entrezFail reports an error in the requested result wrapper
+	entrezFail := func(c *gin.Context, code int, root, msg string, json bool) {
+		if json {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+		c.Data(code, "text/xml; charset=UTF-8", []byte(eutils.EntrezErrorXML(root, msg)))
+	}
+
+	// entrezInt reads an optional non-negative integer parameter
+	entrezInt := func(get func(string) string, name string, def int) (int, bool) {
+
+		str := get(name)
+		if str == "" {
+			return def, true
+		}
+		val, err := strconv.Atoi(str)
+		if err != nil || val < 0 {
+			return 0, false
+		}
+		return val, true
+	}
+
+	// entrezDB only allows the local PubMed archive, which is also the E-utilities default
+	entrezDB := func(get func(string) string) (string, bool) {
+
+		db := strings.ToLower(get("db"))
+		if db == "" || db == "pubmed" {
+			return "pubmed", true
+		}
+		return "Database '" + db + "' is not available on this server", false
+	}
+
+	// entrezUIDs reads identifiers from the id parameter, or from a saved WebEnv
+	// and query_key, paged by retstart and retmax
+	entrezUIDs := func(get func(string) string) ([]string, string) {
+
+		var ids []string
+
+		retstart, ok := entrezInt(get, "retstart", 0)
+		if !ok {
+			return nil, "Invalid retstart"
+		}
+		retmax, ok := entrezInt(get, "retmax", 20)
+		if !ok {
+			return nil, "Invalid retmax"
+		}
+		if retmax > v2MaxRet {
+			retmax = v2MaxRet
+		}
+
+		if get("id") != "" {
+			for _, item := range strings.FieldsFunc(get("id"), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+			}) {
+				// remove version suffix
+				item, _, _ = strings.Cut(item, ".")
+				if !eutils.IsAllDigits(item) {
+					return nil, "Invalid uid " + item
+				}
+				ids = append(ids, item)
+			}
+			if len(ids) > v2MaxRet {
+				return nil, "Number of identifiers must not exceed " + strconv.Itoa(v2MaxRet)
+			}
+			// explicit paging parameters also apply to an id list
+			if get("retstart") != "" || get("retmax") != "" {
+				if retstart >= len(ids) {
+					return nil, ""
+				}
+				if retstart+retmax < len(ids) {
+					ids = ids[retstart : retstart+retmax]
+				} else {
+					ids = ids[retstart:]
+				}
+			}
+			return ids, ""
+		}
+
+		webenv := get("WebEnv")
+		if webenv == "" {
+			return nil, "Empty id list - nothing todo"
+		}
+		key, ok := entrezInt(get, "query_key", 1)
+		if !ok {
+			return nil, "Invalid query_key"
+		}
+		uids, ok := history.Lookup(webenv, key)
+		if !ok {
+			return nil, "Unable to obtain query #" + strconv.Itoa(key)
+		}
+
+		for i := retstart; i < len(uids) && i < retstart+retmax; i++ {
+			ids = append(ids, strconv.Itoa(int(uids[i])))
+		}
+
+		return ids, ""
+	}
+
+	// entrezRecords fetches archived records in identifier order, empty text marks a missing record
+	entrezRecords := func(ids []string, proc func(id, text string)) bool {
+
+		if len(ids) < 1 {
+			return true
+		}
+
+		uidq := eutils.ReadsUIDsFromString(strings.Join(ids, ","))
+		strq := eutils.CreateFetchers(archiveBase, "pubmed", "", ".xml", true, uidq)
+		unsq := eutils.CreateXMLUnshuffler(strq)
+
+		if uidq == nil || strq == nil || unsq == nil {
+			return false
+		}
+
+		for curr := range unsq {
+			if curr.Index < 1 || curr.Index > len(ids) {
+				continue
+			}
+			proc(ids[curr.Index-1], curr.Text)
+		}
+
+		return true
+	}
+
+	// esearch evaluates the term against local postings, newest PMIDs first unless
+	// sort=relevance requests BM25 ordering
+	entrezSearch := func(c *gin.Context) {
+
+		get := entrezParams(c)
+		json := get("retmode") == "json"
+
+		msg, ok := entrezDB(get)
+		if !ok {
+			entrezFail(c, http.StatusBadRequest, "eSearchResult", msg, json)
+			return
+		}
+
+		// without a term, a WebEnv and query_key page through a saved result
+		term := get("term")
+		if term == "" && get("WebEnv") != "" && get("query_key") != "" {
+			key, ok := entrezInt(get, "query_key", 0)
+			if !ok || key < 1 {
+				entrezFail(c, http.StatusBadRequest, "eSearchResult", "Invalid query_key", json)
+				return
+			}
+			term = "#" + strconv.Itoa(key)
+		}
+		if term == "" {
+			entrezFail(c, http.StatusBadRequest, "eSearchResult", "Empty term and query_key - nothing todo", json)
+			return
+		}
+
+		retstart, ok := entrezInt(get, "retstart", 0)
+		if !ok {
+			entrezFail(c, http.StatusBadRequest, "eSearchResult", "Invalid retstart", json)
+			return
+		}
+		retmax, ok := entrezInt(get, "retmax", 20)
+		if !ok {
+			entrezFail(c, http.StatusBadRequest, "eSearchResult", "Invalid retmax", json)
+			return
+		}
+		if retmax > v2MaxRet {
+			retmax = v2MaxRet
+		}
+
+		var uids []int32
+		translation := ""
+
+		// a term of "#1" refers to an earlier result in the same WebEnv, as sent by epost
+		ref := strings.Trim(term, "() ")
+		if strings.HasPrefix(ref, "#") && len(ref) > 1 && eutils.IsAllDigits(ref[1:]) {
+
+			key, _ := strconv.Atoi(ref[1:])
+			saved, ok := history.Lookup(get("WebEnv"), key)
+			if !ok {
+				entrezFail(c, http.StatusBadRequest, "eSearchResult", "Unable to obtain query "+ref, json)
+				return
+			}
+			uids = saved
+			translation = ref
+
+		} else {
+
+			rank := get("sort") == "relevance"
+
+			res, err := eutils.SearchQuery(postingsBase, "pubmed", term, rank, 0, deStop)
+			if err != nil {
+				entrezFail(c, http.StatusBadRequest, "eSearchResult", err.Error(), json)
+				return
+			}
+
+			if rank {
+				for _, item := range res.Ranked {
+					uids = append(uids, item.UID)
+				}
+			} else {
+				// reverse PMID order approximates the PubMed most recent default
+				for i := len(res.UIDs) - 1; i >= 0; i-- {
+					uids = append(uids, res.UIDs[i])
+				}
+			}
+			translation = strings.Join(res.Clauses, " ")
+		}
+
+		count := len(uids)
+
+		if get("rettype") == "count" {
+			if json {
+				c.JSON(http.StatusOK, gin.H{
+					"header":        gin.H{"type": "esearch", "version": "0.3"},
+					"esearchresult": gin.H{"count": strconv.Itoa(count)},
+				})
+				return
+			}
+			c.Data(http.StatusOK, "text/xml; charset=UTF-8", []byte(
+				"<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n<eSearchResult>\n\t<Count>"+strconv.Itoa(count)+"</Count>\n</eSearchResult>\n"))
+			return
+		}
+
+		webenv := ""
+		key := 0
+		if strings.ToLower(get("usehistory")) == "y" {
+			webenv, key = history.Store(get("WebEnv"), uids)
+		}
+
+		var page []int32
+		if retstart < count {
+			stop := retstart + retmax
+			if stop > count {
+				stop = count
+			}
+			page = uids[retstart:stop]
+		}
+
+		if json {
+			idlist := []string{}
+			for _, uid := range page {
+				idlist = append(idlist, strconv.Itoa(int(uid)))
+			}
+			result := gin.H{
+				"count":            strconv.Itoa(count),
+				"retmax":           strconv.Itoa(len(page)),
+				"retstart":         strconv.Itoa(retstart),
+				"idlist":           idlist,
+				"translationset":   []string{},
+				"querytranslation": translation,
+			}
+			if webenv != "" {
+				result["querykey"] = strconv.Itoa(key)
+				result["webenv"] = webenv
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"header":        gin.H{"type": "esearch", "version": "0.3"},
+				"esearchresult": result,
+			})
+			return
+		}
+
+		c.Data(http.StatusOK, "text/xml; charset=UTF-8",
+			[]byte(eutils.ESearchResultXML(count, len(page), retstart, key, webenv, page, translation)))
+	}
+
+	// efetch returns PubmedArticleSet XML, or a uilist of identifiers
+	entrezFetch := func(c *gin.Context) {
+
+		get := entrezParams(c)
+
+		msg, ok := entrezDB(get)
+		if !ok {
+			entrezFail(c, http.StatusBadRequest, "eFetchResult", msg, false)
+			return
+		}
+
+		ids, msg := entrezUIDs(get)
+		if msg != "" {
+			entrezFail(c, http.StatusBadRequest, "eFetchResult", msg, false)
+			return
+		}
+
+		rettype := strings.ToLower(get("rettype"))
+		retmode := strings.ToLower(get("retmode"))
+
+		switch rettype {
+		case "uilist":
+			if retmode == "xml" {
+				c.Data(http.StatusOK, "text/xml; charset=UTF-8", []byte(eutils.UIDListXML(ids)))
+				return
+			}
+			txt := ""
+			if len(ids) > 0 {
+				txt = strings.Join(ids, "\n") + "\n"
+			}
+			c.Data(http.StatusOK, "text/plain; charset=UTF-8", []byte(txt))
+			return
+		case "", "xml", "full":
+			if retmode != "" && retmode != "xml" {
+				entrezFail(c, http.StatusBadRequest, "eFetchResult", "Unsupported retmode '"+retmode+"'", false)
+				return
+			}
+		default:
+			entrezFail(c, http.StatusBadRequest, "eFetchResult", "Unsupported rettype '"+rettype+"'", false)
+			return
+		}
+
+		// stream records between set wrappers instead of collecting them in memory
+		c.Header("Content-Type", "text/xml; charset=UTF-8")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString(pmaSetHead)
+
+		ok = entrezRecords(ids, func(id, text string) {
+			if text == "" {
+				return
+			}
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			c.Writer.WriteString(text)
+		})
+		if !ok {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create archive reader\n")
+		}
+
+		c.Writer.WriteString(pmaSetTail)
+	}
+
+	// esummary converts archived records to version 1 DocSum or version 2.0 DocumentSummary
+	entrezSummary := func(c *gin.Context) {
+
+		get := entrezParams(c)
+
+		msg, ok := entrezDB(get)
+		if !ok {
+			entrezFail(c, http.StatusBadRequest, "eSummaryResult", msg, false)
+			return
+		}
+
+		if get("retmode") == "json" {
+			entrezFail(c, http.StatusBadRequest, "eSummaryResult", "Unsupported retmode 'json'", true)
+			return
+		}
+
+		ids, msg := entrezUIDs(get)
+		if msg != "" {
+			entrezFail(c, http.StatusBadRequest, "eSummaryResult", msg, false)
+			return
+		}
+
+		version2 := get("version") == "2.0"
+
+		var buffer strings.Builder
+
+		buffer.WriteString(eutils.ESummaryHead(version2))
+
+		ok = entrezRecords(ids, func(id, text string) {
+			sum := ""
+			if text != "" {
+				sum = eutils.PubmedDocSum(text, version2)
+			}
+			if sum != "" {
+				buffer.WriteString(sum)
+			} else if version2 {
+				buffer.WriteString("<DocumentSummary uid=\"" + id + "\">\n\t<error>cannot get document summary</error>\n</DocumentSummary>\n")
+			} else {
+				buffer.WriteString("<ERROR>UID=" + id + ": cannot get document summary</ERROR>\n")
+			}
+		})
+		if !ok {
+			entrezFail(c, http.StatusInternalServerError, "eSummaryResult", "Unable to create archive reader", false)
+			return
+		}
+
+		buffer.WriteString(eutils.ESummaryTail(version2))
+
+		c.Data(http.StatusOK, "text/xml; charset=UTF-8", []byte(buffer.String()))
+	}
+
+	// epost saves identifiers on the history server for later efetch or esummary
+	entrezPost := func(c *gin.Context) {
+
+		get := entrezParams(c)
+
+		msg, ok := entrezDB(get)
+		if !ok {
+			entrezFail(c, http.StatusBadRequest, "ePostResult", msg, false)
+			return
+		}
+
+		if get("id") == "" {
+			entrezFail(c, http.StatusBadRequest, "ePostResult", "Empty id list - nothing todo", false)
+			return
+		}
+
+		ids, msg := entrezUIDs(get)
+		if msg != "" {
+			entrezFail(c, http.StatusBadRequest, "ePostResult", msg, false)
+			return
+		}
+
+		var uids []int32
+		for _, id := range ids {
+			val, err := strconv.Atoi(id)
+			if err == nil {
+				uids = append(uids, int32(val))
+			}
+		}
+
+		webenv, key := history.Store(get("WebEnv"), uids)
+
+		c.Data(http.StatusOK, "text/xml; charset=UTF-8", []byte(eutils.EPostResultXML(key, webenv)))
+	}
+
+	// register at the server root, for -base "http://localhost:8080/", and under
+	// the NCBI path, for clients that append /entrez/eutils/ to a host name
+	for _, pfx := range []string{"", "/entrez/eutils"} {
+
+		// esearch -base "http://localhost:8080/" -db pubmed -query "tn3 transposition immunity"
+		r.GET(pfx+"/esearch.fcgi", entrezSearch)
+		r.POST(pfx+"/esearch.fcgi", entrezSearch)
+
+		// efetch -base "http://localhost:8080/" -db pubmed -id 2539356 -format xml
+		r.GET(pfx+"/efetch.fcgi", entrezFetch)
+		r.POST(pfx+"/efetch.fcgi", entrezFetch)
+
+		// efetch -base "http://localhost:8080/" -db pubmed -id 2539356 -format docsum
+		r.GET(pfx+"/esummary.fcgi", entrezSummary)
+		r.POST(pfx+"/esummary.fcgi", entrezSummary)
+
+		// epost -base "http://localhost:8080/" -db pubmed -id 2539356,1937004
+		r.GET(pfx+"/epost.fcgi", entrezPost)
+		r.POST(pfx+"/epost.fcgi", entrezPost)
+	}
+
+	// unknown versioned endpoints get a JSON error, others keep the plain text response
+	r.NoRoute(func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/v2/") {
+			v2Fail(c, http.StatusNotFound, "Unknown endpoint "+c.Request.URL.Path)
+			return
+		}
+		c.String(http.StatusNotFound, "404 page not found")
+	})
+
 	// START LISTENING ON PORT
 
 	// listen for requests
@@ -184,6 +184,10 @@ func main() {
 	mock := false
 	btch := false
 
+	// relevance ranking of query results, optionally limited to top N
+	rank := false
+	topN := 0
+
 	// print term list with counts
 	trms := ""
 	plrl := false
@@ -208,6 +212,19 @@ func main() {
 	// print UIDs and hash values
 	hshv := false
 
+	// keep prior versions of records, with manifest naming source update file
+	hist := false
+	srce := ""
+
+	// retrieve versions current at given date, or print version manifests
+	asof := ""
+	mnfs := false
+
+	// check archive and postings integrity, optionally quarantining or rebuilding damaged files
+	fsck := ""
+	qrnt := ""
+	rbld := ""
+
 	// convert UIDs to archive trie
 	trei := false
 
@@ -426,9 +443,21 @@ func main() {
 			if xact && rlxd {
 				rlxd = false
 			}
+			// allow -search -ranked "query"
+			if len(args) > 1 && args[1] == "-ranked" {
+				rank = true
+				args = args[1:]
+			}
 			phrs = eutils.GetStringArg(args, "Query argument")
 			args = args[1:]
 
+		// order query results by BM25 score
+		case "-ranked":
+			rank = true
+		case "-top":
+			topN = eutils.GetNumericArg(args, "Number of ranked results", 0, 1, 0)
+			args = args[1:]
+
 		case "-link":
 			lnks = eutils.GetStringArg(args, "Links field")
 			isLink = true
@@ -491,6 +520,30 @@ func main() {
 			invt = true
 		case "-hash":
 			hshv = true
+		case "-history":
+			hist = true
+		case "-source":
+			srce = eutils.GetStringArg(args, "Source update file name")
+			args = args[1:]
+		case "-asof":
+			str := eutils.GetStringArg(args, "As-of date")
+			asof = eutils.NormalizeAsOf(str)
+			if asof == "" {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -asof date '%s', use YYYY-MM-DD\n", str)
+				os.Exit(1)
+			}
+			args = args[1:]
+		case "-manifest":
+			mnfs = true
+		case "-fsck":
+			fsck = eutils.GetStringArg(args, "Integrity check path")
+			args = args[1:]
+		case "-quarantine":
+			qrnt = eutils.GetStringArg(args, "Quarantine directory")
+			args = args[1:]
+		case "-rebuild":
+			rbld = eutils.GetStringArg(args, "Merged directory")
+			args = args[1:]
 		case "-trie":
 			trei = true
 			if len(args) > 1 {
@@ -1064,6 +1117,16 @@ func main() {
 				}
 			}
 
+			return
+		case "-taxon-lca", "-taxon-subtree", "-taxon-lineage", "-taxon-name":
+			recordCount = eutils.TaxonQuery(args, in)
+
+			debug.FreeOSMemory()
+
+			if timr {
+				printDuration("queries")
+			}
+
 			return
 		default:
 		}
@@ -1231,7 +1294,19 @@ func main() {
 
 	if dlet != "" {
 
-		dltq := eutils.CreateDeleter(dlet, in)
+		// file names must match those written by the stasher
+		pfx := ""
+		sfx := ".xml"
+
+		if db == "pmc" {
+			pfx = "PMC"
+		}
+
+		if pma2pme {
+			sfx = ".asn"
+		}
+
+		dltq := eutils.CreateDeleter(dlet, pfx, sfx, srce, zipp, hist, in)
 		clrq := eutils.CreateClearer(idcs, incr, dltq)
 
 		if dltq == nil || clrq == nil {
@@ -1569,6 +1644,66 @@ func main() {
 		return
 	}
 
+	// CHECK INTEGRITY OF ARCHIVE, INDICES, OR POSTINGS FILES
+
+	if fsck != "" {
+
+		chkq := eutils.CreateIntegrityCheckers(fsck)
+
+		if chkq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create integrity checker\n")
+			os.Exit(1)
+		}
+
+		var damaged []eutils.FsckProblem
+
+		// drain channel, print path, identifier or term prefix, and problem
+		for prob := range chkq {
+
+			fmt.Fprintf(os.Stdout, "%s\t%s\t%s\n", prob.Path, prob.Ident, prob.Reason)
+
+			damaged = append(damaged, prob)
+
+			recordCount++
+			runtime.Gosched()
+		}
+
+		// rebuild postings from merged inverted index files, replacing damaged files in place
+		if rbld != "" && len(damaged) > 0 {
+
+			rbdq := eutils.RebuildPostings(fsck, rbld, damaged)
+
+			for str := range rbdq {
+				fmt.Fprintf(os.Stderr, "Rebuilt %s\n", str)
+			}
+
+			// report anything still damaged, archive records cannot be regenerated here
+			var remaining []eutils.FsckProblem
+			for prob := range eutils.CreateIntegrityCheckers(fsck) {
+				remaining = append(remaining, prob)
+			}
+			damaged = remaining
+		}
+
+		// move remaining damaged files out of the way
+		if qrnt != "" {
+			for _, prob := range damaged {
+				eutils.QuarantineFile(fsck, qrnt, prob)
+			}
+		}
+
+		if len(damaged) > 0 {
+			if qrnt != "" {
+				fmt.Fprintf(os.Stderr, "\n%d damaged file(s) moved to %s\n\n", len(damaged), qrnt)
+			} else {
+				fmt.Fprintf(os.Stderr, "\n%d damaged file(s) found\n\n", len(damaged))
+			}
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	// PROMOTE MERGED INVERTED INDEX TO TERM LIST AND POSTINGS FILES
 
 	if prom != "" && fild != "" {
@@ -1659,6 +1794,22 @@ func main() {
 		// deStop should match value used in building the indices
 		if mock {
 			recordCount = eutils.ProcessMock(base, db, phrs, xact, titl, rlxd, deStop)
+		} else if rank {
+			ranked := eutils.ProcessRanked(base, db, phrs, xact, titl, rlxd, deStop, topN)
+
+			// use buffer to speed up uid printing
+			var buffer strings.Builder
+
+			for _, res := range ranked {
+				buffer.WriteString(strconv.Itoa(int(res.UID)))
+				buffer.WriteString("\t")
+				buffer.WriteString(strconv.FormatFloat(res.Score, 'f', 3, 64))
+				buffer.WriteString("\n")
+			}
+
+			os.Stdout.WriteString(buffer.String())
+
+			recordCount = len(ranked)
 		} else {
 			recordCount = eutils.ProcessSearch(base, db, phrs, xact, titl, rlxd, false, deStop)
 		}
@@ -2046,7 +2197,40 @@ func main() {
 		}
 
 		uidq := eutils.CreateUIDReader(in)
-		strq := eutils.CreateFetchers(ftch, db, pfx, sfx, zipp, uidq)
+
+		if mnfs {
+
+			if uidq == nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to create archive reader\n")
+				os.Exit(1)
+			}
+
+			// print version, date, source, and action for each record
+			for ext := range uidq {
+
+				for _, ent := range eutils.RecordHistory(ftch, ext.Text, pfx, sfx) {
+					fmt.Fprintf(os.Stdout, "%s\t%d\t%s\t%s\t%s\n", ext.Text, ent.Version, ent.Date, ent.Source, ent.Action)
+				}
+
+				recordCount++
+				runtime.Gosched()
+			}
+
+			debug.FreeOSMemory()
+
+			if timr {
+				printDuration("records")
+			}
+
+			return
+		}
+
+		var strq <-chan eutils.XMLRecord
+		if asof != "" {
+			strq = eutils.CreateAsOfFetchers(ftch, db, pfx, sfx, asof, zipp, uidq)
+		} else {
+			strq = eutils.CreateFetchers(ftch, db, pfx, sfx, zipp, uidq)
+		}
 		unsq := eutils.CreateXMLUnshuffler(strq)
 
 		if uidq == nil || strq == nil || unsq == nil {
@@ -2903,7 +3087,12 @@ func main() {
 		}
 
 		xmlq := eutils.CreateXMLProducer(topPattern, star, false, rdr)
-		stsq := eutils.CreateStashers(stsh, parent, indx, pfx, sfx, db, xmlString, hshv, zipp, asn, report, xmlq)
+		// manifest records update file that introduced each version
+		if srce == "" && fileName != "" {
+			srce = filepath.Base(fileName)
+		}
+
+		stsq := eutils.CreateStashers(stsh, parent, indx, pfx, sfx, db, xmlString, srce, hshv, zipp, asn, hist, report, xmlq)
 		clrq := eutils.CreateClearer(idcs, incr, stsq)
 
 		if xmlq == nil || stsq == nil || clrq == nil {
@@ -1282,6 +1282,9 @@ func sequenceSearch(inp io.Reader, args []string) {
 	protein := false
 	circular := false
 	topStrand := false
+	approx := false
+	indels := false
+	maxDiffs := 0
 
 	for len(args) > 0 {
 		if args[0] == "-protein" {
@@ -1293,6 +1296,12 @@ func sequenceSearch(inp io.Reader, args []string) {
 		} else if args[0] == "-top" {
 			topStrand = true
 			args = args[1:]
+		} else if args[0] == "-mismatches" || args[0] == "-edits" {
+			// -edits also allows insertions and deletions
+			approx = true
+			indels = (args[0] == "-edits")
+			maxDiffs = eutils.GetNumericArg(args, args[0], 0, 0, 1000)
+			args = args[2:]
 		} else {
 			break
 		}
@@ -1319,16 +1328,28 @@ func sequenceSearch(inp io.Reader, args []string) {
 
 	str := readOneFastaSequence(inp)
 
-	srch := eutils.SequenceSearcher(arry, protein, circular, topStrand)
-
 	txt := ""
 
-	srch.Search(str[:],
-		func(str, pat string, pos int) bool {
-			txt = fmt.Sprintf("%d\t%s\n", pos, pat)
-			os.Stdout.WriteString(txt)
-			return true
-		})
+	if approx {
+		apx := eutils.ApproximateSearcher(arry, protein, circular, topStrand, maxDiffs, indels)
+
+		// reports start, pattern, stop, number of differences, and extended CIGAR alignment
+		apx.Search(str[:],
+			func(hit eutils.SequenceHit) bool {
+				txt = fmt.Sprintf("%d\t%s\t%d\t%d\t%s\n", hit.Start, hit.Pattern, hit.Stop, hit.Mismatches, hit.Cigar)
+				os.Stdout.WriteString(txt)
+				return true
+			})
+	} else {
+		srch := eutils.SequenceSearcher(arry, protein, circular, topStrand)
+
+		srch.Search(str[:],
+			func(str, pat string, pos int) bool {
+				txt = fmt.Sprintf("%d\t%s\n", pos, pat)
+				os.Stdout.WriteString(txt)
+				return true
+			})
+	}
 
 	if !strings.HasSuffix(txt, "\n") {
 		os.Stdout.WriteString("\n")
@@ -1490,6 +1511,359 @@ func baseCount(inp io.Reader) {
 	}
 }
 
+// FASTQ CONVERSION AND QUALITY FILTERING
+
+// getPhredOffset reads the -offset argument, which must be 33 or 64
+func getPhredOffset(args []string) int {
+
+	offset := eutils.GetNumericArg(args, "Phred quality offset", 33, 0, 0)
+	if offset != 33 && offset != 64 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Phred quality offset must be 33 or 64\n")
+		os.Exit(1)
+	}
+
+	return offset
+}
+
+// writeFastqAsFasta prints a read in FASTA format with 70 residues per line
+func writeFastqAsFasta(fsq eutils.FASTQRecord) {
+
+	var buffer strings.Builder
+
+	buffer.WriteString(">")
+	buffer.WriteString(fsq.SeqID)
+	if fsq.Title != "" {
+		buffer.WriteString(" ")
+		buffer.WriteString(fsq.Title)
+	}
+	buffer.WriteString("\n")
+
+	str := fsq.Sequence
+	for str != "" {
+		mx := len(str)
+		if mx > 70 {
+			mx = 70
+		}
+		buffer.WriteString(str[:mx])
+		buffer.WriteString("\n")
+		str = str[mx:]
+	}
+
+	os.Stdout.WriteString(buffer.String())
+}
+
+// writeFastq prints a read as a four-line FASTQ record
+func writeFastq(fsq eutils.FASTQRecord) {
+
+	var buffer strings.Builder
+
+	buffer.WriteString("@")
+	buffer.WriteString(fsq.SeqID)
+	if fsq.Title != "" {
+		buffer.WriteString(" ")
+		buffer.WriteString(fsq.Title)
+	}
+	buffer.WriteString("\n")
+	buffer.WriteString(fsq.Sequence)
+	buffer.WriteString("\n+\n")
+	buffer.WriteString(fsq.Quality)
+	buffer.WriteString("\n")
+
+	os.Stdout.WriteString(buffer.String())
+}
+
+// fastqToFasta drops quality strings, optionally removing low-quality reads
+func fastqToFasta(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	minMean := 0
+	offset := 33
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-min-mean":
+			minMean = eutils.GetNumericArg(args, "Minimum mean quality", 0, 0, 0)
+			args = args[2:]
+		case "-offset":
+			offset = getPhredOffset(args)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -fq2fa command\n")
+			os.Exit(1)
+		}
+	}
+
+	fstq := eutils.FASTQConverter(inp)
+
+	for fsq := range fstq {
+		if minMean > 0 && eutils.MeanPhredQuality(fsq.Quality, offset) < float64(minMean) {
+			continue
+		}
+		writeFastqAsFasta(fsq)
+	}
+}
+
+// fastqTrim removes low-quality bases from both ends of each read
+func fastqTrim(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	minQual := 20
+	minLen := 1
+	offset := 33
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-min-qual":
+			minQual = eutils.GetNumericArg(args, "Minimum base quality", 0, 0, 0)
+			args = args[2:]
+		case "-min-length":
+			minLen = eutils.GetNumericArg(args, "Minimum trimmed length", 1, 1, 0)
+			args = args[2:]
+		case "-offset":
+			offset = getPhredOffset(args)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -fqtrim command\n")
+			os.Exit(1)
+		}
+	}
+
+	fstq := eutils.FASTQConverter(inp)
+
+	for fsq := range fstq {
+		fsq = eutils.TrimFASTQ(fsq, minQual, offset)
+		if fsq.Length < minLen {
+			continue
+		}
+		writeFastq(fsq)
+	}
+}
+
+// fastqQuality prints the identifier, length, and mean Phred score of each read
+func fastqQuality(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	offset := 33
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-offset":
+			offset = getPhredOffset(args)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -fqmean command\n")
+			os.Exit(1)
+		}
+	}
+
+	fstq := eutils.FASTQConverter(inp)
+
+	for fsq := range fstq {
+		mean := eutils.MeanPhredQuality(fsq.Quality, offset)
+		fmt.Fprintf(os.Stdout, "%s\t%d\t%.2f\n", fsq.SeqID, fsq.Length, mean)
+	}
+}
+
+// VARIANT NORMALIZATION
+
+// readReferenceFasta loads reference sequences keyed by accession, also by full SeqID
+func readReferenceFasta(fname string) map[string]string {
+
+	fl, err := os.Open(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to open reference file '%s'\n", fname)
+		os.Exit(1)
+	}
+	defer fl.Close()
+
+	refs := make(map[string]string)
+
+	count := 0
+	only := ""
+
+	fsta := eutils.FASTAConverter(fl, false)
+	for fsa := range fsta {
+		count++
+		only = fsa.Sequence
+		refs[fsa.SeqID] = fsa.Sequence
+		// also index by accession.version and bare accession extracted from NCBI-style SeqIDs
+		acc := fsa.SeqID
+		if pos := strings.LastIndex(acc, "|"); pos >= 0 {
+			flds := strings.Split(strings.Trim(acc, "|"), "|")
+			acc = flds[len(flds)-1]
+		}
+		refs[acc] = fsa.Sequence
+		if pos := strings.Index(acc, "."); pos > 0 {
+			if _, ok := refs[acc[:pos]]; !ok {
+				refs[acc[:pos]] = fsa.Sequence
+			}
+		}
+	}
+
+	if count < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: No sequences found in reference file '%s'\n", fname)
+		os.Exit(1)
+	}
+
+	// a single reference sequence is used regardless of accession
+	if count == 1 {
+		refs[""] = only
+	}
+
+	return refs
+}
+
+// parseVariantOnReference finds the reference sequence for a variant expression and parses it
+func parseVariantOnReference(expr string, refs map[string]string) (*eutils.SPDI, string) {
+
+	accn, _ := eutils.SplitInTwoLeft(strings.TrimSpace(expr), ":")
+
+	seq, ok := refs[accn]
+	if !ok {
+		seq, ok = refs[""]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "\nERROR: Reference sequence for '%s' not found\n", accn)
+			return nil, ""
+		}
+	}
+
+	spdi := eutils.ParseVariant(expr, seq)
+	spdi = eutils.NormalizeSPDI(seq, spdi)
+
+	return spdi, seq
+}
+
+// normalizeVariants prints the left-aligned SPDI and 3'-shifted HGVS for each input expression
+func normalizeVariants(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	fname := ""
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-ref", "-reference":
+			fname = eutils.GetStringArg(args, "Reference FASTA file")
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -leftalign command\n")
+			os.Exit(1)
+		}
+	}
+
+	if fname == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: -leftalign command requires -ref argument\n")
+		os.Exit(1)
+	}
+
+	refs := readReferenceFasta(fname)
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		expr := strings.TrimSpace(scanr.Text())
+		if expr == "" {
+			continue
+		}
+
+		spdi, _ := parseVariantOnReference(expr, refs)
+		if spdi == nil {
+			continue
+		}
+
+		fmt.Fprintf(os.Stdout, "%s\t%s:%d:%s:%s\t%s\n", expr, spdi.Accession, spdi.Position, spdi.Deleted, spdi.Inserted, spdi.Hgvs)
+	}
+}
+
+// equivalentVariants compares two tab-separated variant expressions per line
+func equivalentVariants(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	fname := ""
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-ref", "-reference":
+			fname = eutils.GetStringArg(args, "Reference FASTA file")
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -equivalent command\n")
+			os.Exit(1)
+		}
+	}
+
+	if fname == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: -equivalent command requires -ref argument\n")
+		os.Exit(1)
+	}
+
+	refs := readReferenceFasta(fname)
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		line := strings.TrimSpace(scanr.Text())
+		if line == "" {
+			continue
+		}
+
+		flds := strings.Fields(line)
+		if len(flds) != 2 {
+			fmt.Fprintf(os.Stderr, "\nERROR: -equivalent expects two variant expressions per line\n")
+			continue
+		}
+
+		frst, seq := parseVariantOnReference(flds[0], refs)
+		scnd, _ := parseVariantOnReference(flds[1], refs)
+		if frst == nil || scnd == nil {
+			continue
+		}
+
+		res := "different"
+		if frst.Accession == scnd.Accession && eutils.VariantsEquivalent(seq, frst, scnd) {
+			res = "equivalent"
+		}
+
+		fmt.Fprintf(os.Stdout, "%s\t%s\t%s\n", flds[0], flds[1], res)
+	}
+}
+
 // REVERSE SEQUENCE
 
 // seqFlip reverses without complementing - e.g., minus strand proteins translated in reverse order
@@ -1685,6 +2059,171 @@ func fastaDiff(inp io.Reader, args []string) {
 	printFastaPairs(frstFasta, scndFasta)
 }
 
+// PAIRWISE ALIGNMENT
+
+func pairwiseAlign(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	local := false
+	protein := false
+	cigarOnly := false
+
+	match := 0
+	mismatch := 0
+	gapOpen := -1
+	gapExtend := -1
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 2 {
+
+		switch args[0] {
+		case "-global":
+			local = false
+			args = args[1:]
+		case "-local":
+			local = true
+			args = args[1:]
+		case "-protein":
+			protein = true
+			args = args[1:]
+		case "-cigar":
+			cigarOnly = true
+			args = args[1:]
+		case "-match":
+			match = eutils.GetNumericArg(args, "match score", 0, 0, 0)
+			args = args[2:]
+		case "-mismatch":
+			// penalty is entered as a positive number
+			mismatch = eutils.GetNumericArg(args, "mismatch penalty", 0, 0, 0)
+			args = args[2:]
+		case "-open":
+			gapOpen = eutils.GetNumericArg(args, "gap open penalty", 0, 0, 0)
+			args = args[2:]
+		case "-extend":
+			gapExtend = eutils.GetNumericArg(args, "gap extension penalty", 0, 0, 0)
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -pairwise command\n")
+			os.Exit(1)
+		}
+	}
+
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Two files required by -pairwise command\n")
+		os.Exit(1)
+	}
+
+	scr := eutils.DefaultScoring(protein)
+	if match > 0 {
+		scr.Match = match
+	}
+	if mismatch > 0 {
+		scr.Mismatch = -mismatch
+	}
+	if gapOpen >= 0 {
+		scr.GapOpen = gapOpen
+	}
+	if gapExtend >= 0 {
+		scr.GapExtend = gapExtend
+	}
+
+	readRecordFromFile := func(fname string) eutils.FASTARecord {
+
+		f, err := os.Open(fname)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open file %s - %s\n", fname, err.Error())
+			os.Exit(1)
+		}
+
+		defer f.Close()
+
+		var rec eutils.FASTARecord
+
+		// return first FASTA record, draining the rest
+		fsta := eutils.FASTAConverter(f, false)
+		for fsa := range fsta {
+			if rec.Sequence == "" {
+				rec = fsa
+			}
+		}
+
+		if rec.SeqID == "" {
+			rec.SeqID = fname
+		}
+
+		return rec
+	}
+
+	frst := readRecordFromFile(args[0])
+	scnd := readRecordFromFile(args[1])
+
+	aln := eutils.AlignPair(frst.Sequence, scnd.Sequence, scr, local)
+	if aln == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: No alignment found between %s and %s\n", frst.SeqID, scnd.SeqID)
+		os.Exit(1)
+	}
+
+	if cigarOnly {
+		fmt.Fprintf(os.Stdout, "%s\n", aln.Cigar)
+		return
+	}
+
+	percent := func(num int) int {
+		return (num*100 + aln.Length/2) / aln.Length
+	}
+
+	fmt.Fprintf(os.Stdout, "Score %d, Identities %d/%d (%d%%), Gaps %d/%d (%d%%)\n",
+		aln.Score, aln.Identities, aln.Length, percent(aln.Identities), aln.Gaps, aln.Length, percent(aln.Gaps))
+	fmt.Fprintf(os.Stdout, "CIGAR %s\n", aln.Cigar)
+
+	// pad identifiers to common width
+	wid := len(frst.SeqID)
+	if len(scnd.SeqID) > wid {
+		wid = len(scnd.SeqID)
+	}
+
+	posF := aln.FirstFrom
+	posS := aln.SecondFrom
+
+	// print in blocks of 60 columns, with identities marked between sequences
+	for i := 0; i < aln.Length; i += 60 {
+
+		j := i + 60
+		if j > aln.Length {
+			j = aln.Length
+		}
+
+		top := aln.First[i:j]
+		bot := aln.Second[i:j]
+
+		var mid strings.Builder
+		for k := 0; k < len(top); k++ {
+			if top[k] == '-' || bot[k] == '-' {
+				mid.WriteString(" ")
+			} else if top[k] == bot[k] {
+				mid.WriteString("|")
+			} else {
+				mid.WriteString(".")
+			}
+		}
+
+		endF := posF + len(top) - strings.Count(top, "-") - 1
+		endS := posS + len(bot) - strings.Count(bot, "-") - 1
+
+		fmt.Fprintf(os.Stdout, "\n%-*s %8d %s %d\n", wid, frst.SeqID, posF, top, endF)
+		fmt.Fprintf(os.Stdout, "%-*s %8s %s\n", wid, "", "", mid.String())
+		fmt.Fprintf(os.Stdout, "%-*s %8d %s %d\n", wid, scnd.SeqID, posS, bot, endS)
+
+		posF = endF + 1
+		posS = endS + 1
+	}
+}
+
 // PROTEIN WEIGHT
 
 func protWeight(inp io.Reader, args []string) {
@@ -1720,6 +2259,55 @@ func protWeight(inp io.Reader, args []string) {
 	}
 }
 
+// PROTEIN PROPERTIES
+
+func protProperty(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	cmd := args[0]
+	reduced := false
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-reduced":
+			reduced = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after %s command\n", cmd)
+			os.Exit(1)
+		}
+	}
+
+	str := readOneFastaSequence(inp)
+
+	switch cmd {
+	case "-pi", "-isoelectric":
+		str = eutils.ProteinIsoelectricPoint(str)
+	case "-extinct", "-extinction":
+		str = eutils.ProteinExtinction(str, reduced)
+	case "-gravy", "-hydropathy":
+		str = eutils.ProteinHydropathy(str)
+	case "-instability":
+		str = eutils.ProteinInstability(str)
+	case "-composition":
+		// one residue per line
+		arry := eutils.ProteinComposition(str)
+		str = strings.Replace(strings.Join(arry, "\n"), ":", "\t", -1)
+	}
+
+	os.Stdout.WriteString(str)
+	if !strings.HasSuffix(str, "\n") {
+		os.Stdout.WriteString("\n")
+	}
+}
+
 // cdRegionToProtein reads all of stdin as sequence data
 func cdRegionToProtein(inp io.Reader, args []string) {
 
@@ -1736,6 +2324,12 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 	is3primeComplete := true
 	between := ""
 
+	// infer genetic code from local taxonomy unless -code is given
+	hasCode := false
+	taxID := ""
+	organelle := ""
+	taxPath := ""
+
 	repeat := 1
 
 	// skip past command name
@@ -1746,6 +2340,16 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 		switch args[0] {
 		case "-code", "-gencode":
 			genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 30)
+			hasCode = true
+			args = args[2:]
+		case "-taxid", "-organism":
+			taxID = eutils.GetStringArg(args, "taxonomy identifier or organism name")
+			args = args[2:]
+		case "-organelle":
+			organelle = eutils.GetStringArg(args, "organelle")
+			args = args[2:]
+		case "-taxonomy":
+			taxPath = eutils.GetStringArg(args, "taxonomy path")
 			args = args[2:]
 		case "-frame":
 			frame = eutils.GetNumericArg(args, "offset into coding sequence", 0, 1, 30)
@@ -1782,6 +2386,22 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 		}
 	}
 
+	if taxID != "" && !hasCode {
+		gl := eutils.NewGeneticCodeLookup(taxPath)
+		if gl == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Local taxonomy not found, set EDIRECT_TAXONOMY_MASTER or use -taxonomy\n")
+			os.Exit(1)
+		}
+		genCode = gl.GeneticCode(taxID, organelle)
+		if genCode == 0 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to find genetic code for '%s'\n", taxID)
+			os.Exit(1)
+		}
+	} else if organelle != "" && !hasCode {
+		fmt.Fprintf(os.Stderr, "\nERROR: -organelle requires -taxid\n")
+		os.Exit(1)
+	}
+
 	txt := readOneFastaSequence(inp)
 
 	for i := 0; i < repeat; i++ {
@@ -1796,6 +2416,56 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 	}
 }
 
+// findOpenReadingFrames scans each FASTA record on six frames
+func findOpenReadingFrames(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	genCode := 1
+	minLength := 30
+	altStarts := false
+	topStrand := false
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-code", "-gencode":
+			genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 30)
+			args = args[2:]
+		case "-min", "-minimum":
+			minLength = eutils.GetNumericArg(args, "minimum protein length", 0, 0, 0)
+			args = args[2:]
+		case "-alt", "-alternative":
+			altStarts = true
+			args = args[1:]
+		case "-top":
+			topStrand = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -orfs command\n")
+			os.Exit(1)
+		}
+	}
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	for fsa := range fsta {
+
+		orfs := eutils.FindORFs(fsa.Sequence, genCode, minLength, altStarts, topStrand)
+
+		for _, orf := range orfs {
+			// one-based coordinates, length in residues excludes stop
+			fmt.Fprintf(os.Stdout, "%s\t%d\t%d\t%s\t%s%d\t%d\t%s\n", fsa.SeqID, orf.Start+1, orf.Stop+1,
+				orf.Strand, orf.Strand, orf.Frame, len(orf.Protein), orf.Protein)
+		}
+	}
+}
+
 // nucProtCodonReport prints amino acid residues under nucleotide codons
 func nucProtCodonReport(args []string) {
 
@@ -2231,6 +2901,7 @@ func main() {
 		set := "root"
 		rec := ""
 		nest := "element"
+		hints := true
 
 		// look for optional arguments
 		for {
@@ -2252,6 +2923,12 @@ func main() {
 				if ok && rec == "-" {
 					rec = ""
 				}
+			case "-hints":
+				// JSON types and arrays are recorded for round-trip through -x2j by default
+				hints = true
+			case "-plain":
+				// omit json:type and json:array attributes
+				hints = false
 			case "-nest":
 				// specify nested array naming policy
 				nest, ok = nextArg()
@@ -2289,7 +2966,12 @@ func main() {
 		}
 
 		// use output channel of tokenizer as input channel of converter
-		jcnv := eutils.JSONConverter(in, set, rec, nest)
+		var jcnv <-chan string
+		if hints {
+			jcnv = eutils.HintedJSONConverter(in, set, rec, nest)
+		} else {
+			jcnv = eutils.JSONConverter(in, set, rec, nest)
+		}
 
 		if jcnv == nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create JSON to XML converter\n")
@@ -2557,35 +3239,158 @@ func main() {
 		tail := ""
 
 		// drain output of last channel in service chain
-		for str := range gbk {
+		for str := range gbk {
+
+			if str == "" {
+				continue
+			}
+
+			recordCount++
+			byteCount += len(str)
+
+			if head != "" {
+				os.Stdout.WriteString(head)
+				head = ""
+				tail = `</INSDSet>
+`
+			}
+
+			// send result to stdout
+			os.Stdout.WriteString(str)
+			if !strings.HasSuffix(str, "\n") {
+				os.Stdout.WriteString("\n")
+			}
+
+			runtime.Gosched()
+		}
+
+		if tail != "" {
+			os.Stdout.WriteString(tail)
+		}
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
+	// READ FASTA AND WRAP EACH SEQUENCE AS INSDSEQ XML
+
+	if len(args) > 0 && (args[0] == "-f2x" || args[0] == "-fasta2xml") {
+
+		fsa := eutils.FASTAtoINSDSeq(in)
+
+		if fsa == nil {
+			fmt.Fprintf(os.Stderr, "Unable to create FASTA to XML converter\n")
+			os.Exit(1)
+		}
+
+		head := `<?xml version="1.0" encoding="UTF-8" ?>
+<!DOCTYPE INSDSet PUBLIC "-//NCBI//INSD INSDSeq/EN" "https://www.ncbi.nlm.nih.gov/dtd/INSD_INSDSeq.dtd">
+<INSDSet>
+`
+		tail := `</INSDSet>
+`
+
+		// drain output of last channel in service chain
+		recordCount, byteCount = eutils.DrainConverter(head, tail, fsa)
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
+	// FASTQ TO XML FOR READ FILTERING
+
+	if len(args) > 0 && (args[0] == "-fq2x" || args[0] == "-fastq2xml") {
+
+		offset := 33
+		if len(args) > 1 && args[1] == "-offset" {
+			offset = getPhredOffset(args[1:])
+		}
+
+		fsq := eutils.FASTQtoXML(in, offset)
+
+		if fsq == nil {
+			fmt.Fprintf(os.Stderr, "Unable to create FASTQ to XML converter\n")
+			os.Exit(1)
+		}
+
+		head := `<?xml version="1.0" encoding="UTF-8" ?>
+<FASTQSet>
+`
+		tail := `</FASTQSet>
+`
+
+		// drain output of last channel in service chain
+		recordCount, byteCount = eutils.DrainConverter(head, tail, fsq)
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
+	// GFF3 OR GTF ANNOTATION TO INSDSEQ XML
+
+	if len(args) > 0 && (args[0] == "-gff2x" || args[0] == "-gtf2x") {
+
+		gff := eutils.GFFtoINSDSeq(in)
+
+		if gff == nil {
+			fmt.Fprintf(os.Stderr, "Unable to create GFF to XML converter\n")
+			os.Exit(1)
+		}
+
+		head := `<?xml version="1.0" encoding="UTF-8" ?>
+<!DOCTYPE INSDSet PUBLIC "-//NCBI//INSD INSDSeq/EN" "https://www.ncbi.nlm.nih.gov/dtd/INSD_INSDSeq.dtd">
+<INSDSet>
+`
+		tail := `</INSDSet>
+`
+
+		// drain output of last channel in service chain
+		recordCount, byteCount = eutils.DrainConverter(head, tail, gff)
 
-			if str == "" {
-				continue
-			}
+		debug.FreeOSMemory()
 
-			recordCount++
-			byteCount += len(str)
+		if timr {
+			printDuration("records")
+		}
 
-			if head != "" {
-				os.Stdout.WriteString(head)
-				head = ""
-				tail = `</INSDSet>
-`
-			}
+		return
+	}
 
-			// send result to stdout
-			os.Stdout.WriteString(str)
-			if !strings.HasSuffix(str, "\n") {
-				os.Stdout.WriteString("\n")
-			}
+	// VCF VARIANTS TO SPDI AND HGVS XML
 
-			runtime.Gosched()
-		}
+	if len(args) > 0 && (args[0] == "-vcf2x" || args[0] == "-vcf2xml") {
 
-		if tail != "" {
-			os.Stdout.WriteString(tail)
+		vcf := eutils.VCFtoXML(in)
+
+		if vcf == nil {
+			fmt.Fprintf(os.Stderr, "Unable to create VCF to XML converter\n")
+			os.Exit(1)
 		}
 
+		head := `<?xml version="1.0" encoding="UTF-8" ?>
+<VCFSet>
+`
+		tail := `</VCFSet>
+`
+
+		// drain output of last channel in service chain
+		recordCount, byteCount = eutils.DrainConverter(head, tail, vcf)
+
 		debug.FreeOSMemory()
 
 		if timr {
@@ -2828,6 +3633,10 @@ func main() {
 		makePlain(in)
 	case "-hgvs":
 		decodeHGVS(in)
+	case "-leftalign":
+		normalizeVariants(in, args)
+	case "-equivalent":
+		equivalentVariants(in, args)
 	case "-align":
 		processAlign(in, args)
 	case "-remove":
@@ -2850,18 +3659,30 @@ func main() {
 		lowerString(in)
 	case "-counts", "-basecount":
 		baseCount(in)
+	case "-fq2fa", "-fastq2fasta":
+		fastqToFasta(in, args)
+	case "-fqtrim":
+		fastqTrim(in, args)
+	case "-fqmean":
+		fastqQuality(in, args)
 	case "-revcomp":
 		nucRevComp(in)
 	case "-reverse":
 		seqFlip(in)
 	case "-molwt":
 		protWeight(in, args)
+	case "-pi", "-isoelectric", "-extinct", "-extinction", "-gravy", "-hydropathy", "-instability", "-composition":
+		protProperty(in, args)
 	case "-cds2prot":
 		cdRegionToProtein(in, args)
+	case "-orfs":
+		findOpenReadingFrames(in, args)
 	case "-codons":
 		nucProtCodonReport(args)
 	case "-diff":
 		fastaDiff(in, args)
+	case "-pairwise":
+		pairwiseAlign(in, args)
 	default:
 		// if not any of the conversion commands, keep going
 		inSwitch = false
@@ -3202,6 +4023,348 @@ func main() {
 		return
 	}
 
+	// INSDSEQ XML TO GENBANK FLATFILE
+
+	if len(args) > 0 && (args[0] == "-x2g" || args[0] == "-xml2gb") {
+
+		xmlq := eutils.CreateXMLProducer("INSDSeq", "", false, rdr)
+		gbfq := eutils.CreateGenBankWriters(xmlq)
+		unsq := eutils.CreateXMLUnshuffler(gbfq)
+
+		if xmlq == nil || gbfq == nil || unsq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create XML to GenBank converter\n")
+			os.Exit(1)
+		}
+
+		// drain output channel
+		for curr := range unsq {
+
+			str := curr.Text
+
+			if str == "" {
+				continue
+			}
+
+			// send result to output
+			os.Stdout.WriteString(str)
+
+			recordCount++
+			byteCount += len(str)
+
+			runtime.Gosched()
+		}
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
+	// INSDSEQ FEATURES TO GFF3 OR BED12
+
+	if len(args) > 0 && (args[0] == "-x2gff" || args[0] == "-xml2gff" || args[0] == "-x2bed" || args[0] == "-xml2bed") {
+
+		asBED := strings.HasSuffix(args[0], "bed")
+
+		// skip past command name
+		args = args[1:]
+
+		var keys map[string]bool
+
+		// look for optional arguments
+		for {
+			arg, ok := nextArg()
+			if !ok {
+				break
+			}
+
+			switch arg {
+			case "-feature", "-features":
+				// restrict to comma-separated list of feature keys
+				ftrs, ok := nextArg()
+				if !ok || ftrs == "" {
+					fmt.Fprintf(os.Stderr, "\nERROR: Item missing after -feature command\n")
+					os.Exit(1)
+				}
+				keys = eutils.ParseFeatureKeys(ftrs)
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -x2gff or -x2bed command\n")
+				os.Exit(1)
+			}
+		}
+
+		xmlq := eutils.CreateXMLProducer("INSDSeq", "", false, rdr)
+		ftrq := eutils.CreateFeatureExporters(asBED, keys, xmlq)
+		unsq := eutils.CreateXMLUnshuffler(ftrq)
+
+		if xmlq == nil || ftrq == nil || unsq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create feature table exporter\n")
+			os.Exit(1)
+		}
+
+		if !asBED {
+			os.Stdout.WriteString("##gff-version 3\n")
+		}
+
+		// drain output channel
+		for curr := range unsq {
+
+			str := curr.Text
+
+			if str == "" {
+				continue
+			}
+
+			// send result to output
+			os.Stdout.WriteString(str)
+
+			recordCount++
+			byteCount += len(str)
+
+			runtime.Gosched()
+		}
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
+	// CODON USAGE OF INSDSEQ CODING REGIONS
+
+	if len(args) > 0 && args[0] == "-codon-usage" {
+
+		perGene := false
+		genCode := 0
+
+		// skip past command name
+		args = args[1:]
+
+		// look for optional arguments
+		for {
+			arg, ok := nextArg()
+			if !ok {
+				break
+			}
+
+			switch arg {
+			case "-gene", "-per-gene":
+				perGene = true
+			case "-code", "-gencode":
+				// override transl_table qualifiers for grouping synonymous codons
+				str, ok := nextArg()
+				if !ok {
+					fmt.Fprintf(os.Stderr, "\nERROR: Item missing after -code command\n")
+					os.Exit(1)
+				}
+				genCode = eutils.GetNumericArg([]string{arg, str}, "genetic code number", 1, 1, 30)
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -codon-usage command\n")
+				os.Exit(1)
+			}
+		}
+
+		xmlq := eutils.CreateXMLProducer("INSDSeq", "", false, rdr)
+		cdsq := eutils.CreateCodingExtractors(xmlq)
+		unsq := eutils.CreateXMLUnshuffler(cdsq)
+
+		if xmlq == nil || cdsq == nil || unsq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create codon usage counter\n")
+			os.Exit(1)
+		}
+
+		genes := make(map[string]*eutils.CodonUsage)
+		var order []string
+
+		// without -gene, coding regions are pooled separately for each genetic code
+		totals := make(map[int]*eutils.CodonUsage)
+		var codes []int
+
+		// drain output channel, accumulating counts in record order
+		for curr := range unsq {
+
+			recordCount++
+			byteCount += len(curr.Text)
+
+			for _, line := range strings.Split(curr.Text, "\n") {
+
+				cols := strings.Split(line, "\t")
+				if len(cols) != 3 {
+					continue
+				}
+
+				name := cols[0]
+				code := genCode
+				if code == 0 {
+					code, _ = strconv.Atoi(cols[1])
+				}
+
+				if perGene {
+					cu, ok := genes[name]
+					if !ok {
+						cu = eutils.NewCodonUsage(code)
+						genes[name] = cu
+						order = append(order, name)
+					}
+					cu.AddCDS(cols[2])
+					continue
+				}
+
+				total, ok := totals[code]
+				if !ok {
+					total = eutils.NewCodonUsage(code)
+					totals[code] = total
+					codes = append(codes, code)
+				}
+				total.AddCDS(cols[2])
+			}
+
+			runtime.Gosched()
+		}
+
+		if perGene {
+			for _, name := range order {
+				os.Stdout.WriteString(genes[name].Report(name))
+			}
+		} else if len(codes) == 1 {
+			os.Stdout.WriteString(totals[codes[0]].Report(""))
+		} else {
+			// mixed genetic codes are reported separately, prefixed by code number
+			for _, code := range codes {
+				os.Stdout.WriteString(totals[code].Report("Code" + strconv.Itoa(code)))
+			}
+		}
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
+	// XML TO JSON CONVERTER
+
+	if len(args) > 0 && (args[0] == "-x2j" || args[0] == "-xml2json") {
+
+		// skip past command name
+		args = args[1:]
+
+		pat := ""
+		prefix := "@"
+		asArray := false
+		asStrings := false
+		wrap := false
+
+		// look for optional arguments
+		for {
+			arg, ok := nextArg()
+			if !ok {
+				break
+			}
+
+			switch arg {
+			case "-pattern", "-Pattern", "-record", "-Record":
+				pat, ok = nextArg()
+				if !ok || pat == "" || strings.HasPrefix(pat, "-") {
+					fmt.Fprintf(os.Stderr, "\nERROR: Item missing after -pattern command\n")
+					os.Exit(1)
+				}
+			case "-prefix":
+				// override attribute key prefix
+				prefix, ok = nextArg()
+				if ok && prefix == "-" {
+					prefix = ""
+				}
+			case "-array":
+				asArray = true
+			case "-lines", "-ndjson":
+				asArray = false
+			case "-strings":
+				asStrings = true
+			case "-wrap":
+				wrap = true
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -x2j argument '%s'\n", arg)
+				os.Exit(1)
+			}
+		}
+
+		if pat == "" {
+			fmt.Fprintf(os.Stderr, "\nERROR: No -pattern supplied to -x2j\n")
+			os.Exit(1)
+		}
+
+		// look for -pattern Parent/* construct for heterogeneous data
+		topPattern, star := eutils.SplitInTwoLeft(pat, "/")
+		if topPattern == "" {
+			return
+		}
+
+		xmlq := eutils.CreateXMLProducer(topPattern, star, false, rdr)
+		jsnq := eutils.CreateJSONConverters(topPattern, prefix, asStrings, wrap, xmlq)
+		unsq := eutils.CreateXMLUnshuffler(jsnq)
+
+		if xmlq == nil || jsnq == nil || unsq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create XML to JSON converter\n")
+			os.Exit(1)
+		}
+
+		if asArray {
+			os.Stdout.WriteString("[\n")
+		}
+
+		between := ""
+
+		// drain output channel
+		for curr := range unsq {
+
+			str := curr.Text
+
+			if str == "" {
+				continue
+			}
+
+			os.Stdout.WriteString(between)
+			if asArray {
+				between = ",\n"
+			}
+
+			// send result to output, one JSON object per line
+			os.Stdout.WriteString(str)
+			if !asArray {
+				os.Stdout.WriteString("\n")
+			}
+
+			recordCount++
+			byteCount += len(str)
+
+			runtime.Gosched()
+		}
+
+		if asArray {
+			if recordCount > 0 {
+				os.Stdout.WriteString("\n")
+			}
+			os.Stdout.WriteString("]\n")
+		}
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
 	// ENSURE PRESENCE OF PATTERN ARGUMENT
 
 	if len(args) < 1 {
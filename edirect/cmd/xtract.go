@@ -602,11 +602,35 @@ func main() {
 	hd := ""
 	tl := ""
 
+	// -output json writes one JSON object per record instead of tab-delimited text,
+	// -output json-typed also writes numbers, true, false, and null as JSON literals
+	asJSON := false
+	typed := false
+
 	for {
 
 		inSwitch = true
 
 		switch args[0] {
+		case "-output":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Format missing after -output command\n")
+				os.Exit(1)
+			}
+			switch args[1] {
+			case "json", "JSON":
+				asJSON = true
+				typed = false
+			case "json-typed", "JSON-typed":
+				asJSON = true
+				typed = true
+			case "text", "tsv":
+				asJSON = false
+				typed = false
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -output format '%s'\n", args[1])
+				os.Exit(1)
+			}
 		case "-head":
 			if len(args) < 2 {
 				fmt.Fprintf(os.Stderr, "\nERROR: Pattern missing after -head command\n")
@@ -706,6 +730,12 @@ func main() {
 		}
 	}
 
+	// text wrappers would make newline-delimited JSON unreadable
+	if asJSON && (head != "" || tail != "" || hd != "" || tl != "") {
+		fmt.Fprintf(os.Stderr, "\nERROR: -output json cannot be combined with -head, -tail, -hd, -tl, -set, -rec, or -wrp\n")
+		os.Exit(1)
+	}
+
 	// CREATE XML BLOCK READER FROM STDIN OR FILE
 
 	const FirstBuffSize = 4096
@@ -845,6 +875,8 @@ func main() {
 		html := false
 		max := 0
 
+		var schema *eutils.XMLSchema
+
 		// look for optional arguments
 		for {
 			arg, ok := nextArg()
@@ -865,10 +897,18 @@ func main() {
 				if err == nil && val > 0 {
 					max = val
 				}
+			case "-dtd", "-xsd":
+				// check content models and attributes against schema
+				fname, ok := nextArg()
+				if !ok || fname == "" {
+					fmt.Fprintf(os.Stderr, "\nERROR: File name missing after %s argument\n", arg)
+					os.Exit(1)
+				}
+				schema = eutils.LoadSchema(fname, arg[1:])
 			}
 		}
 
-		recordCount = eutils.ValidateXML(rdr, find, html, max)
+		recordCount = eutils.ValidateXML(rdr, find, html, max, schema)
 
 		debug.FreeOSMemory()
 
@@ -998,6 +1038,70 @@ func main() {
 		return
 	}
 
+	// CODING REGION TRANSLATION WITH GENETIC CODE FROM LOCAL TAXONOMY
+
+	// efetch -db nuccore -id NC_012920 -format gbc | xtract -translate
+	if args[0] == "-translate" {
+
+		genCode := 0
+		includeStop := false
+		taxPath := ""
+
+		args = args[1:]
+
+		for len(args) > 0 {
+
+			switch args[0] {
+			case "-code", "-gencode":
+				// override transl_table qualifiers and taxonomy
+				genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 30)
+				args = args[2:]
+			case "-stop", "-stops":
+				includeStop = true
+				args = args[1:]
+			case "-taxonomy":
+				taxPath = eutils.GetStringArg(args, "taxonomy path")
+				args = args[2:]
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -translate command\n")
+				os.Exit(1)
+			}
+		}
+
+		// without local taxonomy, codes come from transl_table or default to 1
+		gl := eutils.NewGeneticCodeLookup(taxPath)
+		if gl == nil && genCode == 0 {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Local taxonomy not found, using transl_table or standard code\n")
+		}
+
+		xmlq := eutils.CreateXMLProducer("INSDSeq", "", false, rdr)
+		trnq := eutils.CreateTranslators(gl, genCode, includeStop, xmlq)
+		unsq := eutils.CreateXMLUnshuffler(trnq)
+
+		if xmlq == nil || trnq == nil || unsq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create coding region translator\n")
+			os.Exit(1)
+		}
+
+		for curr := range unsq {
+
+			recordCount++
+			byteCount += len(curr.Text)
+
+			os.Stdout.WriteString(curr.Text)
+
+			runtime.Gosched()
+		}
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
 	// ENSURE PRESENCE OF PATTERN ARGUMENT
 
 	if len(args) < 1 {
@@ -1548,6 +1652,23 @@ func main() {
 		return
 	}
 
+	// CROSS-RECORD AGGREGATION
+
+	// -group-by replaces the extraction commands with one raw row per record, reduced after the stream ends
+	var grp *eutils.GroupBy
+	for i, str := range args {
+		if str == "-group-by" {
+			if asJSON {
+				fmt.Fprintf(os.Stderr, "\nERROR: -output json cannot be combined with -group-by\n")
+				os.Exit(1)
+			}
+			var cmnds []string
+			grp, cmnds = eutils.ParseGroupBy(args[i:])
+			args = append(args[:i:i], cmnds...)
+			break
+		}
+	}
+
 	// PARSE AND VALIDATE EXTRACTION ARGUMENTS
 
 	// parse nested exploration instruction from command-line arguments
@@ -1743,7 +1864,12 @@ func main() {
 		cmds.Position = ""
 
 		// process single selected record
-		res := eutils.ProcessExtract(qry[:], parent, idx, hd, tl, transform, nil, histogram, cmds)
+		res := ""
+		if asJSON {
+			res = eutils.ProcessExtractJSON(qry[:], parent, idx, typed, transform, nil, histogram, cmds)
+		} else {
+			res = eutils.ProcessExtract(qry[:], parent, idx, hd, tl, transform, nil, histogram, cmds)
+		}
 
 		if res != "" {
 			fmt.Printf("%s", res)
@@ -1758,7 +1884,12 @@ func main() {
 	xmlq := eutils.CreateXMLProducer(topPattern, star, turbo, rdr)
 
 	// launch consumer goroutines to parse and explore partitioned XML objects
-	tblq := eutils.CreateXMLConsumers(cmds, parent, hd, tl, transform, forClassify, histogram, xmlq)
+	var tblq <-chan eutils.XMLRecord
+	if asJSON {
+		tblq = eutils.CreateJSONConsumers(cmds, parent, typed, transform, forClassify, histogram, xmlq)
+	} else {
+		tblq = eutils.CreateXMLConsumers(cmds, parent, hd, tl, transform, forClassify, histogram, xmlq)
+	}
 
 	// launch unshuffler goroutine to restore order of results
 	unsq := eutils.CreateXMLUnshuffler(tblq)
@@ -1805,6 +1936,17 @@ func main() {
 
 	// DRAIN OUTPUT CHANNEL TO EXECUTE EXTRACTION COMMANDS, RESTORE OUTPUT ORDER WITH HEAP
 
+	if grp != nil {
+		recordCount, byteCount = grp.Drain(unsq)
+		grp.Print()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
 	recordCount, byteCount = eutils.DrainExtractions(head, tail, posn, mpty, idnt, histogram, unsq)
 
 	if timr {
@@ -528,6 +528,8 @@ FinishSetup() {
   if [ -n "$basx" ]
   then
     base="$basx"
+    # alternative servers keep their own history, skip PubMed preview server workarounds
+    quick=true
   elif [ "$dev" = true ]
   then
     base="https://dev.ncbi.nlm.nih.gov/entrez/eutils/"
@@ -481,8 +481,9 @@ func mapXMLtoASN(node *XMLNode, proc func(string)) {
 const XMLDoctypeGzipLen = 183
 
 // CreateStashers saves records to archive, multithreaded for performance, use of UID
-// position index allows it to prevent earlier version from overwriting later version
-func CreateStashers(stsh, parent, indx, pfx, sfx, db, xmlString string, hash, zipp, asn bool, report int, inp <-chan XMLRecord) <-chan string {
+// position index allows it to prevent earlier version from overwriting later version,
+// and history flag keeps prior versions with a manifest naming the source update file
+func CreateStashers(stsh, parent, indx, pfx, sfx, db, xmlString, source string, hash, zipp, asn, history bool, report int, inp <-chan XMLRecord) <-chan string {
 
 	if inp == nil {
 		return nil
@@ -677,6 +678,11 @@ func CreateStashers(stsh, parent, indx, pfx, sfx, db, xmlString string, hash, zi
 			return ""
 		}
 
+		if history {
+			// rename existing file with its version number before writing new version
+			saveVersion(dpath, pfx, file, sfx, source, "update")
+		}
+
 		// overwrites and truncates existing file
 		fl, err := os.Create(fpath)
 		if err != nil {
@@ -775,12 +781,17 @@ func CreateStashers(stsh, parent, indx, pfx, sfx, db, xmlString string, hash, zi
 
 // CreateDeleter reads PMIDs, deletes them in the archive, and sends them
 // down a channel to have the affected inverted index cache files removed.
-func CreateDeleter(stsh string, in io.Reader) <-chan string {
+// With the history flag, deleted records are kept as prior versions.
+func CreateDeleter(stsh, pfx, sfx, source string, zipp, history bool, in io.Reader) <-chan string {
 
 	if stsh == "" || in == nil {
 		return nil
 	}
 
+	if zipp {
+		sfx += ".gz"
+	}
+
 	out := make(chan string, ChanDepth())
 	if out == nil {
 		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create deleter channel\n")
@@ -821,12 +832,17 @@ func CreateDeleter(stsh string, in io.Reader) <-chan string {
 				continue
 			}
 
-			dpath := filepath.Join(stsh, dir, file+".xml.gz")
+			dpath := filepath.Join(stsh, dir, pfx+file+sfx)
 			if dpath == "" {
 				continue
 			}
 
-			os.Remove(dpath)
+			if history {
+				// rename with version number instead of removing
+				saveVersion(filepath.Join(stsh, dir), pfx, file, sfx, source, "delete")
+			} else {
+				os.Remove(dpath)
+			}
 			if verbose {
 				fmt.Fprintf(os.Stderr, "DEL PMD %s\n", dpath)
 			}
@@ -928,6 +944,18 @@ func CreateClearer(indexBase, invertBase string, inp <-chan string) <-chan strin
 // CreateFetchers returns uncompressed records from archive, multithreaded for speed
 func CreateFetchers(stsh, db, pfx, sfx string, zipp bool, inp <-chan XMLRecord) <-chan XMLRecord {
 
+	return createArchiveFetchers(stsh, db, pfx, sfx, "", zipp, inp)
+}
+
+// CreateAsOfFetchers returns the versions of records that were current at a given time,
+// skipping records that had not yet been added or had already been deleted
+func CreateAsOfFetchers(stsh, db, pfx, sfx, asof string, zipp bool, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	return createArchiveFetchers(stsh, db, pfx, sfx, asof, zipp, inp)
+}
+
+func createArchiveFetchers(stsh, db, pfx, sfx, asof string, zipp bool, inp <-chan XMLRecord) <-chan XMLRecord {
+
 	if inp == nil || stsh == "" {
 		return nil
 	}
@@ -950,7 +978,19 @@ func CreateFetchers(stsh, db, pfx, sfx string, zipp bool, inp <-chan XMLRecord)
 
 			buf.Reset()
 
-			str := fetchOneXMLRecord(ext.Text, stsh, pfx, sfx, zipp, buf)
+			vsfx := sfx
+			if asof != "" {
+				id := strings.TrimPrefix(ext.Text, "PMC")
+				res, ok := versionAsOf(stsh, id, pfx, sfx, asof)
+				if !ok {
+					// send empty record to keep unshuffler in order
+					out <- XMLRecord{Index: ext.Index, Ident: ext.Ident, Text: ""}
+					continue
+				}
+				vsfx = res
+			}
+
+			str := fetchOneXMLRecord(ext.Text, stsh, pfx, vsfx, zipp, buf)
 
 			// trim header now included in archive XML files
 			if db == "" || db == "pubmed" {
@@ -132,13 +132,48 @@ func json2pmid(jsn string) (bool, string, string) {
 	return true, "unmatched", "0"
 }
 
-// Citation2PMID is a shortcut for extracting the PMID from the citMatch network service result
-func Citation2PMID(query string) string {
+// mutex to protect creation of the scorer shared by Citation2PMID calls
+var clock sync.Mutex
+
+var (
+	citScorer        *CitScorer
+	citScorerChecked bool
+)
+
+// sharedCitScorer checks for the local archive once, and returns nil if it is not mounted
+func sharedCitScorer() *CitScorer {
+
+	clock.Lock()
+	defer clock.Unlock()
+
+	if !citScorerChecked {
+		citScorer = NewCitScorer(nil, true)
+		citScorerChecked = true
+	}
+
+	return citScorer
+}
+
+// Citation2PMID is a shortcut for obtaining the PMID of a free-text citation, scored against
+// the local archive when it is mounted, with the citMatch network service only if remote is set
+func Citation2PMID(query string, remote bool) string {
 
 	if query == "" {
 		return ""
 	}
 
+	scorer := sharedCitScorer()
+	if scorer != nil {
+		pid, conf := scorer.MatchText(query)
+		if pid > 0 && conf >= citConfidence {
+			return strconv.Itoa(int(pid))
+		}
+	}
+
+	if !remote {
+		return ""
+	}
+
 	jsn := cit2json(query)
 
 	ok, _, pmid := json2pmid(jsn)
@@ -230,7 +265,7 @@ func NormalizeCitation(text string) string {
 
 	// collect fields in desired order
 	flds := []string{"ACCN", "DIV", "REF", "FAUT", "LAUT", "CSRT", "ATHR", "TITL",
-		"JOUR", "VOL", "ISS", "PAGE", "YEAR", "TEXT", "STAT", "ORIG", "PMID", "NOTE"}
+		"JOUR", "VOL", "ISS", "PAGE", "YEAR", "TEXT", "STAT", "ORIG", "PMID", "CONF", "NOTE"}
 
 	var arry []string
 
@@ -1454,18 +1489,23 @@ func PreloadCitCache(fileName string, cache *CitCache) {
 		ident := citFields["TEXT"]
 
 		pmid := citFields["PMID"]
+		conf := citFields["CONF"]
 		note := citFields["NOTE"]
 
 		pm := ""
 		if pmid != "" {
 			pm = "<PMID>" + pmid + "</PMID>"
 		}
+		cf := ""
+		if conf != "" {
+			cf = "<CONF>" + conf + "</CONF>"
+		}
 		nt := ""
 		if note != "" {
 			nt = "<NOTE>" + note + "</NOTE>"
 		}
 
-		suffix := pm + nt
+		suffix := pm + cf + nt
 
 		// cache result (cached PMID + NOTE can be empty)
 		cache.matchResultCache[ident] = suffix
@@ -1559,6 +1599,17 @@ func CreateCitMatchers(inp <-chan XMLRecord, options []string, deStop, doStem bo
 		}
 	}
 
+	// weighted scoring of all citation fields when title-based filtering fails
+	var scorer *CitScorer
+	if local {
+		scorer = NewCitScorer(jtaMap, deStop)
+	}
+
+	minConfidence := citConfidence
+	if strict {
+		minConfidence = citStrictConfidence
+	}
+
 	// gbCitMatch reads partitioned XML from channel and looks up candidate PMIDs
 	gbCitMatch := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
 
@@ -2486,6 +2537,7 @@ func CreateCitMatchers(inp <-chan XMLRecord, options []string, deStop, doStem bo
 			orig := citFields["ORIG"]
 
 			pmid := ""
+			conf := ""
 			note := ""
 
 			if local {
@@ -2501,6 +2553,7 @@ func CreateCitMatchers(inp <-chan XMLRecord, options []string, deStop, doStem bo
 
 					if checkCitedPMID(citFields, refFields) {
 						pmid = orig
+						conf = "100"
 						note = "verified"
 					}
 				}
@@ -2510,6 +2563,7 @@ func CreateCitMatchers(inp <-chan XMLRecord, options []string, deStop, doStem bo
 					pid, nte := citFind(citFields)
 					if pid > 0 {
 						pmid = strconv.Itoa(int(pid))
+						conf = "100"
 						if verbose {
 							note = nte
 						} else {
@@ -2518,6 +2572,19 @@ func CreateCitMatchers(inp <-chan XMLRecord, options []string, deStop, doStem bo
 					}
 				}
 
+				// then score candidates from author, journal, year, volume, and page postings
+				if pmid == "" && scorer != nil {
+					pid, cnf, nte := scorer.Match(citFields)
+					if debug {
+						fmt.Fprintf(os.Stderr, "scored %d, confidence %d, reason %s\n", pid, cnf, nte)
+					}
+					if pid > 0 && cnf >= minConfidence {
+						pmid = strconv.Itoa(int(pid))
+						conf = strconv.Itoa(cnf)
+						note = "scored"
+					}
+				}
+
 				if debug {
 					fmt.Fprintf(os.Stderr, "pmid %s, orig %s, reason %s\n", pmid, orig, note)
 				}
@@ -2538,7 +2605,7 @@ func CreateCitMatchers(inp <-chan XMLRecord, options []string, deStop, doStem bo
 				}
 			}
 
-			// non-verbose note is simple - verified, edirect, citmatch, overuse, failed, unmatched
+			// non-verbose note is simple - verified, edirect, scored, citmatch, overuse, failed, unmatched
 			if !verbose && pmid == "" && note == "" {
 				note = "unmatched"
 			}
@@ -2547,12 +2614,16 @@ func CreateCitMatchers(inp <-chan XMLRecord, options []string, deStop, doStem bo
 			if pmid != "" {
 				pm = "<PMID>" + pmid + "</PMID>"
 			}
+			cf := ""
+			if conf != "" {
+				cf = "<CONF>" + conf + "</CONF>"
+			}
 			nt := ""
 			if note != "" {
 				nt = "<NOTE>" + note + "</NOTE>"
 			}
 
-			suffix := pm + nt
+			suffix := pm + cf + nt
 
 			res := "<CITATION>" + prefix + body + suffix + "</CITATION>"
 
@@ -0,0 +1,514 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  citscore.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LOCAL CITATION SCORING AGAINST PUBMED POSTINGS
+
+// citWeights sets the contribution of each citation field to the confidence value
+var citWeights = map[string]int{
+	"TITL": 40,
+	"FAUT": 15,
+	"LAUT": 10,
+	"JOUR": 10,
+	"YEAR": 10,
+	"VOL":  5,
+	"PAGE": 10,
+}
+
+// minimum confidence for accepting a scored match, raised in strict mode
+const (
+	citConfidence       = 60
+	citStrictConfidence = 80
+)
+
+// CitScorer matches citations against the local archive without using the network
+type CitScorer struct {
+	postingsBase string
+	archiveBase  string
+	jtaMap       map[string]string
+	deStop       bool
+}
+
+// citCandidate is a PMID with its accumulated weighted votes
+type citCandidate struct {
+	UID   int32
+	Title int
+	Score int
+}
+
+// NewCitScorer returns nil if the local archive and search index are not mounted
+func NewCitScorer(jtaMap map[string]string, deStop bool) *CitScorer {
+
+	// obtain path from environment variable
+	base := os.Getenv("EDIRECT_PUBMED_MASTER")
+	if base == "" {
+		return nil
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	postingsBase := base + "Postings"
+	archiveBase := base + "Archive"
+
+	for _, dir := range []string{postingsBase, archiveBase} {
+		_, err := os.Stat(dir)
+		if err != nil {
+			return nil
+		}
+	}
+
+	if jtaMap == nil {
+		jtaMap = make(map[string]string)
+	}
+
+	return &CitScorer{
+		postingsBase: postingsBase,
+		archiveBase:  archiveBase,
+		jtaMap:       jtaMap,
+		deStop:       deStop,
+	}
+}
+
+func (cs *CitScorer) query(str string) []int32 {
+
+	return ProcessQuery(cs.postingsBase, "pubmed", str, false, false, false, false, cs.deStop)
+}
+
+// citWords returns lower-case words, without stop words
+func citWords(str string) []string {
+
+	var arry []string
+
+	str = strings.ToLower(str)
+
+	words := strings.FieldsFunc(str, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+
+	for _, item := range words {
+		if item == "" || IsStopWord(item) {
+			continue
+		}
+		arry = append(arry, item)
+	}
+
+	return arry
+}
+
+// citPairs returns the word pairs and singletons that PubMed indexing puts in the PAIR field
+func citPairs(str string) []string {
+
+	return indexPairs(str, true, true, false)
+}
+
+// titleSimilarity is the Jaccard index of the distinct words in two titles, as a percentage
+func titleSimilarity(a, b string) int {
+
+	wa := make(map[string]bool)
+	for _, item := range citWords(CleanTitle(a)) {
+		wa[item] = true
+	}
+	wb := make(map[string]bool)
+	for _, item := range citWords(CleanTitle(b)) {
+		wb[item] = true
+	}
+
+	intrs := 0
+	for item := range wa {
+		if wb[item] {
+			intrs++
+		}
+	}
+	union := len(wa) + len(wb) - intrs
+	if union < 1 {
+		return 0
+	}
+
+	return intrs * 100 / union
+}
+
+// recordFields collects lower-case citation fields from an archived PubmedArticle
+func (cs *CitScorer) recordFields(uid int32) map[string]string {
+
+	refFields := make(map[string]string)
+
+	var buf bytes.Buffer
+	pma := fetchOneXMLRecord(strconv.Itoa(int(uid)), cs.archiveBase, "", ".xml", true, buf)
+	pma = strings.TrimSpace(pma)
+	if pma == "" {
+		return refFields
+	}
+
+	pat := ParseRecord(pma[:], "PubmedArticle")
+
+	var last []string
+
+	VisitNodes(pat, "AuthorList/Author", func(auth *XMLNode) {
+		VisitElements(auth, "LastName", func(str string) {
+			last = append(last, strings.ToLower(str))
+		})
+	})
+
+	if len(last) > 0 {
+		refFields["FAUT"] = last[0]
+		refFields["LAUT"] = last[len(last)-1]
+	}
+
+	VisitElements(pat, "ArticleTitle", func(str string) {
+		refFields["TITL"] = strings.ToLower(CleanTitle(str))
+	})
+
+	VisitNodes(pat, "Article/Journal", func(jour *XMLNode) {
+
+		VisitElements(jour, "ISOAbbreviation", func(str string) {
+			refFields["JOUR"] = strings.ToLower(CleanJournal(str))
+		})
+
+		VisitElements(jour, "JournalIssue/Volume", func(str string) {
+			refFields["VOL"] = strings.ToLower(str)
+		})
+
+		VisitElements(jour, "PubDate/Year", func(str string) {
+			refFields["YEAR"] = str
+		})
+	})
+
+	VisitElements(pat, "Pagination/MedlinePgn", func(str string) {
+		refFields["PAGE"] = strings.ToLower(CleanPage(str))
+	})
+
+	return refFields
+}
+
+// rankCandidates sorts by descending score, then by ascending PMID to prefer the original paper
+func rankCandidates(cands []*citCandidate) {
+
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].Score != cands[j].Score {
+			return cands[i].Score > cands[j].Score
+		}
+		return cands[i].UID < cands[j].UID
+	})
+}
+
+// Match scores candidate PMIDs by weighted votes from title, author, journal, year, volume,
+// and page postings, returning the best PMID, its confidence percentage, and a reason for failure
+func (cs *CitScorer) Match(citFields map[string]string) (int32, int, string) {
+
+	if citFields == nil {
+		return 0, 0, "map missing"
+	}
+
+	// maximum score achievable from the fields present in the citation
+	possible := 0
+
+	candidates := make(map[int32]*citCandidate)
+
+	// initial candidates match at least half of the overlapping title word pairs
+
+	titl := strings.ToLower(CleanTitle(citFields["TITL"]))
+
+	pairs := citPairs(titl)
+	if len(pairs) > 0 {
+
+		possible += citWeights["TITL"]
+
+		counts := make(map[int32]int)
+		for _, item := range pairs {
+			for _, uid := range cs.query(item + " [PAIR]") {
+				counts[uid]++
+			}
+		}
+
+		for uid, num := range counts {
+			if num*2 >= len(pairs) {
+				votes := citWeights["TITL"] * num / len(pairs)
+				candidates[uid] = &citCandidate{UID: uid, Title: votes, Score: votes}
+			}
+		}
+	}
+
+	// prepare postings queries for the remaining fields
+
+	type citQuery struct {
+		Field  string
+		Query  string
+		Weight int
+	}
+
+	var queries []citQuery
+
+	addQuery := func(fld, query string, weight int) {
+		queries = append(queries, citQuery{Field: fld, Query: query, Weight: weight})
+		possible += weight
+	}
+
+	authorQuery := func(name string) string {
+		name = strings.ToLower(CleanAuthor(name))
+		if name == "" {
+			return ""
+		}
+		if strings.Index(name, " ") < 0 {
+			// just last name, space plus asterisk to wildcard on initials
+			name += " "
+		}
+		return name + "* [AUTH]"
+	}
+
+	faut := authorQuery(citFields["FAUT"])
+	laut := authorQuery(citFields["LAUT"])
+	if faut != "" {
+		addQuery("FAUT", faut, citWeights["FAUT"])
+	}
+	if laut != "" && laut != faut {
+		addQuery("LAUT", laut, citWeights["LAUT"])
+	}
+
+	jour := strings.ToLower(CleanJournal(citFields["JOUR"]))
+	if jour != "" {
+		// map full journal title to indexed abbreviation, unless ambiguous
+		jta, ok := cs.jtaMap[jour]
+		if ok && jta != "" && strings.Index(jta, "|") < 0 {
+			jour = strings.ToLower(CleanJournal(jta))
+		}
+		addQuery("JOUR", jour+" [JOUR]", citWeights["JOUR"])
+	}
+
+	year := strings.TrimSpace(citFields["YEAR"])
+	if yr, err := strconv.Atoi(year); err == nil {
+		// adjacent year earns half credit, exact year earns the rest
+		half := citWeights["YEAR"] / 2
+		addQuery("YEAR", strconv.Itoa(yr-1)+":"+strconv.Itoa(yr+1)+" [YEAR]", half)
+		addQuery("YEAR", year+" [YEAR]", citWeights["YEAR"]-half)
+	}
+
+	vol := strings.ToLower(strings.TrimSpace(citFields["VOL"]))
+	if vol != "" && IsAllDigits(vol) {
+		addQuery("VOL", vol+" [VOL]", citWeights["VOL"])
+	}
+
+	page := citWords(CleanPage(citFields["PAGE"]))
+	if len(page) > 0 {
+		addQuery("PAGE", page[0]+" [PAGE]", citWeights["PAGE"])
+	}
+
+	if possible < 1 {
+		return 0, 0, "empty citation"
+	}
+
+	postings := make([][]int32, len(queries))
+	for i, qry := range queries {
+		postings[i] = cs.query(qry.Query)
+	}
+
+	// without a usable title, seed candidates from author and journal intersection
+	if len(candidates) < 1 {
+
+		auth := make(map[int32]bool)
+		jrnl := make(map[int32]bool)
+		for i, qry := range queries {
+			switch qry.Field {
+			case "FAUT", "LAUT":
+				for _, uid := range postings[i] {
+					auth[uid] = true
+				}
+			case "JOUR":
+				for _, uid := range postings[i] {
+					jrnl[uid] = true
+				}
+			}
+		}
+
+		for uid := range auth {
+			if jrnl[uid] {
+				candidates[uid] = &citCandidate{UID: uid}
+			}
+		}
+
+		// too many candidates to discriminate
+		if len(candidates) > 1000 {
+			return 0, 0, "insufficient citation fields"
+		}
+	}
+
+	if len(candidates) < 1 {
+		return 0, 0, "no candidates"
+	}
+
+	// each field adds its weight to every candidate present in its postings
+	for i, qry := range queries {
+		for _, uid := range postings[i] {
+			cand, ok := candidates[uid]
+			if ok {
+				cand.Score += qry.Weight
+			}
+		}
+	}
+
+	var ranked []*citCandidate
+	for _, cand := range candidates {
+		ranked = append(ranked, cand)
+	}
+	rankCandidates(ranked)
+
+	// refine title votes of leading candidates by comparison to archived title
+	if titl != "" {
+		if len(ranked) > 10 {
+			ranked = ranked[:10]
+		}
+		for _, cand := range ranked {
+			refFields := cs.recordFields(cand.UID)
+			ttl := refFields["TITL"]
+			if ttl == "" {
+				continue
+			}
+			votes := citWeights["TITL"] * titleSimilarity(titl, ttl) / 100
+			cand.Score += votes - cand.Title
+			cand.Title = votes
+		}
+		rankCandidates(ranked)
+	}
+
+	best := ranked[0]
+	if len(ranked) > 1 && ranked[1].Score == best.Score {
+		return 0, 0, "ambiguous candidates"
+	}
+
+	conf := best.Score * 100 / possible
+	if conf > 100 {
+		conf = 100
+	}
+
+	return best.UID, conf, ""
+}
+
+// MatchText scores candidates for an unstructured citation string, with title word pairs
+// found in the postings, and other fields confirmed in the archived record
+func (cs *CitScorer) MatchText(text string) (int32, int) {
+
+	pairs := citPairs(CleanTitle(text))
+	if len(pairs) < 1 {
+		return 0, 0
+	}
+
+	counts := make(map[int32]int)
+	for _, item := range pairs {
+		for _, uid := range cs.query(item + " [PAIR]") {
+			counts[uid]++
+		}
+	}
+
+	// require at least three matching pairs to avoid false positives
+	max := 0
+	for _, num := range counts {
+		if num > max {
+			max = num
+		}
+	}
+	if max < 3 {
+		return 0, 0
+	}
+
+	var ranked []*citCandidate
+	for uid, num := range counts {
+		if num >= max-1 {
+			ranked = append(ranked, &citCandidate{UID: uid, Score: num})
+		}
+	}
+	rankCandidates(ranked)
+	if len(ranked) > 25 {
+		ranked = ranked[:25]
+	}
+
+	words := make(map[string]bool)
+	for _, item := range citWords(CleanTitle(text)) {
+		words[item] = true
+	}
+
+	// all words of a record field must appear in the citation text
+	contains := func(str string) bool {
+		wrds := citWords(str)
+		if len(wrds) < 1 {
+			return false
+		}
+		for _, item := range wrds {
+			if !words[item] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, cand := range ranked {
+
+		refFields := cs.recordFields(cand.UID)
+
+		score := 0
+
+		// fraction of record title words present in citation text
+		ttl := citWords(refFields["TITL"])
+		if len(ttl) > 0 {
+			num := 0
+			for _, item := range ttl {
+				if words[item] {
+					num++
+				}
+			}
+			score += citWeights["TITL"] * num / len(ttl)
+		}
+
+		for _, fld := range []string{"FAUT", "LAUT", "JOUR", "YEAR", "VOL", "PAGE"} {
+			if contains(refFields[fld]) {
+				score += citWeights[fld]
+			}
+		}
+
+		cand.Score = score
+	}
+	rankCandidates(ranked)
+
+	best := ranked[0]
+	if len(ranked) > 1 && ranked[1].Score == best.Score {
+		return 0, 0
+	}
+
+	return best.UID, best.Score
+}
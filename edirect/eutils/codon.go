@@ -0,0 +1,243 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  codon.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CODON USAGE AND GC CONTENT OF CODING REGIONS
+
+// INSDSeqToCDS writes one line per coding region, with name, genetic code,
+// and spliced nucleotide sequence starting at the first complete codon
+func INSDSeqToCDS(text string) string {
+
+	seq := ParseRecord(text, "INSDSeq")
+	if seq == nil {
+		return ""
+	}
+
+	nucs := strings.ToUpper(xmlChildText(seq, "INSDSeq_sequence"))
+	if nucs == "" {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	for _, feat := range parseINSDFeatures(seq, map[string]bool{"CDS": true}) {
+
+		genCode := 1
+		offset := 0
+		skip := false
+
+		for _, qual := range feat.Quals {
+			switch qual.Name {
+			case "transl_table":
+				if val, err := strconv.Atoi(qual.Value); err == nil {
+					genCode = val
+				}
+			case "codon_start":
+				if val, err := strconv.Atoi(qual.Value); err == nil && val > 1 && val < 4 {
+					offset = val - 1
+				}
+			case "pseudo", "pseudogene":
+				// pseudogenes do not reflect selection on codon usage
+				skip = true
+			}
+		}
+		if skip {
+			continue
+		}
+
+		cds, ok := featureSequence(nucs, feat)
+		if !ok || len(cds) <= offset {
+			continue
+		}
+
+		buffer.WriteString(featureName(feat))
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(genCode))
+		buffer.WriteString("\t")
+		buffer.WriteString(cds[offset:])
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
+
+// featureSequence splices the nucleotides under feature intervals, returning false if
+// any interval extends past the end of the sequence
+func featureSequence(nucs string, feat insdFeat) (string, bool) {
+
+	// intervals are in biological order, so minus strand exons are complemented in place
+	var cds strings.Builder
+	for _, exon := range feat.Exons {
+		if exon.Stop > len(nucs) {
+			return "", false
+		}
+		str := nucs[exon.Start-1 : exon.Stop]
+		if exon.Minus {
+			str = ReverseComplement(str)
+		}
+		cds.WriteString(str)
+	}
+
+	return cds.String(), true
+}
+
+// CreateCodingExtractors runs concurrent INSDSeq to spliced coding region converters
+func CreateCodingExtractors(inp <-chan XMLRecord) <-chan XMLRecord {
+
+	return createINSDSeqConverters("coding region extractor", INSDSeqToCDS, inp)
+}
+
+// CodonUsage accumulates codon counts and base composition over coding regions
+type CodonUsage struct {
+	GenCode int
+	Regions int
+	Codons  int
+	Counts  map[int]int
+	GC      int
+	GC3     int
+}
+
+// NewCodonUsage creates an empty codon usage accumulator for a genetic code
+func NewCodonUsage(genCode int) *CodonUsage {
+
+	return &CodonUsage{GenCode: genCode, Counts: make(map[int]int)}
+}
+
+// AddCDS counts each complete unambiguous codon in a coding region
+func (cu *CodonUsage) AddCDS(seq string) {
+
+	cu.Regions++
+
+	for i := 0; i+3 <= len(seq); i += 3 {
+
+		cdn := seq[i : i+3]
+		if strings.Trim(cdn, "ACGT") != "" {
+			continue
+		}
+
+		state := SetCodonState(int(cdn[0]), int(cdn[1]), int(cdn[2]))
+
+		cu.Counts[state]++
+		cu.Codons++
+
+		for k := 0; k < 3; k++ {
+			if cdn[k] == 'G' || cdn[k] == 'C' {
+				cu.GC++
+				if k == 2 {
+					cu.GC3++
+				}
+			}
+		}
+	}
+}
+
+// Report writes summary rows, then one row per codon with amino acid, count,
+// frequency per thousand, and relative synonymous codon usage (RSCU)
+func (cu *CodonUsage) Report(prefix string) string {
+
+	if cu.Codons == 0 {
+		return ""
+	}
+
+	type codonRow struct {
+		Codon   string
+		Residue string
+		State   int
+	}
+
+	var rows []codonRow
+
+	// synonymous codons are grouped by the residue they encode in this genetic code
+	synonyms := make(map[string]int)
+	totals := make(map[string]int)
+
+	for _, ch1 := range "ACGT" {
+		for _, ch2 := range "ACGT" {
+			for _, ch3 := range "ACGT" {
+				state := SetCodonState(int(ch1), int(ch2), int(ch3))
+				res := string(rune(GetCodonResidue(cu.GenCode, state)))
+				rows = append(rows, codonRow{Codon: GetCodonFromState(state), Residue: res, State: state})
+				synonyms[res]++
+				totals[res] += cu.Counts[state]
+			}
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Residue != rows[j].Residue {
+			return rows[i].Residue < rows[j].Residue
+		}
+		return rows[i].Codon < rows[j].Codon
+	})
+
+	var buffer strings.Builder
+
+	percent := func(num, denom int) string {
+		return strconv.FormatFloat(100*float64(num)/float64(denom), 'f', 2, 64)
+	}
+
+	writeRow := func(items ...string) {
+		if prefix != "" {
+			buffer.WriteString(prefix)
+			buffer.WriteString("\t")
+		}
+		buffer.WriteString(strings.Join(items, "\t"))
+		buffer.WriteString("\n")
+	}
+
+	writeRow("CDS", strconv.Itoa(cu.Regions))
+	writeRow("Codons", strconv.Itoa(cu.Codons))
+	writeRow("GC", percent(cu.GC, 3*cu.Codons))
+	writeRow("GC3", percent(cu.GC3, cu.Codons))
+
+	for _, row := range rows {
+
+		count := cu.Counts[row.State]
+		per := strconv.FormatFloat(1000*float64(count)/float64(cu.Codons), 'f', 2, 64)
+
+		// RSCU is observed count divided by the count expected if all synonyms were used equally
+		rscu := "-"
+		if totals[row.Residue] > 0 {
+			expected := float64(totals[row.Residue]) / float64(synonyms[row.Residue])
+			rscu = strconv.FormatFloat(float64(count)/expected, 'f', 2, 64)
+		}
+
+		writeRow(row.Codon, row.Residue, strconv.Itoa(count), per, rscu)
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,567 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  entrez.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// E-UTILITIES COMPATIBILITY
+
+// history limits follow the PubMed server, sessions expire after eight hours without use,
+// a session holds a limited number of query keys, and total saved UIDs are bounded
+const (
+	historyIdle    = 8 * time.Hour
+	historyMaxKeys = 100
+	historyMaxUIDs = 50000000
+)
+
+// historySession holds the UID lists saved under one WebEnv
+type historySession struct {
+	keys  [][]int32
+	count int
+	used  time.Time
+}
+
+// HistoryServer keeps search and post results for WebEnv and query_key reuse,
+// discarding the oldest sessions when session or UID limits are reached, and then
+// the earliest query keys of the current session if it alone exceeds the UID limit
+type HistoryServer struct {
+	mutex    sync.Mutex
+	sessions map[string]*historySession
+	order    []string
+	max      int
+	total    int
+}
+
+// NewHistoryServer creates an in-memory history server
+func NewHistoryServer(max int) *HistoryServer {
+
+	if max < 1 {
+		max = 1000
+	}
+
+	return &HistoryServer{sessions: make(map[string]*historySession), max: max}
+}
+
+// newWebEnv generates an opaque session identifier
+func newWebEnv() string {
+
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return ""
+	}
+
+	return "MCID_" + hex.EncodeToString(buf)
+}
+
+// removeSession discards the session at a position in creation order, caller must hold the mutex
+func (hs *HistoryServer) removeSession(idx int) {
+
+	if idx < 0 || idx >= len(hs.order) {
+		return
+	}
+
+	webenv := hs.order[idx]
+	hs.order = append(hs.order[:idx], hs.order[idx+1:]...)
+
+	ssn, ok := hs.sessions[webenv]
+	if ok {
+		hs.total -= ssn.count
+		delete(hs.sessions, webenv)
+	}
+}
+
+// Store saves UIDs in an existing session, or in a new one if webenv is empty, has
+// expired, or has no query keys left, returning the session identifier and the 1-based
+// query key
+func (hs *HistoryServer) Store(webenv string, uids []int32) (string, int) {
+
+	if hs == nil || len(uids) > historyMaxUIDs {
+		return "", 0
+	}
+
+	// a nil list marks a released query key, so empty results are saved as non-nil
+	if uids == nil {
+		uids = []int32{}
+	}
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	now := time.Now()
+
+	// discard idle sessions, order is by creation rather than last use, so check each one
+	var keep []string
+	for _, item := range hs.order {
+		ssn, ok := hs.sessions[item]
+		if ok && now.Sub(ssn.used) > historyIdle {
+			hs.total -= ssn.count
+			delete(hs.sessions, item)
+			continue
+		}
+		if ok {
+			keep = append(keep, item)
+		}
+	}
+	hs.order = keep
+
+	ssn, ok := hs.sessions[webenv]
+	if webenv == "" || !ok || len(ssn.keys) >= historyMaxKeys {
+		webenv = newWebEnv()
+		if webenv == "" {
+			return "", 0
+		}
+		for len(hs.order) >= hs.max {
+			hs.removeSession(0)
+		}
+		ssn = &historySession{}
+		hs.sessions[webenv] = ssn
+		hs.order = append(hs.order, webenv)
+	}
+
+	// make room for the new UIDs by discarding other sessions, oldest first
+	for idx := 0; hs.total+len(uids) > historyMaxUIDs && idx < len(hs.order); {
+		if hs.order[idx] == webenv {
+			idx++
+			continue
+		}
+		hs.removeSession(idx)
+	}
+
+	// then release earlier query keys of the session being extended, keeping key numbers stable
+	for idx := 0; hs.total+len(uids) > historyMaxUIDs && idx < len(ssn.keys); idx++ {
+		hs.total -= len(ssn.keys[idx])
+		ssn.count -= len(ssn.keys[idx])
+		ssn.keys[idx] = nil
+	}
+
+	ssn.keys = append(ssn.keys, uids)
+	ssn.count += len(uids)
+	ssn.used = now
+	hs.total += len(uids)
+
+	return webenv, len(ssn.keys)
+}
+
+// Lookup returns the UIDs saved under a session and query key
+func (hs *HistoryServer) Lookup(webenv string, key int) ([]int32, bool) {
+
+	if hs == nil {
+		return nil, false
+	}
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	ssn, ok := hs.sessions[webenv]
+	if !ok || key < 1 || key > len(ssn.keys) || ssn.keys[key-1] == nil {
+		return nil, false
+	}
+
+	if time.Since(ssn.used) > historyIdle {
+		return nil, false
+	}
+
+	ssn.used = time.Now()
+
+	return ssn.keys[key-1], true
+}
+
+// EntrezErrorXML reports a failure inside the named result wrapper
+func EntrezErrorXML(root, msg string) string {
+
+	var buffer strings.Builder
+
+	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n")
+	buffer.WriteString("<" + root + ">\n")
+	buffer.WriteString("\t<ERROR>" + html.EscapeString(msg) + "</ERROR>\n")
+	buffer.WriteString("</" + root + ">\n")
+
+	return buffer.String()
+}
+
+// ESearchResultXML produces an eSearchResult document, query key and WebEnv are
+// omitted unless the result was saved on the history server
+func ESearchResultXML(count, retmax, retstart, key int, webenv string, ids []int32, translation string) string {
+
+	var buffer strings.Builder
+
+	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n")
+	buffer.WriteString("<!DOCTYPE eSearchResult PUBLIC \"-//NLM//DTD esearch 20060628//EN\" \"https://eutils.ncbi.nlm.nih.gov/eutils/dtd/20060628/esearch.dtd\">\n")
+	buffer.WriteString("<eSearchResult>")
+	buffer.WriteString("<Count>" + strconv.Itoa(count) + "</Count>")
+	buffer.WriteString("<RetMax>" + strconv.Itoa(retmax) + "</RetMax>")
+	buffer.WriteString("<RetStart>" + strconv.Itoa(retstart) + "</RetStart>")
+	if webenv != "" {
+		buffer.WriteString("<QueryKey>" + strconv.Itoa(key) + "</QueryKey>")
+		buffer.WriteString("<WebEnv>" + webenv + "</WebEnv>")
+	}
+	if len(ids) > 0 {
+		buffer.WriteString("<IdList>\n")
+		for _, uid := range ids {
+			buffer.WriteString("<Id>" + strconv.Itoa(int(uid)) + "</Id>\n")
+		}
+		buffer.WriteString("</IdList>")
+	} else {
+		buffer.WriteString("<IdList/>")
+	}
+	buffer.WriteString("<TranslationSet/>")
+	buffer.WriteString("<QueryTranslation>" + html.EscapeString(translation) + "</QueryTranslation>")
+	buffer.WriteString("</eSearchResult>\n")
+
+	return buffer.String()
+}
+
+// EPostResultXML produces an ePostResult document
+func EPostResultXML(key int, webenv string) string {
+
+	var buffer strings.Builder
+
+	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n")
+	buffer.WriteString("<!DOCTYPE ePostResult PUBLIC \"-//NLM//DTD epost 20090526//EN\" \"https://eutils.ncbi.nlm.nih.gov/eutils/dtd/20090526/epost.dtd\">\n")
+	buffer.WriteString("<ePostResult>\n")
+	buffer.WriteString("\t<QueryKey>" + strconv.Itoa(key) + "</QueryKey>\n")
+	buffer.WriteString("\t<WebEnv>" + webenv + "</WebEnv>\n")
+	buffer.WriteString("</ePostResult>\n")
+
+	return buffer.String()
+}
+
+// UIDListXML produces the efetch uilist report in XML mode
+func UIDListXML(ids []string) string {
+
+	var buffer strings.Builder
+
+	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n")
+	buffer.WriteString("<eFetchResult>\n")
+	buffer.WriteString("\t<IdList>\n")
+	for _, id := range ids {
+		buffer.WriteString("\t\t<Id>" + id + "</Id>\n")
+	}
+	buffer.WriteString("\t</IdList>\n")
+	buffer.WriteString("</eFetchResult>\n")
+
+	return buffer.String()
+}
+
+// ESummaryHead returns the opening wrapper for version 1 or version 2.0 summaries
+func ESummaryHead(version2 bool) string {
+
+	if version2 {
+		return "<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n" +
+			"<!DOCTYPE eSummaryResult PUBLIC \"-//NLM//DTD esummary pubmed 20250101//EN\" \"https://eutils.ncbi.nlm.nih.gov/eutils/dtd/20250101/esummary_pubmed.dtd\">\n" +
+			"<eSummaryResult>\n<DocumentSummarySet status=\"OK\">\n"
+	}
+
+	return "<?xml version=\"1.0\" encoding=\"UTF-8\" ?>\n" +
+		"<!DOCTYPE eSummaryResult PUBLIC \"-//NLM//DTD esummary v1 20041029//EN\" \"https://eutils.ncbi.nlm.nih.gov/eutils/dtd/20041029/esummary-v1.dtd\">\n" +
+		"<eSummaryResult>\n"
+}
+
+// ESummaryTail returns the closing wrapper
+func ESummaryTail(version2 bool) string {
+
+	if version2 {
+		return "</DocumentSummarySet>\n</eSummaryResult>\n"
+	}
+
+	return "</eSummaryResult>\n"
+}
+
+// pubmedSummary holds the document summary fields taken from a PubmedArticle
+type pubmedSummary struct {
+	UID      string
+	PubDate  string
+	Source   string
+	Authors  []string
+	Title    string
+	Volume   string
+	Issue    string
+	Pages    string
+	Langs    []string
+	ISSN     string
+	PubTypes []string
+	DOI      string
+	HasAbst  bool
+	Journal  string
+}
+
+// xmlAttribute returns the value of a named attribute
+func xmlAttribute(node *XMLNode, name string) string {
+
+	if node == nil {
+		return ""
+	}
+
+	attribs := ParseAttributes(node.Attributes)
+	for i := 0; i+1 < len(attribs); i += 2 {
+		if attribs[i] == name {
+			return attribs[i+1]
+		}
+	}
+
+	return ""
+}
+
+// xmlPathNode follows a slash-separated path of child names
+func xmlPathNode(node *XMLNode, path string) *XMLNode {
+
+	for _, name := range strings.Split(path, "/") {
+		node = xmlFirstChild(node, name)
+		if node == nil {
+			return nil
+		}
+	}
+
+	return node
+}
+
+// xmlPathText returns the unescaped contents at the end of a path
+func xmlPathText(node *XMLNode, path string) string {
+
+	node = xmlPathNode(node, path)
+	if node == nil {
+		return ""
+	}
+
+	return html.UnescapeString(node.Contents)
+}
+
+// parsePubmedSummary collects summary fields from PubmedArticle XML
+func parsePubmedSummary(text string) *pubmedSummary {
+
+	pma := ParseRecord(text, "PubmedArticle")
+	if pma == nil {
+		return nil
+	}
+
+	cit := xmlFirstChild(pma, "MedlineCitation")
+	if cit == nil {
+		return nil
+	}
+	art := xmlFirstChild(cit, "Article")
+	jour := xmlFirstChild(art, "Journal")
+	iss := xmlFirstChild(jour, "JournalIssue")
+
+	sum := &pubmedSummary{
+		UID:     xmlChildText(cit, "PMID"),
+		Title:   xmlChildText(art, "ArticleTitle"),
+		Volume:  xmlChildText(iss, "Volume"),
+		Issue:   xmlChildText(iss, "Issue"),
+		Pages:   xmlPathText(art, "Pagination/MedlinePgn"),
+		ISSN:    xmlChildText(jour, "ISSN"),
+		Journal: xmlChildText(jour, "Title"),
+		HasAbst: xmlFirstChild(art, "Abstract") != nil,
+	}
+
+	sum.Source = xmlPathText(cit, "MedlineJournalInfo/MedlineTA")
+	if sum.Source == "" {
+		sum.Source = xmlChildText(jour, "ISOAbbreviation")
+	}
+
+	// PubDate is "Year Mon Day" or free-text MedlineDate
+	pdat := xmlFirstChild(iss, "PubDate")
+	if pdat != nil {
+		sum.PubDate = xmlChildText(pdat, "MedlineDate")
+		if sum.PubDate == "" {
+			var parts []string
+			for _, name := range []string{"Year", "Month", "Day"} {
+				str := xmlChildText(pdat, name)
+				if str != "" {
+					parts = append(parts, str)
+				}
+			}
+			sum.PubDate = strings.Join(parts, " ")
+		}
+	}
+
+	xmlEachChild(xmlFirstChild(art, "AuthorList"), "Author", func(auth *XMLNode) {
+		name := xmlChildText(auth, "CollectiveName")
+		if name == "" {
+			name = strings.TrimSpace(xmlChildText(auth, "LastName") + " " + xmlChildText(auth, "Initials"))
+		}
+		if name != "" {
+			sum.Authors = append(sum.Authors, name)
+		}
+	})
+
+	xmlEachChild(art, "Language", func(lang *XMLNode) {
+		sum.Langs = append(sum.Langs, html.UnescapeString(lang.Contents))
+	})
+
+	xmlEachChild(xmlFirstChild(art, "PublicationTypeList"), "PublicationType", func(ptyp *XMLNode) {
+		sum.PubTypes = append(sum.PubTypes, html.UnescapeString(ptyp.Contents))
+	})
+
+	// prefer DOI from PubmedData, fall back on ELocationID
+	xmlEachChild(xmlPathNode(pma, "PubmedData/ArticleIdList"), "ArticleId", func(aid *XMLNode) {
+		if sum.DOI == "" && xmlAttribute(aid, "IdType") == "doi" {
+			sum.DOI = html.UnescapeString(aid.Contents)
+		}
+	})
+	xmlEachChild(art, "ELocationID", func(eloc *XMLNode) {
+		if sum.DOI == "" && xmlAttribute(eloc, "EIdType") == "doi" {
+			sum.DOI = html.UnescapeString(eloc.Contents)
+		}
+	})
+
+	return sum
+}
+
+// PubmedDocSum converts a PubmedArticle record to an esummary DocSum (version 1)
+// or DocumentSummary (version 2.0) element, or returns an empty string on failure
+func PubmedDocSum(text string, version2 bool) string {
+
+	sum := parsePubmedSummary(text)
+	if sum == nil || sum.UID == "" {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	lastAuthor := ""
+	if len(sum.Authors) > 0 {
+		lastAuthor = sum.Authors[len(sum.Authors)-1]
+	}
+
+	hasAbst := "0"
+	if sum.HasAbst {
+		hasAbst = "1"
+	}
+
+	if version2 {
+
+		elem := func(name, value string) {
+			if value == "" {
+				buffer.WriteString("\t<" + name + "/>\n")
+				return
+			}
+			buffer.WriteString("\t<" + name + ">" + html.EscapeString(value) + "</" + name + ">\n")
+		}
+
+		buffer.WriteString("<DocumentSummary uid=\"" + sum.UID + "\">\n")
+		elem("PubDate", sum.PubDate)
+		elem("Source", sum.Source)
+		buffer.WriteString("\t<Authors>\n")
+		for _, auth := range sum.Authors {
+			buffer.WriteString("\t\t<Author>\n")
+			buffer.WriteString("\t\t\t<Name>" + html.EscapeString(auth) + "</Name>\n")
+			buffer.WriteString("\t\t\t<AuthType>Author</AuthType>\n")
+			buffer.WriteString("\t\t</Author>\n")
+		}
+		buffer.WriteString("\t</Authors>\n")
+		elem("LastAuthor", lastAuthor)
+		elem("Title", sum.Title)
+		elem("Volume", sum.Volume)
+		elem("Issue", sum.Issue)
+		elem("Pages", sum.Pages)
+		buffer.WriteString("\t<Lang>\n")
+		for _, lang := range sum.Langs {
+			buffer.WriteString("\t\t<string>" + html.EscapeString(lang) + "</string>\n")
+		}
+		buffer.WriteString("\t</Lang>\n")
+		elem("ISSN", sum.ISSN)
+		buffer.WriteString("\t<PubType>\n")
+		for _, ptyp := range sum.PubTypes {
+			buffer.WriteString("\t\t<flag>" + html.EscapeString(ptyp) + "</flag>\n")
+		}
+		buffer.WriteString("\t</PubType>\n")
+		buffer.WriteString("\t<ArticleIds>\n")
+		buffer.WriteString("\t\t<ArticleId>\n")
+		buffer.WriteString("\t\t\t<IdType>pubmed</IdType>\n")
+		buffer.WriteString("\t\t\t<Value>" + sum.UID + "</Value>\n")
+		buffer.WriteString("\t\t</ArticleId>\n")
+		if sum.DOI != "" {
+			buffer.WriteString("\t\t<ArticleId>\n")
+			buffer.WriteString("\t\t\t<IdType>doi</IdType>\n")
+			buffer.WriteString("\t\t\t<Value>" + html.EscapeString(sum.DOI) + "</Value>\n")
+			buffer.WriteString("\t\t</ArticleId>\n")
+		}
+		buffer.WriteString("\t</ArticleIds>\n")
+		buffer.WriteString("\t<Attributes>\n")
+		if sum.HasAbst {
+			buffer.WriteString("\t\t<flag>Has Abstract</flag>\n")
+		}
+		buffer.WriteString("\t</Attributes>\n")
+		elem("FullJournalName", sum.Journal)
+		buffer.WriteString("</DocumentSummary>\n")
+
+		return buffer.String()
+	}
+
+	item := func(name, typ, value string) {
+		if value == "" {
+			buffer.WriteString("\t<Item Name=\"" + name + "\" Type=\"" + typ + "\"></Item>\n")
+			return
+		}
+		buffer.WriteString("\t<Item Name=\"" + name + "\" Type=\"" + typ + "\">" + html.EscapeString(value) + "</Item>\n")
+	}
+
+	list := func(name, child string, values []string) {
+		if len(values) < 1 {
+			buffer.WriteString("\t<Item Name=\"" + name + "\" Type=\"List\"></Item>\n")
+			return
+		}
+		buffer.WriteString("\t<Item Name=\"" + name + "\" Type=\"List\">\n")
+		for _, val := range values {
+			buffer.WriteString("\t\t<Item Name=\"" + child + "\" Type=\"String\">" + html.EscapeString(val) + "</Item>\n")
+		}
+		buffer.WriteString("\t</Item>\n")
+	}
+
+	buffer.WriteString("<DocSum>\n")
+	buffer.WriteString("\t<Id>" + sum.UID + "</Id>\n")
+	item("PubDate", "Date", sum.PubDate)
+	item("Source", "String", sum.Source)
+	list("AuthorList", "Author", sum.Authors)
+	item("LastAuthor", "String", lastAuthor)
+	item("Title", "String", sum.Title)
+	item("Volume", "String", sum.Volume)
+	item("Issue", "String", sum.Issue)
+	item("Pages", "String", sum.Pages)
+	list("LangList", "Lang", sum.Langs)
+	item("ISSN", "String", sum.ISSN)
+	list("PubTypeList", "PubType", sum.PubTypes)
+	item("DOI", "String", sum.DOI)
+	item("HasAbstract", "Integer", hasAbst)
+	item("FullJournalName", "String", sum.Journal)
+	buffer.WriteString("</DocSum>\n")
+
+	return buffer.String()
+}
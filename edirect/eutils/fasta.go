@@ -31,9 +31,11 @@
 package eutils
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -252,3 +254,389 @@ func FASTAConverter(inp io.Reader, caseSensitive bool) <-chan FASTARecord {
 
 	return out
 }
+
+// FASTQ PARSING AND QUALITY FILTERING
+
+// FASTQRecord contains parsed data from FASTQ format
+type FASTQRecord struct {
+	SeqID    string
+	Title    string
+	Length   int
+	Sequence string
+	Quality  string
+}
+
+// FASTQConverter partitions a FASTQ set and sends records down a channel
+func FASTQConverter(inp io.Reader) <-chan FASTQRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan FASTQRecord, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create FASTQ converter channel\n")
+		os.Exit(1)
+	}
+
+	// fastqStreamer reads header, sequence, separator, and quality lines
+	fastqStreamer := func(inp io.Reader, out chan<- FASTQRecord) {
+
+		// close channel when all records have been sent
+		defer close(out)
+
+		scanr := bufio.NewScanner(inp)
+		scanr.Buffer(make([]byte, 0, 65536), 64*1024*1024)
+
+		row := 0
+
+		nextLine := func() (string, bool) {
+
+			for scanr.Scan() {
+				row++
+				line := strings.TrimRight(scanr.Text(), "\r")
+				if line == "" {
+					continue
+				}
+				return line, true
+			}
+			return "", false
+		}
+
+		for {
+
+			line, ok := nextLine()
+			if !ok {
+				break
+			}
+
+			if !strings.HasPrefix(line, "@") {
+				fmt.Fprintf(os.Stderr, "\nERROR: FASTQ header expected at line %d\n", row)
+				os.Exit(1)
+			}
+
+			seqid, title := SplitInTwoLeft(line[1:], " ")
+
+			// sequence may be wrapped, continue until plus-sign separator
+			var seq strings.Builder
+			for {
+				line, ok = nextLine()
+				if !ok {
+					fmt.Fprintf(os.Stderr, "\nERROR: FASTQ record '%s' is truncated\n", seqid)
+					os.Exit(1)
+				}
+				if strings.HasPrefix(line, "+") {
+					break
+				}
+				seq.WriteString(strings.TrimSpace(line))
+			}
+
+			// quality may also be wrapped, read until it matches sequence length
+			var qual strings.Builder
+			for qual.Len() < seq.Len() {
+				line, ok = nextLine()
+				if !ok {
+					break
+				}
+				qual.WriteString(strings.TrimSpace(line))
+			}
+
+			if qual.Len() != seq.Len() {
+				fmt.Fprintf(os.Stderr, "\nERROR: FASTQ record '%s' quality length %d does not match sequence length %d\n", seqid, qual.Len(), seq.Len())
+				os.Exit(1)
+			}
+
+			out <- FASTQRecord{SeqID: seqid, Title: title, Length: seq.Len(), Sequence: seq.String(), Quality: qual.String()}
+		}
+	}
+
+	// launch single fastq streamer goroutine
+	go fastqStreamer(inp, out)
+
+	return out
+}
+
+// PhredScores decodes a FASTQ quality string, offset is 33 for Sanger and Illumina 1.8+, 64 for older Illumina
+func PhredScores(qual string, offset int) []int {
+
+	scores := make([]int, len(qual))
+
+	for i := 0; i < len(qual); i++ {
+		val := int(qual[i]) - offset
+		if val < 0 {
+			val = 0
+		}
+		scores[i] = val
+	}
+
+	return scores
+}
+
+// MeanPhredQuality returns the arithmetic mean of the Phred scores in a quality string
+func MeanPhredQuality(qual string, offset int) float64 {
+
+	if qual == "" {
+		return 0
+	}
+
+	sum := 0
+	for _, val := range PhredScores(qual, offset) {
+		sum += val
+	}
+
+	return float64(sum) / float64(len(qual))
+}
+
+// TrimFASTQ removes bases below the minimum Phred score from both ends of a read
+func TrimFASTQ(fsq FASTQRecord, minQual, offset int) FASTQRecord {
+
+	scores := PhredScores(fsq.Quality, offset)
+
+	lft := 0
+	for lft < len(scores) && scores[lft] < minQual {
+		lft++
+	}
+
+	rgt := len(scores)
+	for rgt > lft && scores[rgt-1] < minQual {
+		rgt--
+	}
+
+	fsq.Sequence = fsq.Sequence[lft:rgt]
+	fsq.Quality = fsq.Quality[lft:rgt]
+	fsq.Length = rgt - lft
+
+	return fsq
+}
+
+// FASTQtoXML converts FASTQ reads into XML records for filtering with xtract
+func FASTQtoXML(inp io.Reader, offset int) <-chan string {
+
+	if inp == nil {
+		return nil
+	}
+
+	fstq := FASTQConverter(inp)
+
+	out := make(chan string, chanDepth)
+	if fstq == nil || out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create FASTQ to XML converter channels\n")
+		os.Exit(1)
+	}
+
+	convertFASTQ := func(fstq <-chan FASTQRecord, out chan<- string) {
+
+		// close channel when all records have been sent
+		defer close(out)
+
+		var rec strings.Builder
+
+		for fsq := range fstq {
+
+			rec.Reset()
+
+			scores := PhredScores(fsq.Quality, offset)
+			min, max := 0, 0
+			for i, val := range scores {
+				if i == 0 || val < min {
+					min = val
+				}
+				if val > max {
+					max = val
+				}
+			}
+
+			rec.WriteString("  <FASTQ>\n")
+
+			writeXMLElement(&rec, "    ", "SeqID", fsq.SeqID)
+			if fsq.Title != "" {
+				writeXMLElement(&rec, "    ", "Title", fsq.Title)
+			}
+			writeXMLElement(&rec, "    ", "Length", strconv.Itoa(fsq.Length))
+			// rounded to an integer so xtract numeric conditionals can test it
+			mean := int(MeanPhredQuality(fsq.Quality, offset) + 0.5)
+			writeXMLElement(&rec, "    ", "MeanQuality", strconv.Itoa(mean))
+			writeXMLElement(&rec, "    ", "MinQuality", strconv.Itoa(min))
+			writeXMLElement(&rec, "    ", "MaxQuality", strconv.Itoa(max))
+			writeXMLElement(&rec, "    ", "Sequence", fsq.Sequence)
+			writeXMLElement(&rec, "    ", "Quality", fsq.Quality)
+
+			rec.WriteString("  </FASTQ>\n")
+
+			out <- rec.String()
+		}
+	}
+
+	// launch single converter goroutine
+	go convertFASTQ(fstq, out)
+
+	return out
+}
+
+// FASTA TO INSDSEQ XML CONVERTER
+
+// parseFASTADefline splits a FASTA SeqID into accession and version, handling
+// NCBI-style "gi|1234|ref|NM_000518.5|" and "sp|P69905|HBA_HUMAN" identifiers
+func parseFASTADefline(seqid string) (string, string) {
+
+	accn := seqid
+
+	if strings.Contains(seqid, "|") {
+
+		flds := strings.Split(seqid, "|")
+
+		accn = ""
+		for i := 0; i < len(flds)-1; i++ {
+			switch flds[i] {
+			case "ref", "gb", "emb", "dbj", "tpg", "tpe", "tpd", "sp", "tr", "pdb", "pir", "prf", "lcl", "gnl":
+				accn = flds[i+1]
+			}
+			if accn != "" {
+				break
+			}
+		}
+
+		// fall back to last non-empty component
+		if accn == "" {
+			for i := len(flds) - 1; i >= 0; i-- {
+				if flds[i] != "" {
+					accn = flds[i]
+					break
+				}
+			}
+		}
+	}
+
+	// version must be numeric suffix after last period
+	acc, ver := SplitInTwoRight(accn, ".")
+	if acc != "" && ver != "" && IsAllDigits(ver) {
+		return acc, ver
+	}
+
+	return accn, ""
+}
+
+// guessMoleculeType examines residue composition to distinguish DNA, RNA, and protein
+func guessMoleculeType(seq string) string {
+
+	nuc := 0
+	oth := 0
+	hasT := false
+	hasU := false
+
+	for _, ch := range seq {
+		switch ch {
+		case 'A', 'C', 'G', 'N', 'a', 'c', 'g', 'n':
+			nuc++
+		case 'T', 't':
+			nuc++
+			hasT = true
+		case 'U', 'u':
+			nuc++
+			hasU = true
+		case '-', '*':
+			// gaps and stops do not count
+		default:
+			oth++
+		}
+	}
+
+	// allow a small fraction of IUPAC ambiguity codes in nucleotide sequences
+	if nuc > 0 && nuc*10 >= (nuc+oth)*9 {
+		if hasU && !hasT {
+			return "RNA"
+		}
+		return "DNA"
+	}
+
+	return "AA"
+}
+
+// FASTAtoINSDSeq wraps FASTA records as INSDSeq XML for xtract -insd processing
+func FASTAtoINSDSeq(inp io.Reader) <-chan string {
+
+	if inp == nil {
+		return nil
+	}
+
+	fsta := FASTAConverter(inp, false)
+
+	out := make(chan string, chanDepth)
+	if fsta == nil || out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create FASTA to XML converter channels\n")
+		os.Exit(1)
+	}
+
+	convertFASTA := func(fsta <-chan FASTARecord, out chan<- string) {
+
+		// close channel when all records have been sent
+		defer close(out)
+
+		var rec strings.Builder
+
+		for fsa := range fsta {
+
+			rec.Reset()
+
+			accn, vers := parseFASTADefline(fsa.SeqID)
+			accnver := accn
+			if vers != "" {
+				accnver = accn + "." + vers
+			}
+
+			seq := strings.ToLower(fsa.Sequence)
+			moltype := guessMoleculeType(seq)
+			length := strconv.Itoa(fsa.Length)
+
+			rec.WriteString("  <INSDSeq>\n")
+
+			writeXMLElement(&rec, "    ", "INSDSeq_locus", accn)
+			writeXMLElement(&rec, "    ", "INSDSeq_length", length)
+			if moltype == "DNA" {
+				writeXMLElement(&rec, "    ", "INSDSeq_strandedness", "double")
+			} else if moltype == "RNA" {
+				writeXMLElement(&rec, "    ", "INSDSeq_strandedness", "single")
+			}
+			writeXMLElement(&rec, "    ", "INSDSeq_moltype", moltype)
+			writeXMLElement(&rec, "    ", "INSDSeq_topology", "linear")
+			if fsa.Title != "" {
+				writeXMLElement(&rec, "    ", "INSDSeq_definition", strings.TrimSuffix(strings.TrimSpace(fsa.Title), "."))
+			}
+			writeXMLElement(&rec, "    ", "INSDSeq_primary-accession", accn)
+			if vers != "" {
+				writeXMLElement(&rec, "    ", "INSDSeq_accession-version", accnver)
+			}
+			if fsa.SeqID != accnver {
+				rec.WriteString("    <INSDSeq_other-seqids>\n")
+				writeXMLElement(&rec, "      ", "INSDSeqid", fsa.SeqID)
+				rec.WriteString("    </INSDSeq_other-seqids>\n")
+			}
+
+			// source feature spans entire sequence so -insd sub_sequence works
+			rec.WriteString("    <INSDSeq_feature-table>\n")
+			rec.WriteString("      <INSDFeature>\n")
+			writeXMLElement(&rec, "        ", "INSDFeature_key", "source")
+			writeXMLElement(&rec, "        ", "INSDFeature_location", "1.."+length)
+			rec.WriteString("        <INSDFeature_intervals>\n")
+			rec.WriteString("          <INSDInterval>\n")
+			writeXMLElement(&rec, "            ", "INSDInterval_from", "1")
+			writeXMLElement(&rec, "            ", "INSDInterval_to", length)
+			writeXMLElement(&rec, "            ", "INSDInterval_accession", accnver)
+			rec.WriteString("          </INSDInterval>\n")
+			rec.WriteString("        </INSDFeature_intervals>\n")
+			rec.WriteString("      </INSDFeature>\n")
+			rec.WriteString("    </INSDSeq_feature-table>\n")
+
+			writeXMLElement(&rec, "    ", "INSDSeq_sequence", seq)
+
+			rec.WriteString("  </INSDSeq>\n")
+
+			out <- rec.String()
+		}
+	}
+
+	// launch single converter goroutine
+	go convertFASTA(fsta, out)
+
+	return out
+}
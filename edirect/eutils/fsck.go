@@ -0,0 +1,578 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  fsck.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ARCHIVE, INDICES, AND POSTINGS INTEGRITY CHECKING
+
+// FsckProblem describes a damaged file, with the identifier or postings key it holds
+type FsckProblem struct {
+	Path   string
+	Ident  string
+	Field  string
+	Reason string
+}
+
+// fsckRecords maps archive record type to identifier path used by -index when stashing
+var fsckRecords = map[string]string{
+	"PubmedArticle": "MedlineCitation/PMID",
+	"PMCExtract":    "UID",
+	"TaxNode":       "TaxID",
+}
+
+// readGzipFile decompresses an entire file, including concatenated gzip members
+func readGzipFile(fpath string) (string, error) {
+
+	inFile, err := os.Open(fpath)
+	if err != nil {
+		return "", err
+	}
+
+	defer inFile.Close()
+
+	zpr, err := gzip.NewReader(inFile)
+	if err != nil {
+		return "", err
+	}
+
+	defer zpr.Close()
+
+	data, err := io.ReadAll(zpr)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// checkArchiveRecord verifies that a stashed record decompresses, parses, and has the
+// identifier expected from its file name and trie directory
+func checkArchiveRecord(base, fpath string) *FsckProblem {
+
+	dir, name := filepath.Split(fpath)
+
+	rel, err := filepath.Rel(base, filepath.Clean(dir))
+	if err != nil {
+		rel = ""
+	}
+
+	// file name up to first period, without version or suffix, e.g., 12345.v2.xml.gz
+	file, _, _ := strings.Cut(name, ".")
+	id := strings.TrimPrefix(file, "PMC")
+
+	trie, expect := ArchiveTrie(id)
+	trie = strings.TrimSuffix(trie, "/")
+	if trie == "" || expect == "" || expect != id {
+		return &FsckProblem{Path: fpath, Ident: id, Reason: "unrecognized file name"}
+	}
+	if rel != "" && !strings.HasSuffix(filepath.ToSlash(rel), trie) {
+		return &FsckProblem{Path: fpath, Ident: id, Reason: "file not in trie directory " + trie}
+	}
+
+	str, err := readGzipFile(fpath)
+	if err != nil {
+		return &FsckProblem{Path: fpath, Ident: id, Reason: "decompression failed, " + err.Error()}
+	}
+
+	if strings.HasSuffix(name, ".asn.gz") {
+		if strings.TrimSpace(str) == "" {
+			return &FsckProblem{Path: fpath, Ident: id, Reason: "empty record"}
+		}
+		return nil
+	}
+
+	for parent, indx := range fsckRecords {
+
+		pos := strings.Index(str, "<"+parent+">")
+		if pos < 0 {
+			continue
+		}
+		str = str[pos:]
+
+		if !strings.HasSuffix(strings.TrimSpace(str), "</"+parent+">") {
+			return &FsckProblem{Path: fpath, Ident: id, Reason: "truncated " + parent + " record"}
+		}
+
+		uid := FindIdentifier(str, parent, ParseIndex(indx))
+		uid = strings.TrimPrefix(uid, "PMC")
+		uid, _, _ = strings.Cut(uid, ".")
+		if uid == "" {
+			return &FsckProblem{Path: fpath, Ident: id, Reason: "no identifier in " + parent + " record"}
+		}
+		if uid != id {
+			return &FsckProblem{Path: fpath, Ident: id, Reason: "record identifier " + uid + " does not match file name"}
+		}
+
+		return nil
+	}
+
+	return &FsckProblem{Path: fpath, Ident: id, Reason: "unrecognized record"}
+}
+
+// checkIndexFile verifies that a cached Entrez index file decompresses, is complete, and
+// is in the directory expected from the first identifier it contains
+func checkIndexFile(base, fpath string) *FsckProblem {
+
+	dir, name := filepath.Split(fpath)
+
+	str, err := readGzipFile(fpath)
+	if err != nil {
+		return &FsckProblem{Path: fpath, Reason: "decompression failed, " + err.Error()}
+	}
+
+	if !strings.HasSuffix(strings.TrimSpace(str), "</IdxDocumentSet>") {
+		return &FsckProblem{Path: fpath, Reason: "truncated IdxDocumentSet"}
+	}
+
+	_, after, found := strings.Cut(str, "<IdxUid>")
+	if !found {
+		return nil
+	}
+	uid, _, _ := strings.Cut(after, "</IdxUid>")
+
+	trie, idx := IndexTrie(uid)
+	trie = strings.TrimSuffix(trie, "/")
+	if trie == "" || idx == "" {
+		return nil
+	}
+
+	rel, err := filepath.Rel(base, filepath.Clean(dir))
+	if err != nil {
+		rel = ""
+	}
+
+	if name != idx+".e2x.gz" || (rel != "" && !strings.HasSuffix(filepath.ToSlash(rel), trie)) {
+		return &FsckProblem{Path: fpath, Ident: uid, Reason: "identifier " + uid + " does not belong in " + name}
+	}
+
+	return nil
+}
+
+// checkHistoryFile verifies that a version manifest has increasing version numbers and dates
+func checkHistoryFile(fpath string) *FsckProblem {
+
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return &FsckProblem{Path: fpath, Reason: err.Error()}
+	}
+
+	last := 0
+	prev := ""
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+
+		cols := strings.Split(line, "\t")
+		if len(cols) != 4 {
+			return &FsckProblem{Path: fpath, Reason: "malformed manifest line '" + line + "'"}
+		}
+
+		num, err := strconv.Atoi(cols[0])
+		if err != nil || num <= last || cols[1] < prev {
+			return &FsckProblem{Path: fpath, Reason: "manifest versions out of order"}
+		}
+
+		last = num
+		prev = cols[1]
+	}
+
+	return nil
+}
+
+// checkPostingsFiles verifies the master index, term list, postings, and optional position
+// files for one field and term prefix, e.g., canc.TIAB.mst, canc.TIAB.trm, canc.TIAB.pst
+func checkPostingsFiles(fpath string) *FsckProblem {
+
+	dir, name := filepath.Split(fpath)
+
+	stem := strings.TrimSuffix(name, ".mst")
+	pos := strings.LastIndex(stem, ".")
+	if pos < 1 {
+		return &FsckProblem{Path: fpath, Reason: "unrecognized postings file name"}
+	}
+	key := stem[:pos]
+	field := stem[pos+1:]
+
+	bad := func(reason string) *FsckProblem {
+		return &FsckProblem{Path: fpath, Ident: key, Field: field, Reason: reason}
+	}
+
+	// term prefix determines directory, regular or link postings
+	dpath := filepath.ToSlash(filepath.Clean(dir))
+	trie, _ := PostingsTrie(key)
+	link, _ := LinksTrie(key, false)
+	if !strings.HasSuffix(dpath, field+"/"+trie) && !strings.HasSuffix(dpath, field+"/"+link) {
+		return bad("postings not in directory for term prefix " + key)
+	}
+
+	readInt32s := func(sfx string) ([]int32, int64, bool) {
+		data, err := os.ReadFile(filepath.Join(dir, stem+sfx))
+		if err != nil {
+			return nil, 0, false
+		}
+		if len(data)%4 != 0 {
+			return nil, int64(len(data)), true
+		}
+		arry := make([]int32, len(data)/4)
+		binary.Read(bytes.NewReader(data), binary.LittleEndian, arry)
+		return arry, int64(len(data)), true
+	}
+
+	mst, mlen, ok := readInt32s(".mst")
+	if !ok {
+		return bad("unable to read master index")
+	}
+	if mlen%8 != 0 {
+		return bad("master index size not a multiple of 8")
+	}
+
+	// master index is padded with phantom term and postings position
+	numTerms := len(mst)/2 - 1
+	if numTerms < 1 {
+		return bad("master index has no terms")
+	}
+
+	trm, err := os.ReadFile(filepath.Join(dir, stem+".trm"))
+	if err != nil {
+		return bad("missing term list")
+	}
+
+	pst, plen, ok := readInt32s(".pst")
+	if !ok {
+		return bad("missing postings list")
+	}
+	if plen%4 != 0 {
+		return bad("postings size not a multiple of 4")
+	}
+
+	if mst[0] != 0 || mst[1] != 0 {
+		return bad("master index does not start at zero")
+	}
+
+	for i := 0; i < numTerms; i++ {
+
+		tfrom, tto := mst[2*i], mst[2*i+2]
+		pfrom, pto := mst[2*i+1], mst[2*i+3]
+
+		if tto <= tfrom || int(tto) > len(trm) {
+			return bad("term offsets out of order at term " + strconv.Itoa(i+1))
+		}
+		if trm[tto-1] != '\n' {
+			return bad("term list not aligned with master index at term " + strconv.Itoa(i+1))
+		}
+		if pto <= pfrom || pto%4 != 0 || int64(pto) > plen {
+			return bad("postings offsets out of order at term " + strconv.Itoa(i+1))
+		}
+
+		// UIDs for each term must be positive and strictly increasing
+		prev := int32(0)
+		for _, uid := range pst[pfrom/4 : pto/4] {
+			if uid <= prev {
+				return bad("postings for '" + string(trm[tfrom:tto-1]) + "' not in increasing order")
+			}
+			prev = uid
+		}
+	}
+
+	if int(mst[2*numTerms]) != len(trm) {
+		return bad("term list size does not match master index")
+	}
+	if int64(mst[2*numTerms+1]) != plen {
+		return bad("postings size does not match master index")
+	}
+
+	uqi, ulen, ok := readInt32s(".uqi")
+	if !ok {
+		// fields without positions have no .uqi or .ofs files
+		return nil
+	}
+
+	ofs, err := os.Stat(filepath.Join(dir, stem+".ofs"))
+	if err != nil {
+		return bad("position index without offset data")
+	}
+
+	// position index is parallel to postings, plus phantom entry
+	if ulen != plen+4 {
+		return bad("position index size does not match postings")
+	}
+	for i := 1; i < len(uqi); i++ {
+		if uqi[i] < uqi[i-1] {
+			return bad("position index out of order")
+		}
+	}
+	if int64(uqi[len(uqi)-1]) != ofs.Size() {
+		return bad("offset data size does not match position index")
+	}
+
+	return nil
+}
+
+// CreateIntegrityCheckers walks an archive, indices, or postings directory, checking
+// each file concurrently, and sends a description of every damaged file found
+func CreateIntegrityCheckers(base string) <-chan FsckProblem {
+
+	if base == "" {
+		return nil
+	}
+
+	base = filepath.Clean(base)
+
+	paths := make(chan string, ChanDepth())
+	out := make(chan FsckProblem, ChanDepth())
+	if paths == nil || out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create integrity checker channel\n")
+		os.Exit(1)
+	}
+
+	// walk directory tree, sending files that have a recognized suffix
+	go func() {
+
+		defer close(paths)
+
+		filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+
+			if err != nil {
+				out <- FsckProblem{Path: path, Reason: err.Error()}
+				return nil
+			}
+
+			if info.IsDir() {
+				if info.Name() == "Sentinels" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			name := info.Name()
+			if strings.HasSuffix(name, ".xml.gz") || strings.HasSuffix(name, ".asn.gz") ||
+				strings.HasSuffix(name, ".e2x.gz") || strings.HasSuffix(name, ".hst") ||
+				strings.HasSuffix(name, ".mst") {
+				paths <- path
+			}
+
+			return nil
+		})
+	}()
+
+	fsckChecker := func(wg *sync.WaitGroup, inp <-chan string, out chan<- FsckProblem) {
+
+		defer wg.Done()
+
+		for path := range inp {
+
+			var res *FsckProblem
+
+			switch {
+			case strings.HasSuffix(path, ".mst"):
+				res = checkPostingsFiles(path)
+			case strings.HasSuffix(path, ".e2x.gz"):
+				res = checkIndexFile(base, path)
+			case strings.HasSuffix(path, ".hst"):
+				res = checkHistoryFile(path)
+			default:
+				res = checkArchiveRecord(base, path)
+			}
+
+			if res != nil {
+				out <- *res
+			}
+
+			runtime.Gosched()
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple checker goroutines
+	for i := 0; i < NumServe(); i++ {
+		wg.Add(1)
+		go fsckChecker(&wg, paths, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all checkers are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// QuarantineFile moves a damaged file, and its sibling postings files, to a parallel
+// location under the quarantine directory, so that it can be rebuilt or restored
+func QuarantineFile(base, quarantine string, prob FsckProblem) {
+
+	rel, err := filepath.Rel(filepath.Clean(base), prob.Path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(prob.Path)
+	}
+
+	paths := []string{prob.Path}
+
+	if strings.HasSuffix(prob.Path, ".mst") {
+		stem := strings.TrimSuffix(prob.Path, ".mst")
+		for _, sfx := range []string{".trm", ".pst", ".uqi", ".ofs"} {
+			paths = append(paths, stem+sfx)
+		}
+	}
+
+	dest := filepath.Join(quarantine, filepath.Dir(rel))
+
+	err = os.MkdirAll(dest, os.ModePerm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return
+	}
+
+	for _, path := range paths {
+		_, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		err = os.Rename(path, filepath.Join(dest, filepath.Base(path)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		}
+	}
+}
+
+// RebuildPostings regenerates damaged term list and postings files from the merged
+// inverted index file that covers each term prefix, grouped by field
+func RebuildPostings(base, merged string, probs []FsckProblem) <-chan string {
+
+	if base == "" || merged == "" || len(probs) < 1 {
+		// return closed channel so callers ranging over it do not block
+		out := make(chan string)
+		close(out)
+		return out
+	}
+
+	contents, err := os.ReadDir(merged)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to read merged directory '%s'\n", merged)
+		os.Exit(1)
+	}
+
+	var prefixes []string
+	for _, item := range contents {
+		name := item.Name()
+		if strings.HasSuffix(name, ".mrg.gz") {
+			prefixes = append(prefixes, strings.TrimSuffix(name, ".mrg.gz"))
+		}
+	}
+
+	// merged files are named by a shorter term prefix, use the longest one that matches
+	findMerged := func(key string) string {
+		best := ""
+		for _, pfx := range prefixes {
+			if strings.HasPrefix(key, pfx) && len(pfx) > len(best) {
+				best = pfx
+			}
+		}
+		if best == "" {
+			return ""
+		}
+		return filepath.Join(merged, best+".mrg.gz")
+	}
+
+	type fieldKind struct {
+		field  string
+		isLink bool
+	}
+
+	groups := make(map[fieldKind][]string)
+	seen := make(map[string]bool)
+
+	for _, prob := range probs {
+
+		if prob.Field == "" || prob.Ident == "" {
+			continue
+		}
+
+		fpath := findMerged(prob.Ident)
+		if fpath == "" {
+			fmt.Fprintf(os.Stderr, "No merged file for %s.%s\n", prob.Ident, prob.Field)
+			continue
+		}
+
+		dpath := filepath.ToSlash(filepath.Dir(prob.Path))
+		trie, _ := PostingsTrie(prob.Ident)
+		link, _ := LinksTrie(prob.Ident, false)
+		isLink := !strings.HasSuffix(dpath, prob.Field+"/"+trie) && strings.HasSuffix(dpath, prob.Field+"/"+link)
+
+		fk := fieldKind{field: prob.Field, isLink: isLink}
+		if seen[prob.Field+"\t"+fpath] {
+			continue
+		}
+		seen[prob.Field+"\t"+fpath] = true
+
+		groups[fk] = append(groups[fk], fpath)
+	}
+
+	out := make(chan string, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create rebuild channel\n")
+		os.Exit(1)
+	}
+
+	go func() {
+
+		defer close(out)
+
+		// promote one field at a time, forwarding regenerated prefixes
+		for fk, files := range groups {
+
+			prmq := CreatePromoters(base, fk.field, fk.isLink, files)
+			if prmq == nil {
+				continue
+			}
+
+			for str := range prmq {
+				out <- fk.field + "\t" + str
+			}
+		}
+	}()
+
+	return out
+}
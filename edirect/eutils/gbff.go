@@ -37,6 +37,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 )
 
 // GenBankConverter reads flatfiles and sends INSDSeq XML records down a channel
@@ -1041,3 +1042,386 @@ func CreateGBConverters(inp <-chan string) <-chan string {
 	return out
 }
 */
+
+// INSDSEQ XML TO GENBANK FLATFILE
+
+// qualifiers whose values are written without surrounding double quotes
+var unquotedQualifiers = map[string]bool{
+	"anticodon":        true,
+	"citation":         true,
+	"codon_start":      true,
+	"compare":          true,
+	"direction":        true,
+	"estimated_length": true,
+	"mod_base":         true,
+	"number":           true,
+	"rpt_type":         true,
+	"rpt_unit_range":   true,
+	"tag_peptide":      true,
+	"transl_except":    true,
+	"transl_table":     true,
+}
+
+// qualifiers that GenBankConverter joins without spaces, so may be split anywhere
+var unspacedQualifiers = map[string]bool{
+	"anticodon":     true,
+	"peptide":       true,
+	"transcription": true,
+	"translation":   true,
+}
+
+// wrapFlatfileLine splits text into lines no wider than 79 characters. The first line starts
+// with lead, continuation lines with indent spaces. Breaks are made at spaces, or after commas
+// in locations, unless anywhere is set, which allows a break at any character.
+func wrapFlatfileLine(lead, text string, indent int, anywhere bool) string {
+
+	const maxWidth = 79
+
+	pad := strings.Repeat(" ", indent)
+	if len(lead) < indent {
+		lead += strings.Repeat(" ", indent-len(lead))
+	}
+
+	room := maxWidth - indent
+
+	var buffer strings.Builder
+
+	pfx := lead
+	for {
+		if len(text) <= room {
+			buffer.WriteString(pfx)
+			buffer.WriteString(text)
+			buffer.WriteString("\n")
+			break
+		}
+
+		brk := room
+		skip := 0
+
+		if !anywhere {
+			// prefer last space that fits, then last comma
+			if pos := strings.LastIndex(text[:room+1], " "); pos > 0 {
+				brk = pos
+				skip = 1
+			} else if pos := strings.LastIndex(text[:room], ","); pos > 0 {
+				brk = pos + 1
+			}
+		}
+
+		buffer.WriteString(pfx)
+		buffer.WriteString(strings.TrimRight(text[:brk], " "))
+		buffer.WriteString("\n")
+
+		text = text[brk+skip:]
+		pfx = pad
+	}
+
+	return buffer.String()
+}
+
+// xmlFirstChild returns the first child node with the given name
+func xmlFirstChild(node *XMLNode, name string) *XMLNode {
+
+	if node == nil {
+		return nil
+	}
+	for chld := node.Children; chld != nil; chld = chld.Next {
+		if chld.Name == name {
+			return chld
+		}
+	}
+	return nil
+}
+
+// xmlChildText returns the unescaped contents of the named child node
+func xmlChildText(node *XMLNode, name string) string {
+
+	chld := xmlFirstChild(node, name)
+	if chld == nil {
+		return ""
+	}
+	return html.UnescapeString(chld.Contents)
+}
+
+// xmlEachChild visits every child node with the given name
+func xmlEachChild(node *XMLNode, name string, proc func(*XMLNode)) {
+
+	if node == nil {
+		return
+	}
+	for chld := node.Children; chld != nil; chld = chld.Next {
+		if chld.Name == name {
+			proc(chld)
+		}
+	}
+}
+
+// INSDSeqToGenBank converts one INSDSeq XML record to a GenBank or GenPept flatfile
+func INSDSeqToGenBank(text string) string {
+
+	pat := ParseRecord(text, "INSDSeq")
+	if pat == nil {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	const twelvespaces = "            "
+	const twentyonespaces = "                     "
+
+	locus := xmlChildText(pat, "INSDSeq_locus")
+	length := xmlChildText(pat, "INSDSeq_length")
+	moltype := xmlChildText(pat, "INSDSeq_moltype")
+	strandedness := xmlChildText(pat, "INSDSeq_strandedness")
+	topology := xmlChildText(pat, "INSDSeq_topology")
+	division := xmlChildText(pat, "INSDSeq_division")
+	date := xmlChildText(pat, "INSDSeq_update-date")
+
+	isProt := (moltype == "AA")
+
+	// LOCUS line has fixed columns, with bp or aa ending at column 43
+	gap := 40 - 12 - len(locus) - len(length)
+	if gap < 1 {
+		gap = 1
+	}
+	buffer.WriteString("LOCUS       ")
+	buffer.WriteString(locus)
+	buffer.WriteString(strings.Repeat(" ", gap))
+	buffer.WriteString(length)
+	if isProt {
+		buffer.WriteString(" aa            ")
+	} else {
+		// strandedness prefix only when it differs from the default for the molecule type
+		strand := ""
+		if strandedness == "mixed" {
+			strand = "ms-"
+		} else if strandedness == "single" && strings.HasSuffix(moltype, "DNA") {
+			strand = "ss-"
+		} else if strandedness == "double" && strings.HasSuffix(moltype, "RNA") {
+			strand = "ds-"
+		}
+		buffer.WriteString(fmt.Sprintf(" bp %3s%-6s  ", strand, moltype))
+	}
+	buffer.WriteString(fmt.Sprintf("%-8s %s %s\n", topology, division, date))
+
+	if def := xmlChildText(pat, "INSDSeq_definition"); def != "" {
+		if !strings.HasSuffix(def, ".") {
+			def += "."
+		}
+		buffer.WriteString(wrapFlatfileLine("DEFINITION", def, 12, false))
+	}
+
+	accns := xmlChildText(pat, "INSDSeq_primary-accession")
+	xmlEachChild(xmlFirstChild(pat, "INSDSeq_secondary-accessions"), "INSDSecondary-accn", func(node *XMLNode) {
+		accns += " " + html.UnescapeString(node.Contents)
+	})
+	if accns != "" {
+		buffer.WriteString(wrapFlatfileLine("ACCESSION", accns, 12, false))
+	}
+
+	if accnver := xmlChildText(pat, "INSDSeq_accession-version"); accnver != "" {
+		gi := ""
+		xmlEachChild(xmlFirstChild(pat, "INSDSeq_other-seqids"), "INSDSeqid", func(node *XMLNode) {
+			if strings.HasPrefix(node.Contents, "gi|") {
+				gi = strings.TrimPrefix(node.Contents, "gi|")
+			}
+		})
+		if gi != "" {
+			buffer.WriteString("VERSION     " + accnver + "  GI:" + gi + "\n")
+		} else {
+			buffer.WriteString("VERSION     " + accnver + "\n")
+		}
+	}
+
+	lead := "DBLINK"
+	xmlEachChild(xmlFirstChild(pat, "INSDSeq_xrefs"), "INSDXref", func(node *XMLNode) {
+		buffer.WriteString(wrapFlatfileLine(lead, xmlChildText(node, "INSDXref_dbname")+": "+xmlChildText(node, "INSDXref_id"), 12, false))
+		lead = ""
+	})
+
+	if srcdb := xmlChildText(pat, "INSDSeq_source-db"); srcdb != "" {
+		buffer.WriteString(wrapFlatfileLine("DBSOURCE", srcdb, 12, false))
+	}
+
+	var kywds []string
+	xmlEachChild(xmlFirstChild(pat, "INSDSeq_keywords"), "INSDKeyword", func(node *XMLNode) {
+		kywds = append(kywds, html.UnescapeString(node.Contents))
+	})
+	buffer.WriteString(wrapFlatfileLine("KEYWORDS", strings.Join(kywds, "; ")+".", 12, false))
+
+	if src := xmlChildText(pat, "INSDSeq_source"); src != "" {
+		buffer.WriteString(wrapFlatfileLine("SOURCE", src, 12, false))
+	}
+	if org := xmlChildText(pat, "INSDSeq_organism"); org != "" {
+		buffer.WriteString(wrapFlatfileLine("  ORGANISM", org, 12, false))
+		if tax := xmlChildText(pat, "INSDSeq_taxonomy"); tax != "" {
+			buffer.WriteString(wrapFlatfileLine(twelvespaces, tax+".", 12, false))
+		}
+	}
+
+	unit := "bases"
+	if isProt {
+		unit = "residues"
+	}
+
+	xmlEachChild(xmlFirstChild(pat, "INSDSeq_references"), "INSDReference", func(ref *XMLNode) {
+
+		str := xmlChildText(ref, "INSDReference_reference")
+		posn := xmlChildText(ref, "INSDReference_position")
+		if posn == "sites" {
+			str += "  (sites)"
+		} else if posn != "" {
+			var arry []string
+			for _, item := range strings.Split(posn, ",") {
+				fr, to := SplitInTwoLeft(item, "..")
+				arry = append(arry, fr+" to "+to)
+			}
+			str += "  (" + unit + " " + strings.Join(arry, "; ") + ")"
+		}
+		buffer.WriteString(wrapFlatfileLine("REFERENCE", str, 12, false))
+
+		var auths []string
+		xmlEachChild(xmlFirstChild(ref, "INSDReference_authors"), "INSDAuthor", func(node *XMLNode) {
+			auths = append(auths, html.UnescapeString(node.Contents))
+		})
+		if num := len(auths); num > 0 {
+			str = auths[0]
+			if num > 1 {
+				str = strings.Join(auths[:num-1], ", ") + " and " + auths[num-1]
+			}
+			buffer.WriteString(wrapFlatfileLine("  AUTHORS", str, 12, false))
+		}
+
+		if str = xmlChildText(ref, "INSDReference_consortium"); str != "" {
+			buffer.WriteString(wrapFlatfileLine("  CONSRTM", str, 12, false))
+		}
+		if str = xmlChildText(ref, "INSDReference_title"); str != "" {
+			buffer.WriteString(wrapFlatfileLine("  TITLE", str, 12, false))
+		}
+		if str = xmlChildText(ref, "INSDReference_journal"); str != "" {
+			buffer.WriteString(wrapFlatfileLine("  JOURNAL", str, 12, false))
+		}
+		if str = xmlChildText(ref, "INSDReference_pubmed"); str != "" {
+			buffer.WriteString(wrapFlatfileLine("   PUBMED", str, 12, false))
+		}
+		if str = xmlChildText(ref, "INSDReference_remark"); str != "" {
+			buffer.WriteString(wrapFlatfileLine("  REMARK", str, 12, false))
+		}
+	})
+
+	if com := xmlChildText(pat, "INSDSeq_comment"); com != "" {
+		buffer.WriteString(wrapFlatfileLine("COMMENT", com, 12, false))
+	}
+	if pmy := xmlChildText(pat, "INSDSeq_primary"); pmy != "" {
+		buffer.WriteString(wrapFlatfileLine("PRIMARY", pmy, 12, false))
+	}
+
+	buffer.WriteString("FEATURES             Location/Qualifiers\n")
+
+	xmlEachChild(xmlFirstChild(pat, "INSDSeq_feature-table"), "INSDFeature", func(ftr *XMLNode) {
+
+		key := xmlChildText(ftr, "INSDFeature_key")
+		loc := xmlChildText(ftr, "INSDFeature_location")
+		buffer.WriteString(wrapFlatfileLine("     "+key, loc, 21, false))
+
+		xmlEachChild(xmlFirstChild(ftr, "INSDFeature_quals"), "INSDQualifier", func(qual *XMLNode) {
+
+			name := xmlChildText(qual, "INSDQualifier_name")
+			if xmlFirstChild(qual, "INSDQualifier_value") == nil {
+				// flag qualifiers such as /pseudo have no value
+				buffer.WriteString(twentyonespaces + "/" + name + "\n")
+				return
+			}
+			val := xmlChildText(qual, "INSDQualifier_value")
+			if !unquotedQualifiers[name] {
+				val = "\"" + val + "\""
+			}
+			buffer.WriteString(wrapFlatfileLine(twentyonespaces, "/"+name+"="+val, 21, unspacedQualifiers[name]))
+		})
+	})
+
+	if con := xmlChildText(pat, "INSDSeq_contig"); con != "" {
+		buffer.WriteString(wrapFlatfileLine("CONTIG", con, 12, true))
+	}
+
+	if seq := xmlChildText(pat, "INSDSeq_sequence"); seq != "" {
+
+		buffer.WriteString("ORIGIN      \n")
+
+		seq = strings.ToLower(seq)
+		max := len(seq)
+
+		// 60 residues per line in blocks of 10, preceded by right-justified position
+		for i := 0; i < max; i += 60 {
+			buffer.WriteString(fmt.Sprintf("%9d", i+1))
+			for j := i; j < i+60 && j < max; j += 10 {
+				end := j + 10
+				if end > max {
+					end = max
+				}
+				buffer.WriteString(" ")
+				buffer.WriteString(seq[j:end])
+			}
+			buffer.WriteString("\n")
+		}
+	}
+
+	buffer.WriteString("//\n")
+
+	return buffer.String()
+}
+
+// CreateGenBankWriters runs multiple INSDSeq to flatfile conversion go routines
+func CreateGenBankWriters(inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create flatfile writer channel\n")
+		os.Exit(1)
+	}
+
+	// gbWriter reads partitioned INSDSeq XML from channel and converts on a per-record basis
+	gbWriter := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// report when this writer has no more records to process
+		defer wg.Done()
+
+		for ext := range inp {
+
+			idx := ext.Index
+			ident := ext.Ident
+			text := ext.Text
+
+			if text == "" {
+				// should never see empty input data
+				out <- XMLRecord{Index: idx, Ident: ident, Text: text}
+				continue
+			}
+
+			str := INSDSeqToGenBank(text)
+
+			// send even if empty to get all record counts for reordering
+			out <- XMLRecord{Index: idx, Ident: ident, Text: str}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple writer goroutines
+	for i := 0; i < numServe; i++ {
+		wg.Add(1)
+		go gbWriter(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all writers are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
@@ -1054,3 +1054,132 @@ func GenerateGeneticCodeMaps() {
 		}
 	*/
 }
+
+// OPEN READING FRAME FINDER
+
+// OpenReadingFrame describes one ORF, with Start and Stop as 0-based top strand positions
+// of the first base of the start codon and the last base of the stop codon, so Start is
+// greater than Stop for minus strand ORFs
+type OpenReadingFrame struct {
+	Start   int
+	Stop    int
+	Strand  string
+	Frame   int
+	Protein string
+}
+
+// FindORFs uses the codon state machine to scan both strands simultaneously, reporting
+// the longest ORF between consecutive stop codons in each of the six frames
+func FindORFs(seq string, genCode, minLength int, altStarts, topStrandOnly bool) []OpenReadingFrame {
+
+	genCode = correctGenCode(genCode)
+
+	seq = strings.ToUpper(seq)
+	length := len(seq)
+
+	isStart := func(state int) bool {
+		if altStarts {
+			return IsOrfStart(genCode, state)
+		}
+		return IsATGStart(genCode, state)
+	}
+
+	var orfs []OpenReadingFrame
+
+	// plus strand records first start codon after previous stop in each frame
+	plusStart := []int{-1, -1, -1}
+
+	// minus strand records first base after previous stop, and last start codon seen since then
+	minusAfter := []int{-1, -1, -1}
+	minusStart := []int{-1, -1, -1}
+
+	addORF := func(left, right int, strand string) {
+
+		// length excludes stop codon
+		if (right-left+1)/3-1 < minLength {
+			return
+		}
+
+		cds := seq[left : right+1]
+		orf := OpenReadingFrame{Strand: strand}
+
+		if strand == "-" {
+			cds = ReverseComplement(cds)
+			orf.Start = right
+			orf.Stop = left
+			orf.Frame = (length-1-right)%3 + 1
+		} else {
+			orf.Start = left
+			orf.Stop = right
+			orf.Frame = left%3 + 1
+		}
+
+		// translating as 5' complete converts alternative start codon to methionine
+		orf.Protein = TranslateCdRegion(cds, genCode, 0, false, false, false, true, true, "")
+
+		orfs = append(orfs, orf)
+	}
+
+	// minus strand ORF runs from previous stop codon to rightmost start codon
+	closeMinus := func(frm int) {
+
+		if minusAfter[frm] >= 3 && minusStart[frm] >= 0 {
+			addORF(minusAfter[frm]-3, minusStart[frm], "-")
+		}
+		minusStart[frm] = -1
+	}
+
+	state := 0
+
+	for i := 0; i < length; i++ {
+
+		state = NextCodonState(state, int(seq[i]))
+		if i < 2 {
+			continue
+		}
+
+		// codon occupies positions i-2 through i
+		frm := (i - 2) % 3
+
+		if IsOrfStop(genCode, state) {
+			if plusStart[frm] >= 0 {
+				addORF(plusStart[frm], i, "+")
+				plusStart[frm] = -1
+			}
+		} else if plusStart[frm] < 0 && isStart(state) {
+			plusStart[frm] = i - 2
+		}
+
+		if topStrandOnly {
+			continue
+		}
+
+		rev := RevCompState(state)
+
+		if IsOrfStop(genCode, rev) {
+			closeMinus(frm)
+			minusAfter[frm] = i + 1
+		} else if isStart(rev) {
+			minusStart[frm] = i
+		}
+	}
+
+	// plus strand starts without a stop codon are incomplete, but last minus strand starts are not
+	for frm := 0; frm < 3; frm++ {
+		closeMinus(frm)
+	}
+
+	sort.SliceStable(orfs, func(i, j int) bool {
+		lfi := orfs[i].Start
+		if orfs[i].Stop < lfi {
+			lfi = orfs[i].Stop
+		}
+		lfj := orfs[j].Start
+		if orfs[j].Stop < lfj {
+			lfj = orfs[j].Stop
+		}
+		return lfi < lfj
+	})
+
+	return orfs
+}
@@ -0,0 +1,1247 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  gff.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FEATURE TABLE EXPORT AS GFF3 OR BED12
+
+// insdExon is one interval of a feature location, with 1-based inclusive coordinates
+type insdExon struct {
+	Start  int
+	Stop   int
+	Minus  bool
+	Length int
+}
+
+// insdQual is one feature qualifier, a flag qualifier has an empty value
+type insdQual struct {
+	Name  string
+	Value string
+}
+
+// insdFeat holds the parts of an INSDFeature needed for tabular export
+type insdFeat struct {
+	Key      string
+	Exons    []insdExon
+	Quals    []insdQual
+	Partial5 bool
+	Partial3 bool
+}
+
+// LegalFeatureKey checks a name against the GenBank feature vocabulary, returning the properly capitalized key
+func LegalFeatureKey(str string) (string, bool) {
+
+	for _, txt := range insdFeatureKeys {
+		if strings.EqualFold(str, txt) {
+			return txt, true
+		}
+	}
+
+	return "", false
+}
+
+// ParseFeatureKeys splits a comma- or plus-separated list of feature keys, exiting on an unrecognized key
+func ParseFeatureKeys(str string) map[string]bool {
+
+	keys := make(map[string]bool)
+
+	str = strings.Replace(str, "+", ",", -1)
+	for _, key := range strings.Split(str, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		legal, ok := LegalFeatureKey(key)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "\nERROR: Item '%s' is not a legal feature key\n", key)
+			os.Exit(1)
+		}
+		keys[legal] = true
+	}
+
+	return keys
+}
+
+// parseINSDFeatures collects the local intervals and qualifiers of each feature in an INSDSeq record
+func parseINSDFeatures(seq *XMLNode, keys map[string]bool) []insdFeat {
+
+	var feats []insdFeat
+
+	accn := xmlChildText(seq, "INSDSeq_accession-version")
+	if accn == "" {
+		accn = xmlChildText(seq, "INSDSeq_primary-accession")
+	}
+
+	xmlEachChild(xmlFirstChild(seq, "INSDSeq_feature-table"), "INSDFeature", func(ftr *XMLNode) {
+
+		key := xmlChildText(ftr, "INSDFeature_key")
+		if key == "" {
+			return
+		}
+		if len(keys) > 0 && !keys[key] {
+			return
+		}
+
+		feat := insdFeat{Key: key}
+
+		xmlEachChild(xmlFirstChild(ftr, "INSDFeature_intervals"), "INSDInterval", func(intv *XMLNode) {
+
+			// skip intervals on other sequences
+			itvl := xmlChildText(intv, "INSDInterval_accession")
+			if itvl != "" && accn != "" && itvl != accn {
+				return
+			}
+
+			fr, to := 0, 0
+			if pt := xmlChildText(intv, "INSDInterval_point"); pt != "" {
+				fr, _ = strconv.Atoi(pt)
+				to = fr
+			} else {
+				fr, _ = strconv.Atoi(xmlChildText(intv, "INSDInterval_from"))
+				to, _ = strconv.Atoi(xmlChildText(intv, "INSDInterval_to"))
+			}
+			if fr < 1 || to < 1 {
+				return
+			}
+
+			minus := false
+			if xmlFirstChild(intv, "INSDInterval_iscomp") != nil || fr > to {
+				minus = true
+			}
+			if fr > to {
+				fr, to = to, fr
+			}
+
+			feat.Exons = append(feat.Exons, insdExon{Start: fr, Stop: to, Minus: minus, Length: to - fr + 1})
+		})
+
+		if len(feat.Exons) < 1 {
+			return
+		}
+
+		if xmlFirstChild(ftr, "INSDFeature_partial5") != nil {
+			feat.Partial5 = true
+		}
+		if xmlFirstChild(ftr, "INSDFeature_partial3") != nil {
+			feat.Partial3 = true
+		}
+
+		xmlEachChild(xmlFirstChild(ftr, "INSDFeature_quals"), "INSDQualifier", func(qual *XMLNode) {
+			name := xmlChildText(qual, "INSDQualifier_name")
+			if name == "" {
+				return
+			}
+			feat.Quals = append(feat.Quals, insdQual{Name: name, Value: xmlChildText(qual, "INSDQualifier_value")})
+		})
+
+		feats = append(feats, feat)
+	})
+
+	return feats
+}
+
+// featureName picks a human-readable label for a feature
+func featureName(feat insdFeat) string {
+
+	for _, pref := range []string{"gene", "locus_tag", "product", "protein_id", "transcript_id", "note"} {
+		for _, qual := range feat.Quals {
+			if qual.Name == pref && qual.Value != "" {
+				return qual.Value
+			}
+		}
+	}
+
+	return feat.Key
+}
+
+// featureStrand returns "-" for complemented locations and "+" otherwise
+func featureStrand(feat insdFeat) string {
+
+	for _, exon := range feat.Exons {
+		if !exon.Minus {
+			return "+"
+		}
+	}
+
+	return "-"
+}
+
+// featureExtent returns the lowest and highest positions covered by a feature
+func featureExtent(feat insdFeat) (int, int) {
+
+	min, max := feat.Exons[0].Start, feat.Exons[0].Stop
+	for _, exon := range feat.Exons {
+		if exon.Start < min {
+			min = exon.Start
+		}
+		if exon.Stop > max {
+			max = exon.Stop
+		}
+	}
+
+	return min, max
+}
+
+// sortedExons returns the intervals in ascending coordinate order
+func sortedExons(feat insdFeat) []insdExon {
+
+	exons := make([]insdExon, len(feat.Exons))
+	copy(exons, feat.Exons)
+	sort.SliceStable(exons, func(i, j int) bool { return exons[i].Start < exons[j].Start })
+
+	return exons
+}
+
+// escapeGFF percent-encodes characters with special meaning in GFF3 column 9
+func escapeGFF(str string) string {
+
+	var buffer strings.Builder
+
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+		switch ch {
+		case ';', '=', '&', ',', '%', '\t', '\n', '\r':
+			buffer.WriteString(fmt.Sprintf("%%%02X", ch))
+		default:
+			if ch < ' ' || ch == 0x7F {
+				buffer.WriteString(fmt.Sprintf("%%%02X", ch))
+			} else {
+				buffer.WriteByte(ch)
+			}
+		}
+	}
+
+	return buffer.String()
+}
+
+// INSDSeqToGFF writes the features of one INSDSeq record as GFF3 rows
+func INSDSeqToGFF(text string, keys map[string]bool) string {
+
+	seq := ParseRecord(text, "INSDSeq")
+	if seq == nil {
+		return ""
+	}
+
+	seqid := xmlChildText(seq, "INSDSeq_accession-version")
+	if seqid == "" {
+		seqid = xmlChildText(seq, "INSDSeq_primary-accession")
+	}
+	if seqid == "" {
+		seqid = xmlChildText(seq, "INSDSeq_locus")
+	}
+	if seqid == "" {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	if length := xmlChildText(seq, "INSDSeq_length"); length != "" {
+		buffer.WriteString("##sequence-region " + escapeGFF(seqid) + " 1 " + length + "\n")
+	}
+
+	seqid = escapeGFF(seqid)
+
+	counts := make(map[string]int)
+
+	writeRow := func(typ string, start, stop int, strand, phase, attrs string) {
+		buffer.WriteString(seqid)
+		buffer.WriteString("\tINSD\t")
+		buffer.WriteString(typ)
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(start))
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(stop))
+		buffer.WriteString("\t.\t")
+		buffer.WriteString(strand)
+		buffer.WriteString("\t")
+		buffer.WriteString(phase)
+		buffer.WriteString("\t")
+		buffer.WriteString(attrs)
+		buffer.WriteString("\n")
+	}
+
+	for _, feat := range parseINSDFeatures(seq, keys) {
+
+		// source feature becomes the GFF3 region type
+		typ := feat.Key
+		if typ == "source" {
+			typ = "region"
+		}
+
+		counts[typ]++
+		id := escapeGFF(typ + "-" + strconv.Itoa(counts[typ]))
+
+		// build attribute column, combining repeated qualifiers into one tag
+		var attrs strings.Builder
+		attrs.WriteString("ID=" + id)
+
+		name := featureName(feat)
+		if name != feat.Key {
+			attrs.WriteString(";Name=" + escapeGFF(name))
+		}
+
+		var order []string
+		vals := make(map[string][]string)
+		for _, qual := range feat.Quals {
+			if _, ok := vals[qual.Name]; !ok {
+				order = append(order, qual.Name)
+			}
+			val := qual.Value
+			if val == "" {
+				val = "true"
+			}
+			vals[qual.Name] = append(vals[qual.Name], escapeGFF(val))
+		}
+		for _, tag := range order {
+			tg := tag
+			// avoid collision with reserved attributes, which start with an uppercase letter
+			if tg == "ID" || tg == "Name" || tg == "Parent" {
+				tg = strings.ToLower(tg)
+			}
+			attrs.WriteString(";" + escapeGFF(tg) + "=" + strings.Join(vals[tag], ","))
+		}
+		if feat.Partial5 || feat.Partial3 {
+			attrs.WriteString(";partial=true")
+		}
+
+		strand := featureStrand(feat)
+		exons := sortedExons(feat)
+
+		if len(exons) == 1 && typ != "CDS" {
+			writeRow(typ, exons[0].Start, exons[0].Stop, strand, ".", attrs.String())
+			continue
+		}
+
+		if typ == "CDS" {
+
+			// compute phase of each segment in biological order, starting from codon_start
+			phase := 0
+			for _, qual := range feat.Quals {
+				if qual.Name == "codon_start" {
+					if cs, err := strconv.Atoi(qual.Value); err == nil && cs > 1 && cs < 4 {
+						phase = cs - 1
+					}
+				}
+			}
+			phases := make(map[insdExon]int)
+			for _, exon := range feat.Exons {
+				phases[exon] = phase
+				phase = (3 - ((exon.Length - phase) % 3)) % 3
+			}
+
+			// multi-exon CDS rows share one ID
+			for _, exon := range exons {
+				writeRow(typ, exon.Start, exon.Stop, strand, strconv.Itoa(phases[exon]), attrs.String())
+			}
+			continue
+		}
+
+		// joined location becomes a spanning parent row with exon children
+		min, max := featureExtent(feat)
+		writeRow(typ, min, max, strand, ".", attrs.String())
+
+		for _, exon := range exons {
+			counts["exon"]++
+			eid := escapeGFF("exon-" + strconv.Itoa(counts["exon"]))
+			writeRow("exon", exon.Start, exon.Stop, strand, ".", "ID="+eid+";Parent="+id)
+		}
+	}
+
+	return buffer.String()
+}
+
+// INSDSeqToBED writes the features of one INSDSeq record as BED12 rows
+func INSDSeqToBED(text string, keys map[string]bool) string {
+
+	seq := ParseRecord(text, "INSDSeq")
+	if seq == nil {
+		return ""
+	}
+
+	chrom := xmlChildText(seq, "INSDSeq_accession-version")
+	if chrom == "" {
+		chrom = xmlChildText(seq, "INSDSeq_primary-accession")
+	}
+	if chrom == "" {
+		chrom = xmlChildText(seq, "INSDSeq_locus")
+	}
+	if chrom == "" {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	for _, feat := range parseINSDFeatures(seq, keys) {
+
+		if feat.Key == "source" {
+			continue
+		}
+
+		// BED uses 0-based half-open coordinates
+		min, max := featureExtent(feat)
+		start := min - 1
+		stop := max
+
+		// only coding regions have a thick part
+		thickStart, thickEnd := start, start
+		if feat.Key == "CDS" {
+			thickEnd = stop
+		}
+
+		exons := sortedExons(feat)
+
+		var sizes []string
+		var starts []string
+		for _, exon := range exons {
+			sizes = append(sizes, strconv.Itoa(exon.Length))
+			starts = append(starts, strconv.Itoa(exon.Start-min))
+		}
+
+		name := strings.Replace(featureName(feat), "\t", " ", -1)
+		name = strings.Replace(name, " ", "_", -1)
+
+		buffer.WriteString(chrom)
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(start))
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(stop))
+		buffer.WriteString("\t")
+		buffer.WriteString(name)
+		buffer.WriteString("\t0\t")
+		buffer.WriteString(featureStrand(feat))
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(thickStart))
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(thickEnd))
+		buffer.WriteString("\t0\t")
+		buffer.WriteString(strconv.Itoa(len(exons)))
+		buffer.WriteString("\t")
+		buffer.WriteString(strings.Join(sizes, ",") + ",")
+		buffer.WriteString("\t")
+		buffer.WriteString(strings.Join(starts, ",") + ",")
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
+
+// CreateFeatureExporters runs concurrent INSDSeq to GFF3 or BED12 converters
+func CreateFeatureExporters(asBED bool, keys map[string]bool, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	convert := INSDSeqToGFF
+	if asBED {
+		convert = INSDSeqToBED
+	}
+
+	return createINSDSeqConverters("feature exporter", func(text string) string { return convert(text, keys) }, inp)
+}
+
+// createINSDSeqConverters runs concurrent goroutines that each turn one INSDSeq record into text
+func createINSDSeqConverters(name string, convert func(string) string, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create %s channel\n", name)
+		os.Exit(1)
+	}
+
+	// convertRecords reads partitioned XML from channel and writes converted results
+	convertRecords := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// report when this converter has no more records to process
+		defer wg.Done()
+
+		for ext := range inp {
+
+			idx := ext.Index
+			ident := ext.Ident
+			text := ext.Text
+
+			if text == "" {
+				// should never see empty input data
+				out <- XMLRecord{Index: idx, Ident: ident, Text: text}
+				continue
+			}
+
+			str := convert(text)
+
+			// send even if empty to get all record counts for reordering
+			out <- XMLRecord{Index: idx, Ident: ident, Text: str}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple converter goroutines
+	for i := 0; i < numServe; i++ {
+		wg.Add(1)
+		go convertRecords(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all converters are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// GFF3 OR GTF IMPORT TO INSDSEQ XML
+
+// gffNode is one feature assembled from GFF3 or GTF rows that share an identifier
+type gffNode struct {
+	ID       string
+	Type     string
+	Strand   string
+	Parents  []string
+	Exons    []insdExon
+	Phases   []int
+	Attrs    []insdQual
+	Children []*gffNode
+	Partial5 bool
+	Partial3 bool
+}
+
+// gffSeq collects the features and optional sequence for one seqid
+type gffSeq struct {
+	Seqid    string
+	Length   int
+	Sequence strings.Builder
+	Nodes    []*gffNode
+	ByID     map[string]*gffNode
+}
+
+// gffTypeToKey maps Sequence Ontology terms to GenBank feature keys
+var gffTypeToKey = map[string]string{
+	"region":                 "source",
+	"transcript":             "mRNA",
+	"primary_transcript":     "precursor_RNA",
+	"pseudogene":             "gene",
+	"pseudogenic_transcript": "misc_RNA",
+	"lnc_RNA":                "ncRNA",
+	"lncRNA":                 "ncRNA",
+	"snRNA":                  "ncRNA",
+	"snoRNA":                 "ncRNA",
+	"miRNA":                  "ncRNA",
+	"antisense_RNA":          "ncRNA",
+	"RNase_P_RNA":            "ncRNA",
+	"five_prime_UTR":         "5'UTR",
+	"three_prime_UTR":        "3'UTR",
+	"UTR":                    "misc_feature",
+	"start_codon":            "",
+	"stop_codon":             "",
+	"Selenocysteine":         "",
+}
+
+// gffSkipAttrs are GFF3 reserved or GTF bookkeeping attributes that do not become qualifiers
+var gffSkipAttrs = map[string]bool{
+	"ID":            true,
+	"Parent":        true,
+	"Name":          true,
+	"Alias":         true,
+	"Target":        true,
+	"Gap":           true,
+	"Derives_from":  true,
+	"Is_circular":   true,
+	"Ontology_term": true,
+	"start_range":   true,
+	"end_range":     true,
+	"partial":       true,
+	"gene_id":       true,
+	"gene_name":     true,
+	"exon_number":   true,
+	"exon_id":       true,
+	"gbkey":         true,
+}
+
+// parseGFFAttributes splits column 9 of GFF3 (tag=value;...) or GTF (tag "value"; ...) into qualifiers
+func parseGFFAttributes(str string) ([]insdQual, bool) {
+
+	var attrs []insdQual
+
+	isGTF := false
+
+	for _, item := range strings.Split(str, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" || item == "." {
+			continue
+		}
+
+		if eq := strings.Index(item, "="); eq > 0 && !strings.Contains(item[:eq], " ") {
+			// GFF3 attribute, values are percent-encoded and may be comma-separated
+			tag := item[:eq]
+			for _, val := range strings.Split(item[eq+1:], ",") {
+				if dec, err := url.PathUnescape(val); err == nil {
+					val = dec
+				}
+				attrs = append(attrs, insdQual{Name: tag, Value: val})
+			}
+			continue
+		}
+
+		// GTF attribute, value is usually quoted
+		isGTF = true
+		tag, val := SplitInTwoLeft(item, " ")
+		val = strings.TrimSpace(val)
+		val = strings.TrimPrefix(val, "\"")
+		val = strings.TrimSuffix(val, "\"")
+		attrs = append(attrs, insdQual{Name: tag, Value: val})
+	}
+
+	return attrs, isGTF
+}
+
+// gffAttr returns the first value of the named attribute
+func gffAttr(attrs []insdQual, name string) string {
+
+	for _, attr := range attrs {
+		if attr.Name == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// gffLocation builds a GenBank location string and interval list from sorted exons
+func gffLocation(node *gffNode) string {
+
+	var parts []string
+
+	for i, exon := range node.Exons {
+		lft := strconv.Itoa(exon.Start)
+		rgt := strconv.Itoa(exon.Stop)
+		// partial flags refer to the biological ends, which swap on the minus strand
+		if i == 0 && ((node.Partial5 && node.Strand != "-") || (node.Partial3 && node.Strand == "-")) {
+			lft = "<" + lft
+		}
+		if i == len(node.Exons)-1 && ((node.Partial3 && node.Strand != "-") || (node.Partial5 && node.Strand == "-")) {
+			rgt = ">" + rgt
+		}
+		if exon.Start == exon.Stop {
+			parts = append(parts, lft)
+		} else {
+			parts = append(parts, lft+".."+rgt)
+		}
+	}
+
+	loc := strings.Join(parts, ",")
+	if len(parts) > 1 {
+		loc = "join(" + loc + ")"
+	}
+	if node.Strand == "-" {
+		loc = "complement(" + loc + ")"
+	}
+
+	return loc
+}
+
+// mergeExons sorts intervals and combines those that overlap or abut, keeping the phase of the leftmost piece
+func mergeExons(exons []insdExon, phases []int) ([]insdExon, []int) {
+
+	if len(exons) < 2 {
+		return exons, phases
+	}
+
+	idx := make([]int, len(exons))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return exons[idx[i]].Start < exons[idx[j]].Start })
+
+	var mxons []insdExon
+	var mphas []int
+
+	for _, i := range idx {
+		exon := exons[i]
+		phase := -1
+		if i < len(phases) {
+			phase = phases[i]
+		}
+		last := len(mxons) - 1
+		if last >= 0 && exon.Start <= mxons[last].Stop+1 {
+			if exon.Stop > mxons[last].Stop {
+				mxons[last].Stop = exon.Stop
+				mxons[last].Length = mxons[last].Stop - mxons[last].Start + 1
+			}
+			// minus strand phase belongs to the rightmost piece
+			if exon.Minus && phase >= 0 {
+				mphas[last] = phase
+			}
+			continue
+		}
+		mxons = append(mxons, exon)
+		mphas = append(mphas, phase)
+	}
+
+	return mxons, mphas
+}
+
+// GFFtoINSDSeq reads GFF3 or GTF annotation and sends one INSDSeq record per seqid
+func GFFtoINSDSeq(inp io.Reader) <-chan string {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan string, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create GFF to XML converter channel\n")
+		os.Exit(1)
+	}
+
+	convertGFF := func(inp io.Reader, out chan<- string) {
+
+		// close channel when all records have been sent
+		defer close(out)
+
+		var seqs []*gffSeq
+		seqMap := make(map[string]*gffSeq)
+
+		getSeq := func(seqid string) *gffSeq {
+			sq, ok := seqMap[seqid]
+			if !ok {
+				sq = &gffSeq{Seqid: seqid, ByID: make(map[string]*gffNode)}
+				seqMap[seqid] = sq
+				seqs = append(seqs, sq)
+			}
+			return sq
+		}
+
+		// getNode finds or creates a feature, rows with the same identifier are merged
+		getNode := func(sq *gffSeq, id, typ, strand string) *gffNode {
+			if id != "" {
+				if node, ok := sq.ByID[id]; ok {
+					if node.Type == "" {
+						node.Type = typ
+					}
+					if node.Strand == "" || node.Strand == "." {
+						node.Strand = strand
+					}
+					return node
+				}
+			}
+			node := &gffNode{ID: id, Type: typ, Strand: strand}
+			if id != "" {
+				sq.ByID[id] = node
+			}
+			sq.Nodes = append(sq.Nodes, node)
+			return node
+		}
+
+		scanr := bufio.NewScanner(inp)
+		scanr.Buffer(make([]byte, 0, 65536), 64*1024*1024)
+
+		inFasta := false
+		var fsa *gffSeq
+		anon := 0
+
+		for scanr.Scan() {
+
+			line := strings.TrimRight(scanr.Text(), "\r")
+			if line == "" {
+				continue
+			}
+
+			// trailing sequence section
+			if inFasta {
+				if strings.HasPrefix(line, ">") {
+					id := strings.TrimSpace(line[1:])
+					if sp := strings.IndexAny(id, " \t"); sp > 0 {
+						id = id[:sp]
+					}
+					fsa = getSeq(id)
+					fsa.Sequence.Reset()
+				} else if fsa != nil {
+					fsa.Sequence.WriteString(strings.ToLower(strings.TrimSpace(line)))
+				}
+				continue
+			}
+
+			if strings.HasPrefix(line, "#") {
+				if strings.HasPrefix(line, "##FASTA") {
+					inFasta = true
+				} else if strings.HasPrefix(line, "##sequence-region") {
+					flds := strings.Fields(line)
+					if len(flds) > 3 {
+						sq := getSeq(flds[1])
+						sq.Length, _ = strconv.Atoi(flds[3])
+					}
+				}
+				continue
+			}
+
+			cols := strings.Split(line, "\t")
+			if len(cols) < 8 {
+				continue
+			}
+
+			seqid := cols[0]
+			if dec, err := url.PathUnescape(seqid); err == nil {
+				seqid = dec
+			}
+			typ := cols[2]
+			start, err1 := strconv.Atoi(cols[3])
+			stop, err2 := strconv.Atoi(cols[4])
+			if err1 != nil || err2 != nil || start < 1 || stop < start {
+				continue
+			}
+			strand := cols[6]
+			phase := -1
+			if ph, err := strconv.Atoi(cols[7]); err == nil {
+				phase = ph
+			}
+
+			attrs := []insdQual{}
+			isGTF := false
+			if len(cols) > 8 {
+				attrs, isGTF = parseGFFAttributes(cols[8])
+			}
+
+			sq := getSeq(seqid)
+			exon := insdExon{Start: start, Stop: stop, Minus: strand == "-", Length: stop - start + 1}
+
+			var node *gffNode
+
+			if isGTF {
+
+				// GTF implies gene and transcript parents through gene_id and transcript_id
+				gid := gffAttr(attrs, "gene_id")
+				tid := gffAttr(attrs, "transcript_id")
+
+				var gene *gffNode
+				if gid != "" {
+					gene = getNode(sq, "gene:"+gid, "gene", strand)
+					if gffAttr(gene.Attrs, "gene") == "" {
+						name := gffAttr(attrs, "gene_name")
+						if name == "" {
+							name = gid
+						}
+						gene.Attrs = append(gene.Attrs, insdQual{Name: "gene", Value: name})
+					}
+				}
+
+				var tran *gffNode
+				if tid != "" {
+					tran = getNode(sq, "transcript:"+tid, "transcript", strand)
+					if gene != nil && len(tran.Parents) == 0 {
+						tran.Parents = []string{gene.ID}
+					}
+				}
+
+				switch typ {
+				case "gene":
+					node = gene
+				case "transcript", "mRNA":
+					node = tran
+				case "CDS", "start_codon", "stop_codon":
+					if tran == nil {
+						continue
+					}
+					node = getNode(sq, "cds:"+tid, "CDS", strand)
+					node.Parents = []string{tran.ID}
+					if typ != "CDS" {
+						// GTF CDS rows exclude the stop codon
+						phase = -1
+					}
+				default:
+					node = getNode(sq, "", typ, strand)
+					if tran != nil {
+						node.Parents = []string{tran.ID}
+					} else if gene != nil {
+						node.Parents = []string{gene.ID}
+					}
+				}
+				if node == nil {
+					continue
+				}
+
+				node.Exons = append(node.Exons, exon)
+				node.Phases = append(node.Phases, phase)
+
+				// keep new attributes, skipping duplicates from repeated rows
+				for _, attr := range attrs {
+					if gffSkipAttrs[attr.Name] || attr.Name == "transcript_id" && node.Type != "transcript" {
+						continue
+					}
+					if strings.HasPrefix(attr.Name, "gene_") && node.Type != "gene" {
+						continue
+					}
+					if node.Type == "gene" && attr.Name != "gene_biotype" && attr.Name != "db_xref" {
+						continue
+					}
+					if gffAttr(node.Attrs, attr.Name) == attr.Value {
+						continue
+					}
+					node.Attrs = append(node.Attrs, attr)
+				}
+				continue
+			}
+
+			// GFF3 rows with the same ID form one discontinuous feature
+			id := gffAttr(attrs, "ID")
+			if id == "" {
+				anon++
+				id = "_anon:" + strconv.Itoa(anon)
+			}
+			node = getNode(sq, id, typ, strand)
+			first := len(node.Exons) == 0
+			node.Exons = append(node.Exons, exon)
+			node.Phases = append(node.Phases, phase)
+
+			if !first {
+				continue
+			}
+
+			for _, attr := range attrs {
+				switch attr.Name {
+				case "Parent":
+					node.Parents = append(node.Parents, attr.Value)
+				case "start_range":
+					node.Partial5 = true
+				case "end_range":
+					node.Partial3 = true
+				}
+			}
+			if node.Strand == "-" {
+				// range tags are in coordinate order
+				node.Partial5, node.Partial3 = node.Partial3, node.Partial5
+			}
+			node.Attrs = attrs
+		}
+
+		for _, sq := range seqs {
+
+			// link children to parents, remember roots in file order
+			var roots []*gffNode
+			for _, node := range sq.Nodes {
+				linked := false
+				for _, pid := range node.Parents {
+					if prnt, ok := sq.ByID[pid]; ok && prnt != node {
+						prnt.Children = append(prnt.Children, node)
+						linked = true
+					}
+				}
+				if !linked {
+					roots = append(roots, node)
+				}
+			}
+
+			// GTF implied parents and exon-only transcripts have their extent computed from descendants
+			var span func(node *gffNode) (int, int)
+			span = func(node *gffNode) (int, int) {
+				min, max := 0, 0
+				for _, exon := range node.Exons {
+					if min == 0 || exon.Start < min {
+						min = exon.Start
+					}
+					if exon.Stop > max {
+						max = exon.Stop
+					}
+				}
+				for _, chld := range node.Children {
+					lf, rt := span(chld)
+					if lf > 0 && (min == 0 || lf < min) {
+						min = lf
+					}
+					if rt > max {
+						max = rt
+					}
+				}
+				return min, max
+			}
+
+			length := sq.Length
+			if sq.Sequence.Len() > 0 {
+				length = sq.Sequence.Len()
+			}
+			for _, node := range roots {
+				if _, rt := span(node); rt > length {
+					length = rt
+				}
+			}
+
+			var rec strings.Builder
+
+			accn, vers := parseFASTADefline(sq.Seqid)
+			accnver := accn
+			if vers != "" {
+				accnver = accn + "." + vers
+			}
+
+			moltype := "DNA"
+			if sq.Sequence.Len() > 0 {
+				moltype = guessMoleculeType(sq.Sequence.String())
+			}
+
+			rec.WriteString("  <INSDSeq>\n")
+
+			writeXMLElement(&rec, "    ", "INSDSeq_locus", accn)
+			writeXMLElement(&rec, "    ", "INSDSeq_length", strconv.Itoa(length))
+			writeXMLElement(&rec, "    ", "INSDSeq_moltype", moltype)
+			writeXMLElement(&rec, "    ", "INSDSeq_topology", "linear")
+			writeXMLElement(&rec, "    ", "INSDSeq_primary-accession", accn)
+			if vers != "" {
+				writeXMLElement(&rec, "    ", "INSDSeq_accession-version", accnver)
+			}
+			if sq.Seqid != accnver {
+				rec.WriteString("    <INSDSeq_other-seqids>\n")
+				writeXMLElement(&rec, "      ", "INSDSeqid", sq.Seqid)
+				rec.WriteString("    </INSDSeq_other-seqids>\n")
+			}
+
+			rec.WriteString("    <INSDSeq_feature-table>\n")
+
+			writeFeature := func(node *gffNode, key, gene string) {
+
+				rec.WriteString("      <INSDFeature>\n")
+				writeXMLElement(&rec, "        ", "INSDFeature_key", key)
+				writeXMLElement(&rec, "        ", "INSDFeature_location", gffLocation(node))
+
+				// intervals are listed in biological order
+				rec.WriteString("        <INSDFeature_intervals>\n")
+				for i := range node.Exons {
+					exon := node.Exons[i]
+					fr, to := exon.Start, exon.Stop
+					if node.Strand == "-" {
+						exon = node.Exons[len(node.Exons)-1-i]
+						fr, to = exon.Stop, exon.Start
+					}
+					rec.WriteString("          <INSDInterval>\n")
+					if fr == to {
+						writeXMLElement(&rec, "            ", "INSDInterval_point", strconv.Itoa(fr))
+					} else {
+						writeXMLElement(&rec, "            ", "INSDInterval_from", strconv.Itoa(fr))
+						writeXMLElement(&rec, "            ", "INSDInterval_to", strconv.Itoa(to))
+						if node.Strand == "-" {
+							rec.WriteString("            <INSDInterval_iscomp value=\"true\"/>\n")
+						}
+					}
+					writeXMLElement(&rec, "            ", "INSDInterval_accession", accnver)
+					rec.WriteString("          </INSDInterval>\n")
+				}
+				rec.WriteString("        </INSDFeature_intervals>\n")
+
+				if node.Partial5 {
+					rec.WriteString("        <INSDFeature_partial5 value=\"true\"/>\n")
+				}
+				if node.Partial3 {
+					rec.WriteString("        <INSDFeature_partial3 value=\"true\"/>\n")
+				}
+
+				var quals []insdQual
+
+				if gene != "" && gffAttr(node.Attrs, "gene") == "" {
+					quals = append(quals, insdQual{Name: "gene", Value: gene})
+				}
+				if key == "gene" && node.Type == "pseudogene" {
+					quals = append(quals, insdQual{Name: "pseudo"})
+				}
+				if key == "ncRNA" && node.Type != "ncRNA" && gffAttr(node.Attrs, "ncRNA_class") == "" {
+					class := strings.TrimSuffix(strings.Replace(node.Type, "_", "", -1), "RNA") + "RNA"
+					quals = append(quals, insdQual{Name: "ncRNA_class", Value: class})
+				}
+				if key == "CDS" && len(node.Phases) > 0 {
+					// phase of the first coding segment in biological order sets the reading frame
+					ph := node.Phases[0]
+					if node.Strand == "-" {
+						ph = node.Phases[len(node.Phases)-1]
+					}
+					if ph > 0 {
+						quals = append(quals, insdQual{Name: "codon_start", Value: strconv.Itoa(ph + 1)})
+					}
+				}
+				if key == "misc_feature" && node.Type != "misc_feature" {
+					quals = append(quals, insdQual{Name: "note", Value: node.Type})
+				}
+
+				for _, attr := range node.Attrs {
+					switch attr.Name {
+					case "Dbxref":
+						quals = append(quals, insdQual{Name: "db_xref", Value: attr.Value})
+					case "Note":
+						quals = append(quals, insdQual{Name: "note", Value: attr.Value})
+					default:
+						if !gffSkipAttrs[attr.Name] {
+							quals = append(quals, attr)
+						}
+					}
+				}
+
+				if len(quals) > 0 {
+					rec.WriteString("        <INSDFeature_quals>\n")
+					for _, qual := range quals {
+						rec.WriteString("          <INSDQualifier>\n")
+						writeXMLElement(&rec, "            ", "INSDQualifier_name", qual.Name)
+						if qual.Value != "" {
+							writeXMLElement(&rec, "            ", "INSDQualifier_value", qual.Value)
+						}
+						rec.WriteString("          </INSDQualifier>\n")
+					}
+					rec.WriteString("        </INSDFeature_quals>\n")
+				}
+
+				rec.WriteString("      </INSDFeature>\n")
+			}
+
+			// source feature spans entire sequence unless a region row provides one
+			hasSource := false
+			for _, node := range roots {
+				if node.Type == "region" {
+					if lf, rt := span(node); lf == 1 && rt == length {
+						hasSource = true
+					}
+				}
+			}
+			if !hasSource && length > 0 {
+				src := &gffNode{Type: "region", Strand: "+", Exons: []insdExon{{Start: 1, Stop: length, Length: length}}}
+				writeFeature(src, "source", "")
+			}
+
+			done := make(map[*gffNode]bool)
+
+			// visit writes a feature and then its descendants, gene before mRNA before CDS
+			var visit func(node *gffNode, gene string)
+			visit = func(node *gffNode, gene string) {
+
+				if done[node] {
+					return
+				}
+				done[node] = true
+
+				// fold exon children into a transcript location
+				var exons []insdExon
+				var others []*gffNode
+				for _, chld := range node.Children {
+					if chld.Type == "exon" && node.Type != "gene" && node.Type != "pseudogene" {
+						exons = append(exons, chld.Exons...)
+						done[chld] = true
+					} else {
+						others = append(others, chld)
+					}
+				}
+				if len(exons) > 0 {
+					node.Exons = exons
+					node.Phases = nil
+				}
+				if len(node.Exons) == 0 {
+					if lf, rt := span(node); lf > 0 {
+						node.Exons = []insdExon{{Start: lf, Stop: rt, Minus: node.Strand == "-", Length: rt - lf + 1}}
+					}
+				}
+				node.Exons, node.Phases = mergeExons(node.Exons, node.Phases)
+
+				if len(node.Exons) == 0 {
+					return
+				}
+
+				key, ok := gffTypeToKey[node.Type]
+				if !ok {
+					key, ok = LegalFeatureKey(node.Type)
+					if !ok {
+						key = "misc_feature"
+					}
+				}
+				if node.Type == "transcript" && !hasCDS(node) {
+					key = "misc_RNA"
+				}
+
+				if key == "gene" {
+					if name := gffAttr(node.Attrs, "gene"); name != "" {
+						gene = name
+					} else if name := gffAttr(node.Attrs, "Name"); name != "" {
+						gene = name
+						node.Attrs = append([]insdQual{{Name: "gene", Value: name}}, node.Attrs...)
+					}
+				}
+
+				if key == "source" && (node.Exons[0].Start != 1 || node.Exons[len(node.Exons)-1].Stop != length) {
+					key = "misc_feature"
+				}
+
+				if key != "" {
+					writeFeature(node, key, gene)
+				}
+
+				for _, chld := range others {
+					visit(chld, gene)
+				}
+			}
+
+			for _, node := range roots {
+				visit(node, "")
+			}
+
+			rec.WriteString("    </INSDSeq_feature-table>\n")
+
+			if sq.Sequence.Len() > 0 {
+				writeXMLElement(&rec, "    ", "INSDSeq_sequence", sq.Sequence.String())
+			}
+
+			rec.WriteString("  </INSDSeq>\n")
+
+			out <- rec.String()
+		}
+	}
+
+	// launch single converter goroutine
+	go convertGFF(inp, out)
+
+	return out
+}
+
+// hasCDS reports whether a transcript has a coding region child
+func hasCDS(node *gffNode) bool {
+
+	for _, chld := range node.Children {
+		if chld.Type == "CDS" {
+			return true
+		}
+	}
+
+	return false
+}
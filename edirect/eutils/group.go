@@ -0,0 +1,289 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  group.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CROSS-RECORD AGGREGATION FOR XTRACT -group-by
+
+// field separators in generated extraction rows, chosen to never appear in XML contents
+const (
+	groupSep  = "\x1f"
+	groupNone = "\x1d"
+)
+
+// groupAccum is one requested accumulator, e.g., -avg Year
+type groupAccum struct {
+	Op    string
+	Field int
+}
+
+// groupData holds the per-key record count and the values seen for each field
+type groupData struct {
+	Count  int
+	Values [][]int
+}
+
+// GroupBy collects extraction rows and reduces them by key when the stream ends
+type GroupBy struct {
+	Key    string
+	Fields []string
+	Accums []groupAccum
+	Groups map[string]*groupData
+}
+
+// ParseGroupBy reads "-group-by Key -count -sum Field -avg Field ..." and returns
+// the ordinary extraction arguments that generate one raw row per record
+func ParseGroupBy(args []string) (*GroupBy, []string) {
+
+	if len(args) < 2 || args[0] != "-group-by" {
+		return nil, args
+	}
+
+	key := args[1]
+	if key == "" || strings.HasPrefix(key, "-") {
+		fmt.Fprintf(os.Stderr, "\nERROR: Item missing after -group-by command\n")
+		os.Exit(1)
+	}
+
+	grp := &GroupBy{Key: key, Groups: make(map[string]*groupData)}
+
+	// fields are extracted once even if requested by several accumulators
+	fieldIndex := make(map[string]int)
+
+	args = args[2:]
+
+	for len(args) > 0 {
+
+		op := args[0]
+
+		switch op {
+		case "-count":
+			grp.Accums = append(grp.Accums, groupAccum{Op: "count", Field: -1})
+			args = args[1:]
+		case "-sum", "-avg", "-min", "-max", "-med", "-dev":
+			if len(args) < 2 || args[1] == "" || strings.HasPrefix(args[1], "-") {
+				fmt.Fprintf(os.Stderr, "\nERROR: Item missing after %s command\n", op)
+				os.Exit(1)
+			}
+			fld := args[1]
+			idx, ok := fieldIndex[fld]
+			if !ok {
+				idx = len(grp.Fields)
+				fieldIndex[fld] = idx
+				grp.Fields = append(grp.Fields, fld)
+			}
+			grp.Accums = append(grp.Accums, groupAccum{Op: op[1:], Field: idx})
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option '%s' after -group-by command\n", op)
+			os.Exit(1)
+		}
+	}
+
+	if len(grp.Accums) == 0 {
+		// plain -group-by counts records per key
+		grp.Accums = append(grp.Accums, groupAccum{Op: "count", Field: -1})
+	}
+
+	// each field in its own clause so -def applies to every one
+	cmds := []string{"-sep", groupSep, "-def", groupNone, "-element", key}
+	for _, fld := range grp.Fields {
+		cmds = append(cmds, "-element", fld)
+	}
+
+	return grp, cmds
+}
+
+// Add accumulates the rows produced by one record
+func (grp *GroupBy) Add(text string) {
+
+	for _, line := range strings.Split(text, "\n") {
+
+		if line == "" {
+			continue
+		}
+
+		cols := strings.Split(line, "\t")
+		if len(cols) < 1 || cols[0] == groupNone {
+			continue
+		}
+
+		// a record with multiple key values contributes to each distinct group
+		seen := make(map[string]bool)
+
+		for _, ky := range strings.Split(cols[0], groupSep) {
+
+			if ky == "" || seen[ky] {
+				continue
+			}
+			seen[ky] = true
+
+			data, ok := grp.Groups[ky]
+			if !ok {
+				data = &groupData{Values: make([][]int, len(grp.Fields))}
+				grp.Groups[ky] = data
+			}
+
+			data.Count++
+
+			for i := range grp.Fields {
+				if i+1 >= len(cols) || cols[i+1] == groupNone {
+					continue
+				}
+				for _, str := range strings.Split(cols[i+1], groupSep) {
+					value, err := strconv.Atoi(str)
+					if err == nil {
+						data.Values[i] = append(data.Values[i], value)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Drain reads extraction results from a channel, returning record and byte counts
+func (grp *GroupBy) Drain(inp <-chan XMLRecord) (int, int) {
+
+	recordCount := 0
+	byteCount := 0
+
+	for curr := range inp {
+
+		recordCount++
+		byteCount += len(curr.Text)
+
+		grp.Add(curr.Text)
+
+		runtime.Gosched()
+	}
+
+	return recordCount, byteCount
+}
+
+// reduce computes one accumulator over a list of values, returning false if undefined
+func (acc groupAccum) reduce(data *groupData) (string, bool) {
+
+	if acc.Op == "count" {
+		return strconv.Itoa(data.Count), true
+	}
+
+	arry := data.Values[acc.Field]
+	count := len(arry)
+	if count < 1 {
+		return "", false
+	}
+
+	switch acc.Op {
+	case "sum", "avg":
+		sum := 0
+		for _, value := range arry {
+			sum += value
+		}
+		if acc.Op == "avg" {
+			// integer average, as with -avg on a single record
+			sum = int(float64(sum) / float64(count))
+		}
+		return strconv.Itoa(sum), true
+	case "min", "max":
+		res := arry[0]
+		for _, value := range arry {
+			if acc.Op == "min" && value < res || acc.Op == "max" && value > res {
+				res = value
+			}
+		}
+		return strconv.Itoa(res), true
+	case "med":
+		srt := make([]int, count)
+		copy(srt, arry)
+		sort.Ints(srt)
+		return strconv.Itoa(srt[count/2]), true
+	case "dev":
+		if count < 2 {
+			return "", false
+		}
+		// Welford algorithm for one-pass standard deviation
+		mean := 0.0
+		m2 := 0.0
+		for i, value := range arry {
+			x := float64(value)
+			delta := x - mean
+			mean += delta / float64(i+1)
+			m2 += delta * (x - mean)
+		}
+		vrc := m2 / float64(count-1)
+		return strconv.Itoa(int(math.Sqrt(vrc))), true
+	}
+
+	return "", false
+}
+
+// Print writes one tab-delimited row per key, sorted in alphabetical or numeric order
+func (grp *GroupBy) Print() {
+
+	var keys []string
+	for ky := range grp.Groups {
+		keys = append(keys, ky)
+	}
+
+	sortTaxIDs(keys)
+
+	var buffer strings.Builder
+
+	for _, ky := range keys {
+
+		data := grp.Groups[ky]
+
+		buffer.Reset()
+		buffer.WriteString(ky)
+
+		for _, acc := range grp.Accums {
+			buffer.WriteString("\t")
+			if str, ok := acc.reduce(data); ok {
+				buffer.WriteString(str)
+			} else {
+				buffer.WriteString("-")
+			}
+		}
+
+		buffer.WriteString("\n")
+
+		os.Stdout.WriteString(buffer.String())
+	}
+}
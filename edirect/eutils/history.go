@@ -0,0 +1,258 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  history.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ARCHIVE VERSION HISTORY
+
+// When history is enabled, a record about to be overwritten or deleted is renamed with
+// its version number (e.g., 12345.v2.xml.gz), and the current file keeps its usual name.
+// Each record has a plain-text manifest (e.g., 12345.xml.hst) with one line per version,
+// containing the version number, UTC timestamp, source update file, and action (add,
+// update, or delete). Records stashed before history was enabled are entered as version
+// 1 with the earliest possible timestamp, so they are returned for any -asof date.
+
+const historyEpoch = "0000-00-00T00:00:00Z"
+
+// HistoryEntry is one line of a record's version manifest
+type HistoryEntry struct {
+	Version int
+	Date    string
+	Source  string
+	Action  string
+}
+
+// historyNames returns the manifest file name and a function that gives the version file
+// name, given the usual archive file prefix, trie file name, and suffix (e.g., ".xml.gz")
+func historyNames(pfx, file, sfx string) (string, func(int) string) {
+
+	base := strings.TrimSuffix(sfx, ".gz")
+
+	manifest := pfx + file + base + ".hst"
+
+	version := func(num int) string {
+		return pfx + file + ".v" + strconv.Itoa(num) + sfx
+	}
+
+	return manifest, version
+}
+
+// readHistory reads a record's version manifest, returning nil if not present
+func readHistory(fpath string) []HistoryEntry {
+
+	inFile, err := os.Open(fpath)
+	if err != nil {
+		return nil
+	}
+
+	defer inFile.Close()
+
+	var hist []HistoryEntry
+
+	scanr := bufio.NewScanner(inFile)
+
+	for scanr.Scan() {
+
+		cols := strings.Split(scanr.Text(), "\t")
+		if len(cols) < 4 {
+			continue
+		}
+
+		num, err := strconv.Atoi(cols[0])
+		if err != nil {
+			continue
+		}
+
+		hist = append(hist, HistoryEntry{Version: num, Date: cols[1], Source: cols[2], Action: cols[3]})
+	}
+
+	return hist
+}
+
+// appendHistory adds lines to a record's version manifest
+func appendHistory(fpath string, ents ...HistoryEntry) {
+
+	fl, err := os.OpenFile(fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return
+	}
+
+	for _, ent := range ents {
+		fmt.Fprintf(fl, "%d\t%s\t%s\t%s\n", ent.Version, ent.Date, ent.Source, ent.Action)
+	}
+
+	err = fl.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+	}
+}
+
+// saveVersion preserves the current archive file under its version number, and records the
+// action about to be taken, returning false if a deletion has no record to remove
+func saveVersion(dpath, pfx, file, sfx, source, action string) bool {
+
+	manifest, version := historyNames(pfx, file, sfx)
+
+	mpath := filepath.Join(dpath, manifest)
+	cpath := filepath.Join(dpath, pfx+file+sfx)
+
+	hist := readHistory(mpath)
+
+	var ents []HistoryEntry
+
+	last := 0
+	if len(hist) > 0 {
+		last = hist[len(hist)-1].Version
+	}
+
+	_, err := os.Stat(cpath)
+	exists := (err == nil)
+
+	if exists {
+		if last == 0 {
+			// record was stashed before history was enabled
+			last = 1
+			ents = append(ents, HistoryEntry{Version: last, Date: historyEpoch, Source: "-", Action: "add"})
+		}
+		err = os.Rename(cpath, filepath.Join(dpath, version(last)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		}
+	}
+
+	if action == "delete" && !exists {
+		return false
+	}
+
+	if action != "delete" {
+		action = "add"
+		if exists {
+			action = "update"
+		}
+	}
+
+	if source == "" {
+		source = "-"
+	}
+
+	now := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+
+	ents = append(ents, HistoryEntry{Version: last + 1, Date: now, Source: source, Action: action})
+
+	appendHistory(mpath, ents...)
+
+	return true
+}
+
+// NormalizeAsOf converts a date, with optional time, to the UTC timestamp format used in
+// version manifests, with a date alone referring to the end of that day
+func NormalizeAsOf(str string) string {
+
+	str = strings.TrimSpace(str)
+	str = strings.Replace(str, "/", "-", -1)
+
+	layouts := []string{"2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+	for _, lyt := range layouts {
+		tm, err := time.Parse(lyt, str)
+		if err != nil {
+			continue
+		}
+		if lyt == "2006-01-02" {
+			tm = tm.Add(24*time.Hour - time.Second)
+		}
+		return tm.UTC().Format("2006-01-02T15:04:05Z")
+	}
+
+	return ""
+}
+
+// versionAsOf returns the file suffix for the version of a record current at a given time,
+// or false if the record did not exist or had been deleted by then
+func versionAsOf(base, id, pfx, sfx, asof string) (string, bool) {
+
+	dir, file := ArchiveTrie(id)
+	if dir == "" || file == "" {
+		return "", false
+	}
+
+	manifest, _ := historyNames(pfx, file, sfx)
+
+	hist := readHistory(filepath.Join(base, dir, manifest))
+	if len(hist) < 1 {
+		// no history, return record as is
+		return sfx, true
+	}
+
+	// find last version recorded at or before requested time
+	idx := -1
+	for i, ent := range hist {
+		if ent.Date <= asof {
+			idx = i
+		}
+	}
+
+	if idx < 0 || hist[idx].Action == "delete" {
+		return "", false
+	}
+
+	if idx == len(hist)-1 {
+		// still the current version
+		return sfx, true
+	}
+
+	return ".v" + strconv.Itoa(hist[idx].Version) + sfx, true
+}
+
+// RecordHistory returns the version manifest for a record in the archive
+func RecordHistory(base, id, pfx, sfx string) []HistoryEntry {
+
+	id = strings.TrimPrefix(id, "PMC")
+
+	dir, file := ArchiveTrie(id)
+	if dir == "" || file == "" {
+		return nil
+	}
+
+	manifest, _ := historyNames(pfx, file, sfx)
+
+	return readHistory(filepath.Join(base, dir, manifest))
+}
@@ -357,6 +357,10 @@ func examineFolder(base, path string) ([]string, []string, []string) {
 				dirs = append(dirs, name)
 			}
 		} else if strings.HasSuffix(name, ".xml.gz") {
+			// skip prior versions kept by archive history, e.g., 12345.v2.xml.gz
+			if strings.Contains(name, ".v") {
+				continue
+			}
 			xmls = append(xmls, name)
 		} else if strings.HasSuffix(name, ".e2x.gz") {
 			e2xs = append(e2xs, name)
@@ -40,23 +40,44 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// jsonToken keeps delimiters and quoted strings apart from unquoted literals
+type jsonToken struct {
+	text   string
+	delim  bool
+	quoted bool
+}
+
 // JSONConverter parses JSON stream into XML object stream
 func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 
+	return jsonConverter(inp, set, rec, nest, false)
+}
+
+// HintedJSONConverter also marks quoted strings that look like literals, nested arrays,
+// and members of one-item or empty arrays with json:type and json:array attributes,
+// so XMLtoJSON can restore the original JSON
+func HintedJSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
+
+	return jsonConverter(inp, set, rec, nest, true)
+}
+
+func jsonConverter(inp io.Reader, set, rec, nest string, hints bool) <-chan string {
+
 	if inp == nil {
 		return nil
 	}
 
-	tks := make(chan string, chanDepth)
+	tks := make(chan jsonToken, chanDepth)
 	out := make(chan string, chanDepth)
 	if tks == nil || out == nil {
 		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create JSON converter channels\n")
 		os.Exit(1)
 	}
 
-	tokenizeJSON := func(inp io.Reader, tks chan<- string) {
+	tokenizeJSON := func(inp io.Reader, tks chan<- jsonToken) {
 
 		// close channel when all tokens have been sent
 		defer close(tks)
@@ -83,27 +104,27 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 			switch v := t.(type) {
 			case json.Delim:
 				// opening or closing braces (for objects) or brackets (for arrays)
-				tks <- string(v)
+				tks <- jsonToken{text: string(v), delim: true}
 			case string:
 				str := v
 				if HasAdjacentSpacesOrNewline(str) {
 					str = CompressRunsOfSpaces(str)
 				}
-				tks <- str
+				tks <- jsonToken{text: str, quoted: true}
 			case json.Number:
-				tks <- v.String()
+				tks <- jsonToken{text: v.String()}
 			case float64:
-				tks <- strconv.FormatFloat(v, 'f', -1, 64)
+				tks <- jsonToken{text: strconv.FormatFloat(v, 'f', -1, 64)}
 			case bool:
 				if v {
-					tks <- "true"
+					tks <- jsonToken{text: "true"}
 				} else {
-					tks <- "false"
+					tks <- jsonToken{text: "false"}
 				}
 			case nil:
-				tks <- "null"
+				tks <- jsonToken{text: "null"}
 			default:
-				tks <- t.(string)
+				tks <- jsonToken{text: t.(string), quoted: true}
 			}
 		}
 	}
@@ -164,7 +185,7 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 	}
 
 	// convertJSON sends XML records down a channel
-	convertJSON := func(tks <-chan string, out chan<- string) {
+	convertJSON := func(tks <-chan jsonToken, out chan<- string) {
 
 		// close channel when all tokens have been processed
 		defer close(out)
@@ -237,17 +258,82 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 
 		count := 0
 
+		// tokens read ahead to measure an array are replayed before reading from the channel
+		var pushback []jsonToken
+
+		nextToken := func() (jsonToken, bool) {
+			if len(pushback) > 0 {
+				jtk := pushback[0]
+				pushback = pushback[1:]
+				return jtk, true
+			}
+			jtk, ok := <-tks
+			return jtk, ok
+		}
+
+		// singleItem reads the first array member and the token after it, then pushes them back
+		singleItem := func() bool {
+
+			var saved []jsonToken
+
+			jtk, ok := nextToken()
+			if !ok {
+				return false
+			}
+			saved = append(saved, jtk)
+
+			if jtk.delim && (jtk.text == "{" || jtk.text == "[") {
+				depth := 1
+				for depth > 0 {
+					jtk, ok = nextToken()
+					if !ok {
+						break
+					}
+					saved = append(saved, jtk)
+					if jtk.delim {
+						switch jtk.text {
+						case "{", "[":
+							depth++
+						case "}", "]":
+							depth--
+						}
+					}
+				}
+			} else if jtk.delim {
+				// empty array
+				pushback = append(saved, pushback...)
+				return false
+			}
+
+			single := false
+			jtk, ok = nextToken()
+			if ok {
+				saved = append(saved, jtk)
+				single = jtk.delim && (jtk.text == "]" || jtk.text == "}")
+			}
+
+			pushback = append(saved, pushback...)
+
+			return single
+		}
+
 		// recursive function definitions
-		var parseObject func(tag string)
+		var parseObject func(tag, attr string)
 		var parseArray func(tag, pfx string, lvl int)
 
-		// recursive descent parser uses mutual recursion
-		parseValue := func(tag, pfx, tkn string, lvl int) {
+		// recursive descent parser uses mutual recursion, hint marks the member of a one-item array
+		parseValue := func(tag, pfx string, jtk jsonToken, lvl int, hint string) {
+
+			tkn := jtk.text
+			if !jtk.delim && (tkn == "{" || tkn == "[") {
+				// quoted brace or bracket is content, not a delimiter
+				tkn = " " + tkn
+			}
 
 			switch tkn {
 			case "{":
 				if flatR {
-					parseObject(tag)
+					parseObject(tag, hint)
 				} else if lvl > 0 {
 					// JSON object within JSON array creates recursive XML objects
 					doIndent(indent)
@@ -258,19 +344,20 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 					}
 					buffer.WriteString("<")
 					buffer.WriteString(tg)
+					buffer.WriteString(hint)
 					buffer.WriteString(">\n")
 					if depthR {
-						parseObject(pfx + "_" + strconv.Itoa(lvl))
+						parseObject(pfx+"_"+strconv.Itoa(lvl), "")
 					} else if elemR {
 						sfx := ""
 						for i := 0; i < lvl; i++ {
 							sfx += "_E"
 						}
-						parseObject(pfx + sfx)
+						parseObject(pfx+sfx, "")
 					} else if singularR {
-						parseObject(inflector.Singularize(pfx))
+						parseObject(inflector.Singularize(pfx), "")
 					} else {
-						parseObject(pfx)
+						parseObject(pfx, "")
 					}
 					indent--
 					doIndent(indent)
@@ -278,7 +365,7 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 					buffer.WriteString(tg)
 					buffer.WriteString(">\n")
 				} else {
-					parseObject(tag)
+					parseObject(tag, "")
 				}
 				// no break needed, would use fallthrough to explicitly cause program control to flow to the next case
 			case "[":
@@ -294,6 +381,11 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 					}
 					buffer.WriteString("<")
 					buffer.WriteString(tg)
+					buffer.WriteString(hint)
+					if hints {
+						// wrapper for nested array is restored as a JSON array
+						buffer.WriteString(" json:type=\"array\"")
+					}
 					buffer.WriteString(">\n")
 					if depthL {
 						parseArray(pfx+"_"+strconv.Itoa(lvl), tag, lvl+1)
@@ -323,14 +415,24 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 				doIndent(indent)
 				buffer.WriteString("<")
 				buffer.WriteString(tag)
+				buffer.WriteString(hint)
+				if hints {
+					// attribute keeps the self-closing tag from being skipped by the record parser
+					buffer.WriteString(" json:type=\"string\"")
+				}
 				buffer.WriteString("/>\n")
 			default:
 				// write object and contents to string builder
 				doIndent(indent)
 				tkn = strings.TrimSpace(tkn)
+				attr := hint
+				if hints && jtk.quoted && looksLikeJSONLiteral(tkn) {
+					attr += " json:type=\"string\""
+				}
 				tkn = html.EscapeString(tkn)
 				buffer.WriteString("<")
 				buffer.WriteString(tag)
+				buffer.WriteString(attr)
 				buffer.WriteString(">")
 				buffer.WriteString(tkn)
 				buffer.WriteString("</")
@@ -350,37 +452,49 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 			}
 		}
 
-		parseObject = func(tag string) {
+		parseObject = func(tag, attr string) {
+
+			if hints {
+				// empty object would otherwise come back as an empty string
+				jtk, ok := nextToken()
+				if ok {
+					if jtk.delim && jtk.text == "}" {
+						attr += " json:type=\"object\""
+					}
+					pushback = append([]jsonToken{jtk}, pushback...)
+				}
+			}
 
 			doIndent(indent)
 			indent++
 			buffer.WriteString("<")
 			buffer.WriteString(tag)
+			buffer.WriteString(attr)
 			buffer.WriteString(">\n")
 
 			for {
 				// shadowing tag variable inside for loop does not step on value of tag argument in outer scope
-				tag, ok := <-tks
+				key, ok := nextToken()
 				if !ok {
 					break
 				}
 
-				if tag == "}" || tag == "]" {
+				if key.delim && (key.text == "}" || key.text == "]") {
 					break
 				}
 
-				tag = fixTag(tag)
+				tag := fixTag(key.text)
 
-				tkn, ok := <-tks
+				tkn, ok := nextToken()
 				if !ok {
 					break
 				}
 
-				if tkn == "}" || tkn == "]" {
+				if tkn.delim && (tkn.text == "}" || tkn.text == "]") {
 					break
 				}
 
-				parseValue(tag, tag, tkn, 0)
+				parseValue(tag, tag, tkn, 0, "")
 			}
 
 			indent--
@@ -392,17 +506,34 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 
 		parseArray = func(tag, pfx string, lvl int) {
 
+			num := 0
+
+			// repeated elements already imply an array, only a lone member needs a hint
+			hint := ""
+			if hints && lvl > 0 && singleItem() {
+				hint = " json:array=\"true\""
+			}
+
 			for {
-				tkn, ok := <-tks
+				tkn, ok := nextToken()
 				if !ok {
 					break
 				}
 
-				if tkn == "}" || tkn == "]" {
+				if tkn.delim && (tkn.text == "}" || tkn.text == "]") {
 					break
 				}
 
-				parseValue(tag, pfx, tkn, lvl)
+				parseValue(tag, pfx, tkn, lvl, hint)
+				num++
+			}
+
+			if hints && num == 0 && lvl > 0 {
+				// empty array leaves a placeholder, otherwise the key would disappear
+				doIndent(indent)
+				buffer.WriteString("<")
+				buffer.WriteString(tag)
+				buffer.WriteString(" json:array=\"empty\"/>\n")
 			}
 		}
 
@@ -412,13 +543,13 @@ func JSONConverter(inp io.Reader, set, rec, nest string) <-chan string {
 
 		// process stream of catenated top-level JSON objects or arrays
 		for {
-			tkn, ok := <-tks
+			tkn, ok := nextToken()
 			if !ok {
 				break
 			}
-			if tkn == "{" {
-				parseObject(opt)
-			} else if tkn == "[" {
+			if tkn.delim && tkn.text == "{" {
+				parseObject(opt, "")
+			} else if tkn.delim && tkn.text == "[" {
 				parseArray(anon, anon, 0)
 			} else {
 				break
@@ -481,3 +612,357 @@ func JSONtoXML(jsn, set, rec, nest string) string {
 
 	return res
 }
+
+// XML TO JSON CONVERTER
+
+// isJSONNumber checks for a legal JSON number, rejecting leading zeros, plus signs, hexadecimal, and NaN
+func isJSONNumber(str string) bool {
+
+	if str == "" {
+		return false
+	}
+
+	idx := 0
+	max := len(str)
+
+	if str[idx] == '-' {
+		idx++
+	}
+	if idx >= max {
+		return false
+	}
+
+	// integer part, single zero or digit string not starting with zero
+	if str[idx] == '0' {
+		idx++
+	} else if str[idx] >= '1' && str[idx] <= '9' {
+		for idx < max && str[idx] >= '0' && str[idx] <= '9' {
+			idx++
+		}
+	} else {
+		return false
+	}
+
+	// optional fraction
+	if idx < max && str[idx] == '.' {
+		idx++
+		strt := idx
+		for idx < max && str[idx] >= '0' && str[idx] <= '9' {
+			idx++
+		}
+		if idx == strt {
+			return false
+		}
+	}
+
+	// optional exponent
+	if idx < max && (str[idx] == 'e' || str[idx] == 'E') {
+		idx++
+		if idx < max && (str[idx] == '+' || str[idx] == '-') {
+			idx++
+		}
+		strt := idx
+		for idx < max && str[idx] >= '0' && str[idx] <= '9' {
+			idx++
+		}
+		if idx == strt {
+			return false
+		}
+	}
+
+	return idx == max
+}
+
+// writeJSONString quotes a string and escapes characters not allowed in JSON
+func writeJSONString(buffer *strings.Builder, str string) {
+
+	buffer.WriteString("\"")
+
+	for _, ch := range str {
+		switch ch {
+		case '"':
+			buffer.WriteString("\\\"")
+		case '\\':
+			buffer.WriteString("\\\\")
+		case '\n':
+			buffer.WriteString("\\n")
+		case '\r':
+			buffer.WriteString("\\r")
+		case '\t':
+			buffer.WriteString("\\t")
+		default:
+			if ch < 0x20 {
+				buffer.WriteString(fmt.Sprintf("\\u%04x", ch))
+			} else {
+				buffer.WriteRune(ch)
+			}
+		}
+	}
+
+	buffer.WriteString("\"")
+}
+
+// looksLikeJSONLiteral reports strings that would otherwise be written as numbers, true, false, or null
+func looksLikeJSONLiteral(str string) bool {
+
+	switch str {
+	case "true", "false", "null":
+		return true
+	}
+
+	return isJSONNumber(str)
+}
+
+// writeJSONValue prints numbers, true, false, and null as JSON literals, and quotes everything else
+func writeJSONValue(buffer *strings.Builder, str string, asStrings bool) {
+
+	if !asStrings && looksLikeJSONLiteral(str) {
+		buffer.WriteString(str)
+		return
+	}
+
+	writeJSONString(buffer, str)
+}
+
+// jsonHints separates json:type and json:array attributes written by HintedJSONConverter
+// from the remaining attribute name and value pairs
+func jsonHints(node *XMLNode) (attribs []string, typ string, array string) {
+
+	for i, pairs := 0, ParseAttributes(strings.TrimSpace(node.Attributes)); i < len(pairs)-1; i += 2 {
+		switch pairs[i] {
+		case "json:type":
+			typ = pairs[i+1]
+		case "json:array":
+			array = pairs[i+1]
+		default:
+			attribs = append(attribs, pairs[i], pairs[i+1])
+		}
+	}
+
+	return attribs, typ, array
+}
+
+// XMLtoJSON converts a single partitioned XML record into a one-line JSON object.
+// Repeated child elements become arrays, attributes are given keys starting with
+// prefix, and content of elements with attributes or mixed children goes into "#text".
+// Values that look like numbers, true, false, or null are written as JSON literals
+// unless asStrings is set. Plain JSONConverter output does not record which values
+// were quoted or which elements came from one-item arrays, but the json:type and
+// json:array attributes added by HintedJSONConverter, the transmute -j2x default,
+// are honored, so that output round-trips back to the original JSON.
+func XMLtoJSON(text, parent, prefix string, asStrings, wrap bool) string {
+
+	if text == "" {
+		return ""
+	}
+
+	pat := ParseRecord(text, parent)
+	if pat == nil {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	writeValue := func(str string, isString bool) {
+
+		writeJSONValue(&buffer, html.UnescapeString(str), asStrings || isString)
+	}
+
+	// recursive function definition
+	var writeNode func(node *XMLNode)
+
+	// writeObject prints attributes, then groups children by name in order of first appearance
+	writeObject := func(node *XMLNode) {
+
+		buffer.WriteString("{")
+
+		between := ""
+
+		attribs, _, _ := jsonHints(node)
+		for i := 0; i < len(attribs)-1; i += 2 {
+			buffer.WriteString(between)
+			between = ","
+			writeJSONString(&buffer, prefix+attribs[i])
+			buffer.WriteString(":")
+			writeValue(attribs[i+1], false)
+		}
+
+		var names []string
+		groups := make(map[string][]*XMLNode)
+
+		var mixed []string
+		if node.Contents != "" {
+			mixed = append(mixed, node.Contents)
+		}
+
+		for chld := node.Children; chld != nil; chld = chld.Next {
+			if chld.Name == "" {
+				// unnamed node holds mixed content text
+				if chld.Contents != "" {
+					mixed = append(mixed, chld.Contents)
+				}
+				continue
+			}
+			if _, ok := groups[chld.Name]; !ok {
+				names = append(names, chld.Name)
+			}
+			groups[chld.Name] = append(groups[chld.Name], chld)
+		}
+
+		if len(mixed) > 0 {
+			buffer.WriteString(between)
+			between = ","
+			writeJSONString(&buffer, "#text")
+			buffer.WriteString(":")
+			writeValue(strings.TrimSpace(strings.Join(mixed, "")), false)
+		}
+
+		for _, name := range names {
+			buffer.WriteString(between)
+			between = ","
+			writeJSONString(&buffer, name)
+			buffer.WriteString(":")
+
+			group := groups[name]
+			if len(group) == 1 {
+				_, _, array := jsonHints(group[0])
+				if array == "empty" {
+					buffer.WriteString("[]")
+					continue
+				}
+				if array == "" {
+					writeNode(group[0])
+					continue
+				}
+			}
+
+			// repeated elements become an array
+			buffer.WriteString("[")
+			for i, chld := range group {
+				if i > 0 {
+					buffer.WriteString(",")
+				}
+				writeNode(chld)
+			}
+			buffer.WriteString("]")
+		}
+
+		buffer.WriteString("}")
+	}
+
+	writeNode = func(node *XMLNode) {
+
+		attribs, typ, _ := jsonHints(node)
+
+		if typ == "array" {
+			// wrapper element for a nested array, an empty placeholder child means no members
+			buffer.WriteString("[")
+			between := ""
+			for chld := node.Children; chld != nil; chld = chld.Next {
+				if chld.Name == "" {
+					continue
+				}
+				if _, _, array := jsonHints(chld); array == "empty" {
+					continue
+				}
+				buffer.WriteString(between)
+				between = ","
+				writeNode(chld)
+			}
+			buffer.WriteString("]")
+			return
+		}
+
+		if len(attribs) == 0 && typ != "object" {
+			// leaf element without attributes becomes a simple value, -mixed puts content in unnamed children
+			isLeaf := true
+			str := node.Contents
+			for chld := node.Children; chld != nil; chld = chld.Next {
+				if chld.Name != "" {
+					isLeaf = false
+					break
+				}
+				str += chld.Contents
+			}
+			if isLeaf {
+				str = strings.TrimSpace(str)
+				if str == "" {
+					buffer.WriteString("\"\"")
+				} else {
+					writeValue(str, typ == "string")
+				}
+				return
+			}
+		}
+
+		writeObject(node)
+	}
+
+	if wrap {
+		// keep record element name as single key of outer object
+		buffer.WriteString("{")
+		writeJSONString(&buffer, pat.Name)
+		buffer.WriteString(":")
+		writeNode(pat)
+		buffer.WriteString("}")
+	} else {
+		writeObject(pat)
+	}
+
+	return buffer.String()
+}
+
+// CreateJSONConverters runs multiple XML to JSON conversion go routines
+func CreateJSONConverters(parent, prefix string, asStrings, wrap bool, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create XML to JSON converter channel\n")
+		os.Exit(1)
+	}
+
+	// xmlToJSON reads partitioned XML from channel and converts on a per-record basis
+	xmlToJSON := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		// report when this converter has no more records to process
+		defer wg.Done()
+
+		for ext := range inp {
+
+			idx := ext.Index
+			ident := ext.Ident
+			text := ext.Text
+
+			if text == "" {
+				// should never see empty input data
+				out <- XMLRecord{Index: idx, Ident: ident, Text: text}
+				continue
+			}
+
+			str := XMLtoJSON(text, parent, prefix, asStrings, wrap)
+
+			// send even if empty to get all record counts for reordering
+			out <- XMLRecord{Index: idx, Ident: ident, Text: str}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple converter goroutines
+	for i := 0; i < numServe; i++ {
+		wg.Add(1)
+		go xmlToJSON(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all converters are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
@@ -31,6 +31,7 @@
 package eutils
 
 import (
+	"math"
 	"strconv"
 	"strings"
 )
@@ -249,3 +250,260 @@ func ProteinWeight(str string, trimLeadingMet bool) string {
 
 	return str
 }
+
+// PROTEIN PHYSICO-CHEMICAL PROPERTIES
+
+// standard amino acids in alphabetical order of one-letter codes
+const standardResidues = "ACDEFGHIKLMNPQRSTVWY"
+
+// Kyte-Doolittle hydropathy values
+var hydropathy = map[rune]float64{
+	'A': 1.8,
+	'C': 2.5,
+	'D': -3.5,
+	'E': -3.5,
+	'F': 2.8,
+	'G': -0.4,
+	'H': -3.2,
+	'I': 4.5,
+	'K': -3.9,
+	'L': 3.8,
+	'M': 1.9,
+	'N': -3.5,
+	'P': -1.6,
+	'Q': -3.5,
+	'R': -4.5,
+	'S': -0.8,
+	'T': -0.7,
+	'V': 4.2,
+	'W': -0.9,
+	'Y': -1.3,
+}
+
+// Bjellqvist side chain pK values for charged residues
+var sidePositive = map[rune]float64{
+	'H': 5.98,
+	'K': 10.0,
+	'R': 12.0,
+}
+
+var sideNegative = map[rune]float64{
+	'C': 9.0,
+	'D': 4.05,
+	'E': 4.45,
+	'Y': 10.0,
+}
+
+// terminal pK values that depend on the end residue
+var nTermPK = map[rune]float64{
+	'A': 7.59,
+	'E': 7.7,
+	'M': 7.0,
+	'P': 8.36,
+	'S': 6.93,
+	'T': 6.82,
+	'V': 7.44,
+}
+
+var cTermPK = map[rune]float64{
+	'D': 4.55,
+	'E': 4.75,
+}
+
+// Guruprasad dipeptide instability weight values, rows are first residue
+const dipeptideTable = `
+       A      C      D      E      F      G      H      I      K      L      M      N      P      Q      R      S      T      V      W      Y
+A   1.00  44.94  -7.49   1.00   1.00   1.00  -7.49   1.00   1.00   1.00   1.00   1.00  20.26   1.00   1.00   1.00   1.00   1.00   1.00   1.00
+C   1.00   1.00  20.26   1.00   1.00   1.00  33.60   1.00   1.00  20.26  33.60   1.00  20.26  -6.54   1.00   1.00  33.60  -6.54  24.68   1.00
+D   1.00   1.00   1.00   1.00  -6.54   1.00   1.00   1.00  -7.49   1.00   1.00   1.00   1.00   1.00  -6.54  20.26 -14.03   1.00   1.00   1.00
+E   1.00  44.94  20.26  33.60   1.00   1.00  -6.54  20.26   1.00   1.00   1.00   1.00  20.26  20.26   1.00  20.26   1.00   1.00 -14.03   1.00
+F   1.00   1.00  13.34   1.00   1.00   1.00   1.00   1.00 -14.03   1.00   1.00   1.00  20.26   1.00   1.00   1.00   1.00   1.00   1.00  33.60
+G  -7.49   1.00   1.00  -6.54   1.00  13.34   1.00  -7.49  -7.49   1.00   1.00  -7.49   1.00   1.00   1.00   1.00  -7.49   1.00  13.34  -7.49
+H   1.00   1.00   1.00   1.00  -9.37  -9.37   1.00  44.94  24.68   1.00   1.00  24.68  -1.88   1.00   1.00   1.00  -6.54   1.00  -1.88  44.94
+I   1.00   1.00   1.00  44.94   1.00   1.00  13.34   1.00  -7.49  20.26   1.00   1.00  -1.88   1.00   1.00   1.00   1.00  -7.49   1.00   1.00
+K   1.00   1.00   1.00   1.00   1.00  -7.49   1.00  -7.49   1.00  -7.49  33.60   1.00  -6.54  24.64  33.60   1.00   1.00  -7.49   1.00   1.00
+L   1.00   1.00   1.00   1.00   1.00   1.00   1.00   1.00  -7.49   1.00   1.00   1.00  20.26  33.60  20.26   1.00   1.00   1.00  24.68   1.00
+M  13.34   1.00   1.00   1.00   1.00   1.00  58.28   1.00   1.00   1.00  -1.88   1.00  44.94  -6.54  -6.54  44.94  -1.88   1.00   1.00  24.68
+N   1.00  -1.88   1.00   1.00 -14.03 -14.03   1.00  44.94  24.68   1.00   1.00   1.00  -1.88  -6.54   1.00   1.00  -7.49   1.00  -9.37   1.00
+P  20.26  -6.54  -6.54  18.38  20.26   1.00   1.00   1.00   1.00   1.00  -6.54   1.00  20.26  20.26  -6.54  20.26   1.00  20.26  -1.88   1.00
+Q   1.00  -6.54  20.26  20.26  -6.54   1.00   1.00   1.00   1.00   1.00   1.00   1.00  20.26  20.26   1.00  44.94   1.00  -6.54   1.00  -6.54
+R   1.00   1.00   1.00   1.00   1.00  -7.49  20.26   1.00   1.00   1.00   1.00  13.34  20.26  20.26  58.28  44.94   1.00   1.00  58.28  -6.54
+S   1.00  33.60   1.00  20.26   1.00   1.00   1.00   1.00   1.00   1.00   1.00   1.00  44.94  20.26  20.26  20.26   1.00   1.00   1.00   1.00
+T   1.00   1.00   1.00  20.26  13.34  -7.49   1.00   1.00   1.00   1.00   1.00 -14.03   1.00  -6.54   1.00   1.00   1.00   1.00 -14.03   1.00
+V   1.00   1.00 -14.03   1.00   1.00  -7.49   1.00   1.00  -1.88   1.00   1.00   1.00  20.26   1.00   1.00   1.00  -7.49   1.00   1.00  -6.54
+W -14.03   1.00   1.00   1.00   1.00  -9.37  24.68   1.00   1.00  13.34  24.68  13.34   1.00   1.00   1.00   1.00 -14.03  -7.49   1.00   1.00
+Y  24.68   1.00  24.68  -6.54   1.00  -7.49  13.34   1.00   1.00   1.00  44.94   1.00  13.34   1.00 -15.91   1.00  -7.49   1.00  -9.37  13.34
+`
+
+var dipeptideWeight map[string]float64
+
+// cleanProtein removes gaps, stops, and other non-residue characters
+func cleanProtein(str string) string {
+
+	str = strings.ToUpper(str)
+
+	var buffer strings.Builder
+
+	for _, ch := range str {
+		if ch >= 'A' && ch <= 'Z' {
+			buffer.WriteRune(ch)
+		}
+	}
+
+	return buffer.String()
+}
+
+// proteinCharge computes net charge at a given pH with the Henderson-Hasselbalch equation
+func proteinCharge(str string, ph float64) float64 {
+
+	first := rune(str[0])
+	last := rune(str[len(str)-1])
+
+	nterm, ok := nTermPK[first]
+	if !ok {
+		nterm = 7.5
+	}
+	cterm, ok := cTermPK[last]
+	if !ok {
+		cterm = 3.55
+	}
+
+	pos := 1.0 / (1.0 + math.Pow(10, ph-nterm))
+	neg := 1.0 / (1.0 + math.Pow(10, cterm-ph))
+
+	for _, ch := range str {
+		if pk, ok := sidePositive[ch]; ok {
+			pos += 1.0 / (1.0 + math.Pow(10, ph-pk))
+		} else if pk, ok := sideNegative[ch]; ok {
+			neg += 1.0 / (1.0 + math.Pow(10, pk-ph))
+		}
+	}
+
+	return pos - neg
+}
+
+// ProteinIsoelectricPoint finds the pH at which the net charge is zero by bisection
+func ProteinIsoelectricPoint(str string) string {
+
+	str = cleanProtein(str)
+	if str == "" {
+		return ""
+	}
+
+	lo := 0.0
+	hi := 14.0
+
+	for hi-lo > 0.0001 {
+		mid := (lo + hi) / 2
+		if proteinCharge(str, mid) > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return strconv.FormatFloat((lo+hi)/2, 'f', 2, 64)
+}
+
+// ProteinExtinction calculates the molar extinction coefficient at 280 nm, using
+// Pace values, with cysteines either paired as cystines or all reduced
+func ProteinExtinction(str string, reduced bool) string {
+
+	str = cleanProtein(str)
+	if str == "" {
+		return ""
+	}
+
+	trp := strings.Count(str, "W")
+	tyr := strings.Count(str, "Y")
+	cys := strings.Count(str, "C")
+
+	ext := 5500*trp + 1490*tyr
+	if !reduced {
+		ext += 125 * (cys / 2)
+	}
+
+	return strconv.Itoa(ext)
+}
+
+// ProteinHydropathy calculates the grand average of hydropathy (GRAVY)
+func ProteinHydropathy(str string) string {
+
+	str = cleanProtein(str)
+
+	sum := 0.0
+	count := 0
+
+	for _, ch := range str {
+		if val, ok := hydropathy[ch]; ok {
+			sum += val
+			count++
+		}
+	}
+
+	if count == 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(sum/float64(count), 'f', 3, 64)
+}
+
+// ProteinInstability calculates the Guruprasad instability index, where values
+// above 40 predict that a protein is unstable in vitro
+func ProteinInstability(str string) string {
+
+	str = cleanProtein(str)
+	if len(str) < 2 {
+		return ""
+	}
+
+	sum := 0.0
+
+	for i := 0; i+1 < len(str); i++ {
+		// pairs with non-standard residues contribute nothing
+		sum += dipeptideWeight[str[i:i+2]]
+	}
+
+	return strconv.FormatFloat(10.0*sum/float64(len(str)), 'f', 2, 64)
+}
+
+// ProteinComposition returns the percentage of each standard amino acid,
+// formatted as residue letter and value, e.g., "A:8.2"
+func ProteinComposition(str string) []string {
+
+	str = cleanProtein(str)
+	if str == "" {
+		return nil
+	}
+
+	var arry []string
+
+	for _, ch := range standardResidues {
+		pct := 100.0 * float64(strings.Count(str, string(ch))) / float64(len(str))
+		arry = append(arry, string(ch)+":"+strconv.FormatFloat(pct, 'f', 1, 64))
+	}
+
+	return arry
+}
+
+// initialize dipeptide weights from the instability table
+func init() {
+
+	dipeptideWeight = make(map[string]float64)
+
+	lines := strings.Split(strings.TrimSpace(dipeptideTable), "\n")
+	cols := strings.Fields(lines[0])
+
+	for _, line := range lines[1:] {
+		flds := strings.Fields(line)
+		if len(flds) != len(cols)+1 {
+			continue
+		}
+		for k, str := range flds[1:] {
+			val, err := strconv.ParseFloat(str, 64)
+			if err == nil {
+				dipeptideWeight[flds[0]+cols[k]] = val
+			}
+		}
+	}
+}
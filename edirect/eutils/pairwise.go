@@ -0,0 +1,414 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  pairwise.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PAIRWISE SEQUENCE ALIGNMENT
+
+// Global and local alignments use the Needleman-Wunsch and Smith-Waterman algorithms,
+// with affine gap penalties computed by the three-matrix method described in Osamu
+// Gotoh, An Improved Algorithm for Matching Biological Sequences, Journal of Molecular
+// Biology, 1982, 162:705-708.
+
+// BLOSUM62 substitution matrix for protein alignment
+const blosum62 = `
+   A  R  N  D  C  Q  E  G  H  I  L  K  M  F  P  S  T  W  Y  V  B  Z  X  *
+A  4 -1 -2 -2  0 -1 -1  0 -2 -1 -1 -1 -1 -2 -1  1  0 -3 -2  0 -2 -1  0 -4
+R -1  5  0 -2 -3  1  0 -2  0 -3 -2  2 -1 -3 -2 -1 -1 -3 -2 -3 -1  0 -1 -4
+N -2  0  6  1 -3  0  0  0  1 -3 -3  0 -2 -3 -2  1  0 -4 -2 -3  3  0 -1 -4
+D -2 -2  1  6 -3  0  2 -1 -1 -3 -4 -1 -3 -3 -1  0 -1 -4 -3 -3  4  1 -1 -4
+C  0 -3 -3 -3  9 -3 -4 -3 -3 -1 -1 -3 -1 -2 -3 -1 -1 -2 -2 -1 -3 -3 -2 -4
+Q -1  1  0  0 -3  5  2 -2  0 -3 -2  1  0 -3 -1  0 -1 -2 -1 -2  0  3 -1 -4
+E -1  0  0  2 -4  2  5 -2  0 -3 -3  1 -2 -3 -1  0 -1 -3 -2 -2  1  4 -1 -4
+G  0 -2  0 -1 -3 -2 -2  6 -2 -4 -4 -2 -3 -3 -2  0 -2 -2 -3 -3 -1 -2 -1 -4
+H -2  0  1 -1 -3  0  0 -2  8 -3 -3 -1 -2 -1 -2 -1 -2 -2  2 -3  0  0 -1 -4
+I -1 -3 -3 -3 -1 -3 -3 -4 -3  4  2 -3  1  0 -3 -2 -1 -3 -1  3 -3 -3 -1 -4
+L -1 -2 -3 -4 -1 -2 -3 -4 -3  2  4 -2  2  0 -3 -2 -1 -2 -1  1 -4 -3 -1 -4
+K -1  2  0 -1 -3  1  1 -2 -1 -3 -2  5 -1 -3 -1  0 -1 -3 -2 -2  0  1 -1 -4
+M -1 -1 -2 -3 -1  0 -2 -3 -2  1  2 -1  5  0 -2 -1 -1 -1 -1  1 -3 -1 -1 -4
+F -2 -3 -3 -3 -2 -3 -3 -3 -1  0  0 -3  0  6 -4 -2 -2  1  3 -1 -3 -3 -1 -4
+P -1 -2 -2 -1 -3 -1 -1 -2 -2 -3 -3 -1 -2 -4  7 -1 -1 -4 -3 -2 -2 -1 -2 -4
+S  1 -1  1  0 -1  0  0  0 -1 -2 -2  0 -1 -2 -1  4  1 -3 -2 -2  0  0  0 -4
+T  0 -1  0 -1 -1 -1 -1 -2 -2 -1 -1 -1 -1 -2 -1  1  5 -2 -2  0 -1 -1  0 -4
+W -3 -3 -4 -4 -2 -2 -3 -2 -2 -3 -2 -3 -1  1 -4 -3 -2 11  2 -3 -4 -3 -2 -4
+Y -2 -2 -2 -3 -2 -1 -2 -3  2 -1 -1 -2 -1  3 -3 -2 -2  2  7 -1 -3 -2 -1 -4
+V  0 -3 -3 -3 -1 -2 -2 -3 -3  3  1 -2  1 -1 -2 -2  0 -3 -1  4 -3 -2 -1 -4
+B -2 -1  3  4 -3  0  1 -1  0 -3 -4  0 -3 -3 -2  0 -1 -4 -3 -3  4  1 -1 -4
+Z -1  0  0  1 -3  3  4 -2  0 -3 -3  1 -1 -3 -1  0 -1 -3 -2 -2  1  4 -1 -4
+X  0 -1 -1 -1 -2 -1 -1 -1 -1 -1 -1 -1 -1 -1 -2  0  0 -2 -1 -1 -1 -1 -1 -4
+* -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4 -4  1
+`
+
+// AlignScoring holds substitution scores and affine gap penalties, where a gap of
+// length n costs GapOpen + n * GapExtend
+type AlignScoring struct {
+	Protein   bool
+	Match     int
+	Mismatch  int
+	GapOpen   int
+	GapExtend int
+}
+
+// DefaultScoring returns BLOSUM62 with 11/1 gap costs for protein, and +2/-3 with 5/2
+// gap costs for nucleotide, the same defaults used by blastp and blastn
+func DefaultScoring(isProtein bool) AlignScoring {
+
+	if isProtein {
+		return AlignScoring{Protein: true, GapOpen: 11, GapExtend: 1}
+	}
+
+	return AlignScoring{Match: 2, Mismatch: -3, GapOpen: 5, GapExtend: 2}
+}
+
+// scoreTable expands the scoring parameters into a lookup table indexed by upper-case letters
+func (scr AlignScoring) scoreTable() *[128][128]int {
+
+	var tbl [128][128]int
+
+	if !scr.Protein {
+		for i := 0; i < 128; i++ {
+			for j := 0; j < 128; j++ {
+				tbl[i][j] = scr.Mismatch
+			}
+			tbl[i][i] = scr.Match
+		}
+		// uracil aligns with thymine
+		tbl['T']['U'] = scr.Match
+		tbl['U']['T'] = scr.Match
+		return &tbl
+	}
+
+	lines := strings.Split(strings.TrimSpace(blosum62), "\n")
+	cols := strings.Fields(lines[0])
+
+	// letters missing from the matrix score as X
+	for i := 0; i < 128; i++ {
+		for j := 0; j < 128; j++ {
+			tbl[i][j] = -1
+		}
+	}
+
+	for _, line := range lines[1:] {
+		flds := strings.Fields(line)
+		if len(flds) != len(cols)+1 {
+			continue
+		}
+		row := flds[0][0]
+		for k, str := range flds[1:] {
+			val, err := strconv.Atoi(str)
+			if err == nil {
+				tbl[row][cols[k][0]] = val
+			}
+		}
+	}
+
+	return &tbl
+}
+
+// PairwiseAlignment contains a gapped alignment, with 1-based coordinates of the aligned
+// region in each sequence, and an extended CIGAR string with the first sequence as reference
+type PairwiseAlignment struct {
+	Score      int
+	First      string
+	Second     string
+	FirstFrom  int
+	FirstTo    int
+	SecondFrom int
+	SecondTo   int
+	Identities int
+	Gaps       int
+	Length     int
+	Cigar      string
+}
+
+// traceback flags for affine gap alignment
+const (
+	fromDiag   = 0
+	fromDel    = 1
+	fromIns    = 2
+	fromStart  = 3
+	delExtend  = 4
+	delFromIns = 8
+	insExtend  = 16
+	insFromDel = 32
+)
+
+// AlignPair computes a global or local alignment of two nucleotide or protein sequences
+func AlignPair(frst, scnd string, scr AlignScoring, local bool) *PairwiseAlignment {
+
+	frst = strings.ToUpper(frst)
+	scnd = strings.ToUpper(scnd)
+
+	n := len(frst)
+	m := len(scnd)
+
+	if n == 0 || m == 0 {
+		return nil
+	}
+
+	tbl := scr.scoreTable()
+
+	score := func(a, b byte) int {
+		if a >= 128 || b >= 128 {
+			return scr.Mismatch
+		}
+		return tbl[a][b]
+	}
+
+	// negative infinity that cannot overflow when penalties are subtracted
+	const minusInf = -(1 << 30)
+
+	open := scr.GapOpen + scr.GapExtend
+	ext := scr.GapExtend
+
+	// diag aligns two letters, del consumes a letter of the first sequence against a gap,
+	// and ins consumes a letter of the second sequence against a gap
+	diag := make([]int, m+1)
+	del := make([]int, m+1)
+	ins := make([]int, m+1)
+	prevDiag := make([]int, m+1)
+	prevDel := make([]int, m+1)
+	prevIns := make([]int, m+1)
+
+	trace := make([][]byte, n+1)
+	for i := range trace {
+		trace[i] = make([]byte, m+1)
+	}
+
+	prevDiag[0] = 0
+	prevDel[0] = minusInf
+	prevIns[0] = minusInf
+	for j := 1; j <= m; j++ {
+		prevDiag[j] = minusInf
+		prevDel[j] = minusInf
+		prevIns[j] = -(scr.GapOpen + j*ext)
+		trace[0][j] = insExtend
+		if local {
+			prevDiag[j] = 0
+			prevIns[j] = minusInf
+			trace[0][j] = fromStart
+		}
+	}
+
+	bestScore := 0
+	bestI := 0
+	bestJ := 0
+
+	for i := 1; i <= n; i++ {
+
+		diag[0] = minusInf
+		del[0] = -(scr.GapOpen + i*ext)
+		ins[0] = minusInf
+		trace[i][0] = delExtend
+		if local {
+			diag[0] = 0
+			del[0] = minusInf
+			trace[i][0] = fromStart
+		}
+
+		for j := 1; j <= m; j++ {
+
+			var flags byte
+
+			// best predecessor for aligning frst[i-1] with scnd[j-1]
+			best := prevDiag[j-1]
+			if prevDel[j-1] > best {
+				best = prevDel[j-1]
+				flags = fromDel
+			}
+			if prevIns[j-1] > best {
+				best = prevIns[j-1]
+				flags = fromIns
+			}
+			if local && best < 0 {
+				best = 0
+				flags = fromStart
+			}
+			diag[j] = best + score(frst[i-1], scnd[j-1])
+
+			// gap in second sequence
+			dl := prevDiag[j] - open
+			if prevDel[j]-ext >= dl {
+				dl = prevDel[j] - ext
+				flags |= delExtend
+			} else if prevIns[j]-open > dl {
+				dl = prevIns[j] - open
+				flags |= delFromIns
+			}
+			del[j] = dl
+
+			// gap in first sequence
+			in := diag[j-1] - open
+			if ins[j-1]-ext >= in {
+				in = ins[j-1] - ext
+				flags |= insExtend
+			} else if del[j-1]-open > in {
+				in = del[j-1] - open
+				flags |= insFromDel
+			}
+			ins[j] = in
+
+			trace[i][j] = flags
+
+			if local && diag[j] > bestScore {
+				bestScore = diag[j]
+				bestI = i
+				bestJ = j
+			}
+		}
+
+		diag, prevDiag = prevDiag, diag
+		del, prevDel = prevDel, del
+		ins, prevIns = prevIns, ins
+	}
+
+	// state 0 is diagonal, 1 is deletion, 2 is insertion
+	state := 0
+	i, j := n, m
+
+	if local {
+		if bestScore <= 0 {
+			return nil
+		}
+		i, j = bestI, bestJ
+	} else {
+		// last row is now in the previous arrays
+		bestScore = prevDiag[m]
+		if prevDel[m] > bestScore {
+			bestScore = prevDel[m]
+			state = 1
+		}
+		if prevIns[m] > bestScore {
+			bestScore = prevIns[m]
+			state = 2
+		}
+	}
+
+	lastI := i
+	lastJ := j
+
+	var top []byte
+	var bot []byte
+	var ops []byte
+
+	identities := 0
+	gaps := 0
+
+	// follow traceback flags to the start of the alignment
+	for i > 0 || j > 0 {
+
+		flags := trace[i][j]
+
+		if state == 0 {
+			if i == 0 || j == 0 {
+				break
+			}
+			a := frst[i-1]
+			b := scnd[j-1]
+			top = append(top, a)
+			bot = append(bot, b)
+			if a == b || !scr.Protein && score(a, b) == scr.Match {
+				ops = append(ops, '=')
+				identities++
+			} else {
+				ops = append(ops, 'X')
+			}
+			i--
+			j--
+			if flags&3 == fromStart {
+				// local alignment begins here
+				break
+			}
+			state = int(flags & 3)
+			if local && (i == 0 || j == 0) {
+				break
+			}
+		} else if state == 1 {
+			if i == 0 {
+				break
+			}
+			top = append(top, frst[i-1])
+			bot = append(bot, '-')
+			ops = append(ops, 'D')
+			gaps++
+			i--
+			if flags&delExtend == 0 {
+				state = 0
+				if flags&delFromIns != 0 {
+					state = 2
+				}
+			}
+		} else {
+			if j == 0 {
+				break
+			}
+			top = append(top, '-')
+			bot = append(bot, scnd[j-1])
+			ops = append(ops, 'I')
+			gaps++
+			j--
+			if flags&insExtend == 0 {
+				state = 0
+				if flags&insFromDel != 0 {
+					state = 1
+				}
+			}
+		}
+	}
+
+	// alignment was collected from the end
+	reverse := func(arry []byte) {
+		for l, r := 0, len(arry)-1; l < r; l, r = l+1, r-1 {
+			arry[l], arry[r] = arry[r], arry[l]
+		}
+	}
+	reverse(top)
+	reverse(bot)
+	reverse(ops)
+
+	return &PairwiseAlignment{
+		Score:      bestScore,
+		First:      string(top),
+		Second:     string(bot),
+		FirstFrom:  i + 1,
+		FirstTo:    lastI,
+		SecondFrom: j + 1,
+		SecondTo:   lastJ,
+		Identities: identities,
+		Gaps:       gaps,
+		Length:     len(ops),
+		Cigar:      compressCigar(ops),
+	}
+}
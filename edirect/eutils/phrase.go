@@ -500,13 +500,11 @@ func evaluateQuery(base, dbase, phrase string, clauses []string, noStdout, isLin
 		clauses = clauses[1:]
 
 		if tkn == "(" && prevTkn != "" && prevTkn != "&" && prevTkn != "|" && prevTkn != "!" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Tokens '%s' and '%s' should be separated by AND, OR, or NOT\n", prevTkn, tkn)
-			os.Exit(1)
+			queryFailure("Tokens '%s' and '%s' should be separated by AND, OR, or NOT", prevTkn, tkn)
 		}
 
 		if prevTkn == ")" && tkn != "" && tkn != "&" && tkn != "|" && tkn != "!" && tkn != ")" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Tokens '%s' and '%s' should be separated by AND, OR, or NOT\n", prevTkn, tkn)
-			os.Exit(1)
+			queryFailure("Tokens '%s' and '%s' should be separated by AND, OR, or NOT", prevTkn, tkn)
 		}
 
 		prevTkn = tkn
@@ -538,18 +536,14 @@ func evaluateQuery(base, dbase, phrase string, clauses []string, noStdout, isLin
 			if tkn == ")" {
 				tkn = nextToken()
 			} else {
-				fmt.Fprintf(os.Stderr, "\nERROR: Expected ')' but received '%s'\n", tkn)
-				os.Exit(1)
+				queryFailure("Expected ')' but received '%s'", tkn)
 			}
 		} else if tkn == ")" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unexpected ')' token\n")
-			os.Exit(1)
+			queryFailure("Unexpected ')' token")
 		} else if tkn == "&" || tkn == "|" || tkn == "!" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unexpected operator '%s' in expression\n", tkn)
-			os.Exit(1)
+			queryFailure("Unexpected operator '%s' in expression", tkn)
 		} else if tkn == "" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unexpected end of expression in '%s'\n", phrase)
-			os.Exit(1)
+			queryFailure("Unexpected end of expression in '%s'", phrase)
 		} else {
 			// evaluate current phrase
 			data, ofst, delta = eval(tkn)
@@ -632,8 +626,7 @@ func evaluateQuery(base, dbase, phrase string, clauses []string, noStdout, isLin
 	result, tkn := expr()
 
 	if tkn != "" {
-		fmt.Fprintf(os.Stderr, "\nERROR: Unexpected token '%s' at end of expression\n", tkn)
-		os.Exit(1)
+		queryFailure("Unexpected token '%s' at end of expression", tkn)
 	}
 
 	// sort final result
@@ -1107,8 +1100,7 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 			// check for year wildcard
 			if len(str) == 4 && str[3] == '*' && IsAllDigitsOrPeriod(str[:3]) {
 
-				fmt.Fprintf(os.Stderr, "\nERROR: Wildcards not supported for years - use ####:#### range instead\n")
-				os.Exit(1)
+				queryFailure("Wildcards not supported for years - use ####:#### range instead")
 			}
 
 			// allow year month day to look for unexpected annotation
@@ -1121,13 +1113,11 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 			if len(str) == 9 && str[4] == ' ' && IsAllDigitsOrPeriod(str[:4]) && IsAllDigitsOrPeriod(str[5:]) {
 				start, err := strconv.Atoi(str[:4])
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize starting year '%s'\n", str[:4])
-					os.Exit(1)
+					queryFailure("Unable to recognize starting year '%s'", str[:4])
 				}
 				stop, err := strconv.Atoi(str[5:])
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize stopping year '%s'\n", str[5:])
-					os.Exit(1)
+					queryFailure("Unable to recognize stopping year '%s'", str[5:])
 				}
 				if start > stop {
 					continue
@@ -1146,8 +1136,7 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 				continue
 			}
 
-			fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize year expression '%s'\n", str)
-			os.Exit(1)
+			queryFailure("Unable to recognize year expression '%s'", str)
 
 		} else if strings.HasSuffix(str, " [ANUM]") ||
 			strings.HasSuffix(str, " [INUM]") ||
@@ -1167,8 +1156,7 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 			rgt = strings.TrimSpace(rgt)
 
 			if lft == "" && rgt == "" {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize expression '%s'\n", str)
-				os.Exit(1)
+				queryFailure("Unable to recognize expression '%s'", str)
 			}
 
 			// regular integer
@@ -1176,32 +1164,27 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 				// check for wildcard
 				if strings.HasSuffix(lft, "*") {
 
-					fmt.Fprintf(os.Stderr, "\nERROR: Wildcards not supported - use #:# range instead\n")
-					os.Exit(1)
+					queryFailure("Wildcards not supported - use #:# range instead")
 				}
 				if IsAllDigits(lft) {
 					res = append(res, str)
 					continue
 				}
-				fmt.Fprintf(os.Stderr, "\nERROR: Field %s must be an integer\n", fld)
-				os.Exit(1)
+				queryFailure("Field %s must be an integer", fld)
 			}
 
 			// check for integer range
 			if !IsAllDigits(lft) || !IsAllDigits(rgt) {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize expression '%s'\n", str)
-				os.Exit(1)
+				queryFailure("Unable to recognize expression '%s'", str)
 			}
 
 			start, err := strconv.Atoi(lft)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize starting number '%s'\n", lft)
-				os.Exit(1)
+				queryFailure("Unable to recognize starting number '%s'", lft)
 			}
 			stop, err := strconv.Atoi(rgt)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize ending number '%s'\n", rgt)
-				os.Exit(1)
+				queryFailure("Unable to recognize ending number '%s'", rgt)
 			}
 			if start > stop {
 				// put into proper order
@@ -1244,8 +1227,7 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 				continue
 			}
 
-			fmt.Fprintf(os.Stderr, "\nERROR: Unable to recognize mesh code expression '%s'\n", str)
-			os.Exit(1)
+			queryFailure("Unable to recognize mesh code expression '%s'", str)
 
 		} else if strings.HasSuffix(str, " [JOUR]") {
 
@@ -1347,9 +1329,36 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 
 // SEARCH TERM LISTS FOR PHRASES OR NORMALIZED TERMS, OR MATCH BY PATTERN
 
+// queryError carries a malformed query message from the parser back to an entry point
+type queryError struct {
+	msg string
+}
+
+// queryFailure abandons query evaluation, the message is printed by exitOnQueryError
+// for command-line tools or returned by SearchQuery for the network server
+func queryFailure(format string, args ...interface{}) {
+
+	panic(&queryError{msg: fmt.Sprintf(format, args...)})
+}
+
+// exitOnQueryError is deferred by command-line entry points to report a malformed query and exit
+func exitOnQueryError() {
+
+	if r := recover(); r != nil {
+		qe, ok := r.(*queryError)
+		if !ok {
+			panic(r)
+		}
+		fmt.Fprintf(os.Stderr, "\nERROR: %s\n", qe.msg)
+		os.Exit(1)
+	}
+}
+
 // ProcessSearch evaluates query, returns list of PMIDs to stdout
 func ProcessSearch(base, dbase, phrase string, xact, titl, rlxd, isLink, deStop bool) int {
 
+	defer exitOnQueryError()
+
 	if phrase == "" {
 		return 0
 	}
@@ -1391,6 +1400,8 @@ func ProcessSearch(base, dbase, phrase string, xact, titl, rlxd, isLink, deStop
 // ProcessQuery evaluates query, returns list of PMIDs in array
 func ProcessQuery(base, dbase, phrase string, xact, titl, rlxd, isLink, deStop bool) []int32 {
 
+	defer exitOnQueryError()
+
 	if phrase == "" {
 		return nil
 	}
@@ -1429,9 +1440,65 @@ func ProcessQuery(base, dbase, phrase string, xact, titl, rlxd, isLink, deStop b
 	return arry
 }
 
+// SearchResult has the normalized query clauses and matching UIDs, in UID order, or
+// ordered by relevance score if ranking was requested
+type SearchResult struct {
+	Clauses []string
+	UIDs    []int32
+	Ranked  []RankedUID
+}
+
+// SearchQuery evaluates a query for the network server, returning malformed query
+// messages as an error instead of exiting
+func SearchQuery(base, dbase, phrase string, rank bool, top int, deStop bool) (res SearchResult, err error) {
+
+	if phrase == "" {
+		return res, fmt.Errorf("empty query")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			qe, ok := r.(*queryError)
+			if !ok {
+				panic(r)
+			}
+			res = SearchResult{}
+			err = fmt.Errorf("%s", qe.msg)
+		}
+	}()
+
+	phrase = prepareQuery(phrase)
+
+	phrase = processStopWords(phrase, deStop)
+
+	clauses := partitionQuery(phrase)
+
+	clauses = setFieldQualifiers(clauses, false)
+
+	// network callers have no stdin, do not let [PIPE] read the server's input
+	for _, item := range clauses {
+		if strings.Contains(strings.ToUpper(item), "[PIPE]") {
+			queryFailure("[PIPE] field is not supported in this context")
+		}
+	}
+
+	_, arry := evaluateQuery(base, dbase, phrase, clauses, true, false)
+
+	res.Clauses = clauses
+	res.UIDs = arry
+
+	if rank {
+		res.Ranked = rankResults(base, dbase, clauses, arry, top)
+	}
+
+	return res, nil
+}
+
 // ProcessMock shows individual steps in processing query for evaluation
 func ProcessMock(base, dbase, phrase string, xact, titl, rlxd, deStop bool) int {
 
+	defer exitOnQueryError()
+
 	if phrase == "" {
 		return 0
 	}
@@ -1482,6 +1549,8 @@ func ProcessMock(base, dbase, phrase string, xact, titl, rlxd, deStop bool) int
 // ProcessCount prints document count for each term, also supports terminal wildcards
 func ProcessCount(base, dbase, phrase string, plrl, psns, rlxd, deStop bool) int {
 
+	defer exitOnQueryError()
+
 	if phrase == "" {
 		return 0
 	}
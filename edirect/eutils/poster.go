@@ -1237,6 +1237,13 @@ func excludeIDs(N, M []int32) []int32 {
 		}
 	}
 
+	// copy items remaining after second list is exhausted
+	for i < n {
+		res[k] = N[i]
+		k++
+		i++
+	}
+
 	// truncate output array to actual size of result
 	res = res[:k]
 
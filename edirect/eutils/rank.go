@@ -0,0 +1,322 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  rank.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RANKED RETRIEVAL BY BM25 SCORING OF BOOLEAN QUERY RESULTS
+
+// BM25 term frequency saturation, document lengths are not saved in the
+// postings, so the length normalization parameter b is effectively zero
+const bm25K1 = 1.2
+
+// rankWeights favors words in the title over words anywhere in the title or abstract
+var rankWeights = map[string]float64{
+	"TITL": 3.0,
+	"TIAB": 1.0,
+}
+
+// RankedUID is a query result with its relevance score
+type RankedUID struct {
+	UID   int32
+	Score float64
+}
+
+var (
+	docCountLock  sync.Mutex
+	docCountCache = make(map[string]int)
+)
+
+// documentCount sums the postings of all YEAR terms, since each live record has exactly one
+// publication year, with the total obtained from the phantom last entry of each master index
+func documentCount(base string) int {
+
+	docCountLock.Lock()
+	defer docCountLock.Unlock()
+
+	if num, ok := docCountCache[base]; ok {
+		return num
+	}
+
+	total := 0
+
+	filepath.Walk(filepath.Join(base, "YEAR"), func(path string, info os.FileInfo, err error) error {
+
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".YEAR.mst") {
+			return nil
+		}
+
+		inFile, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+
+		defer inFile.Close()
+
+		size := info.Size()
+		if size < 8 {
+			return nil
+		}
+
+		var last Master
+		_, err = inFile.Seek(size-8, io.SeekStart)
+		if err != nil {
+			return nil
+		}
+		err = binary.Read(inFile, binary.LittleEndian, &last)
+		if err != nil {
+			return nil
+		}
+
+		total += int(last.PostOffset / 4)
+
+		return nil
+	})
+
+	docCountCache[base] = total
+
+	return total
+}
+
+// rankingTerms collects the words of positive text clauses, skipping negated
+// clauses, wildcards, and fields without term positions
+func rankingTerms(clauses []string) []string {
+
+	var terms []string
+
+	seen := make(map[string]bool)
+
+	negate := false
+	depth := 0
+
+	for _, str := range clauses {
+
+		switch {
+		case str == "!":
+			negate = true
+			continue
+		case str == "(":
+			if negate {
+				depth++
+			}
+			continue
+		case str == ")":
+			if negate && depth > 0 {
+				depth--
+				if depth == 0 {
+					negate = false
+				}
+			}
+			continue
+		case str == "&" || str == "|" || strings.HasPrefix(str, "~"):
+			continue
+		}
+
+		if negate {
+			// single excluded clause, or clause inside excluded parentheses
+			if depth == 0 {
+				negate = false
+			}
+			continue
+		}
+
+		field := "TIAB"
+		if strings.HasSuffix(str, "]") {
+			pos := strings.Index(str, "[")
+			if pos >= 0 {
+				field = strings.ToUpper(strings.Trim(str[pos:], "[]"))
+				str = strings.TrimSpace(str[:pos])
+			}
+		}
+
+		switch field {
+		case "TIAB", "TITL", "NORM", "TEXT":
+		default:
+			continue
+		}
+
+		for _, wrd := range strings.Fields(str) {
+			if strings.HasPrefix(wrd, "+") || strings.Contains(wrd, "*") {
+				continue
+			}
+			wrd = strings.Replace(wrd, "_", " ", -1)
+			if seen[wrd] {
+				continue
+			}
+			seen[wrd] = true
+			terms = append(terms, wrd)
+		}
+	}
+
+	return terms
+}
+
+// rankResults scores Boolean query results by BM25, with term frequencies combined
+// across weighted fields before saturation, returning the top results
+func rankResults(base, dbase string, clauses []string, uids []int32, top int) []RankedUID {
+
+	if len(uids) < 1 {
+		return nil
+	}
+
+	scores := make(map[int32]float64, len(uids))
+	for _, uid := range uids {
+		scores[uid] = 0
+	}
+
+	// full text index for PMC has no separate title field
+	fields := []string{"TITL", "TIAB"}
+	if dbase == "pmc" {
+		fields = []string{"TEXT"}
+	}
+
+	numDocs := float64(documentCount(base))
+	if numDocs < 1 {
+		// PMIDs are assigned sequentially, so the largest one approximates the collection size
+		numDocs = float64(uids[len(uids)-1])
+	}
+
+	for _, term := range rankingTerms(clauses) {
+
+		freqs := make(map[int32]float64)
+		docFreq := 0
+
+		for _, field := range fields {
+
+			weight, ok := rankWeights[field]
+			if !ok {
+				weight = 1.0
+			}
+
+			data, ofst := getPostingIDs(base, term, field, false, false)
+
+			if field != "TITL" {
+				// document frequency from the broadest field
+				docFreq = len(data)
+			}
+
+			for i, uid := range data {
+				if _, ok := scores[uid]; !ok {
+					continue
+				}
+				// number of positions is the term frequency
+				tf := 1
+				if i < len(ofst) && len(ofst[i]) > 0 {
+					tf = len(ofst[i])
+				}
+				freqs[uid] += weight * float64(tf)
+			}
+		}
+
+		if docFreq < 1 {
+			continue
+		}
+
+		df := float64(docFreq)
+		n := math.Max(numDocs, df)
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for uid, tf := range freqs {
+			scores[uid] += idf * tf * (bm25K1 + 1) / (tf + bm25K1)
+		}
+	}
+
+	ranked := make([]RankedUID, 0, len(scores))
+	for uid, score := range scores {
+		ranked = append(ranked, RankedUID{UID: uid, Score: score})
+	}
+
+	// highest score first, newer records break ties
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].UID > ranked[j].UID
+	})
+
+	if top > 0 && len(ranked) > top {
+		ranked = ranked[:top]
+	}
+
+	return ranked
+}
+
+// ProcessRanked evaluates query, returns top PMIDs ordered by BM25 relevance score
+func ProcessRanked(base, dbase, phrase string, xact, titl, rlxd, deStop bool, top int) []RankedUID {
+
+	defer exitOnQueryError()
+
+	if phrase == "" {
+		return nil
+	}
+
+	if base == "" {
+		// obtain path from environment variable within rchive as a convenience
+		base = os.Getenv("EDIRECT_PUBMED_MASTER")
+		if base != "" {
+			if !strings.HasSuffix(base, "/") {
+				base += "/"
+			}
+			base += "Postings"
+		}
+	}
+
+	if titl {
+		phrase = prepareExact(phrase, "[titl]", deStop)
+	} else if xact {
+		if dbase == "pmc" {
+			phrase = prepareExact(phrase, "[text]", deStop)
+		} else {
+			phrase = prepareExact(phrase, "[tiab]", deStop)
+		}
+	} else {
+		phrase = prepareQuery(phrase)
+	}
+
+	phrase = processStopWords(phrase, deStop)
+
+	clauses := partitionQuery(phrase)
+
+	clauses = setFieldQualifiers(clauses, rlxd)
+
+	_, arry := evaluateQuery(base, dbase, phrase, clauses, true, false)
+
+	return rankResults(base, dbase, clauses, arry, top)
+}
@@ -0,0 +1,966 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  schema.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DTD AND XSD CONTENT MODELS FOR XTRACT -verify
+
+// schemaAttr is a declared attribute, with optional enumerated or fixed values
+type schemaAttr struct {
+	Name     string
+	Required bool
+	Fixed    string
+	Values   []string
+}
+
+// schemaElem is a declared element, children are matched as a sequence of
+// space-terminated names against a regular expression compiled from the content model
+type schemaElem struct {
+	Name    string
+	Any     bool
+	Empty   bool
+	Mixed   bool
+	Pattern string
+	Model   *regexp.Regexp
+	Attrs   map[string]*schemaAttr
+	AnyAttr bool
+}
+
+// XMLSchema holds element declarations read from a DTD or XSD, element names are treated as global
+type XMLSchema struct {
+	Elements map[string]*schemaElem
+}
+
+// newSchemaElem returns an element that allows any content until a declaration is seen
+func newSchemaElem(name string) *schemaElem {
+
+	return &schemaElem{Name: name, Attrs: make(map[string]*schemaAttr)}
+}
+
+// compileModel builds the anchored regular expression for a content model
+func (elm *schemaElem) compileModel() {
+
+	if elm.Any || elm.Empty || elm.Pattern == "" {
+		return
+	}
+
+	re, err := regexp.Compile("^(?:" + elm.Pattern + ")$")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to compile content model for <%s>: %s\n", elm.Name, err.Error())
+		os.Exit(1)
+	}
+	elm.Model = re
+}
+
+// modelName converts an element name into a regular expression atom
+func modelName(name string) string {
+
+	return "(?:" + regexp.QuoteMeta(name) + " )"
+}
+
+// modelRepeat applies occurrence limits to a regular expression atom
+func modelRepeat(atom, min, max string) string {
+
+	if min == "" {
+		min = "1"
+	}
+	if max == "" {
+		max = "1"
+	}
+
+	// regexp rejects repeat counts above 1000, so larger limits are not enforced
+	if num, err := strconv.Atoi(max); err == nil && num > 1000 {
+		max = "unbounded"
+	}
+	if num, err := strconv.Atoi(min); err == nil && num > 1000 {
+		min = "1000"
+	}
+
+	switch {
+	case min == "1" && max == "1":
+		return atom
+	case min == "0" && max == "1":
+		return atom + "?"
+	case min == "0" && max == "unbounded":
+		return atom + "*"
+	case min == "1" && max == "unbounded":
+		return atom + "+"
+	case max == "unbounded":
+		return atom + "{" + min + ",}"
+	}
+
+	return atom + "{" + min + "," + max + "}"
+}
+
+// IsMixed reports whether the schema allows text interspersed with child elements
+func (sch *XMLSchema) IsMixed(name string) bool {
+
+	elm, ok := sch.Elements[name]
+	if !ok {
+		return false
+	}
+
+	return elm.Any || elm.Mixed && elm.Pattern != ""
+}
+
+// CheckElement validates the attributes of one start tag, returning messages for each problem
+func (sch *XMLSchema) CheckElement(name, attr string) []string {
+
+	var errs []string
+
+	elm, ok := sch.Elements[name]
+	if !ok {
+		return append(errs, "<"+name+"> is not declared")
+	}
+
+	seen := make(map[string]bool)
+
+	attrs := ParseAttributes(attr)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		key := attrs[i]
+		val := attrs[i+1]
+		seen[key] = true
+
+		att, ok := elm.Attrs[key]
+		if !ok {
+			if elm.AnyAttr || elm.Any || key == "xmlns" || strings.HasPrefix(key, "xmlns:") || strings.HasPrefix(key, "xml:") || strings.HasPrefix(key, "xsi:") {
+				continue
+			}
+			errs = append(errs, "<"+name+"> attribute "+key+" is not declared")
+			continue
+		}
+
+		if att.Fixed != "" && val != att.Fixed {
+			errs = append(errs, "<"+name+"> attribute "+key+" must be '"+att.Fixed+"'")
+		}
+
+		if len(att.Values) > 0 {
+			found := false
+			for _, str := range att.Values {
+				if str == val {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, "<"+name+"> attribute "+key+" value '"+val+"' not in ("+strings.Join(att.Values, "|")+")")
+			}
+		}
+	}
+
+	for key, att := range elm.Attrs {
+		if att.Required && !seen[key] {
+			errs = append(errs, "<"+name+"> missing required attribute "+key)
+		}
+	}
+
+	return errs
+}
+
+// CheckContent validates the children and text of one element when its end tag is reached
+func (sch *XMLSchema) CheckContent(name string, children []string, hasText bool) []string {
+
+	var errs []string
+
+	elm, ok := sch.Elements[name]
+	if !ok || elm.Any {
+		return errs
+	}
+
+	if elm.Empty {
+		if len(children) > 0 || hasText {
+			errs = append(errs, "<"+name+"> must be empty")
+		}
+		return errs
+	}
+
+	if hasText && !elm.Mixed {
+		errs = append(errs, "Contents not allowed in <"+name+">")
+	}
+
+	if elm.Model == nil {
+		if len(children) > 0 && elm.Pattern == "" {
+			errs = append(errs, "<"+name+"> may not contain <"+children[0]+">")
+		}
+		return errs
+	}
+
+	seq := ""
+	if len(children) > 0 {
+		seq = strings.Join(children, " ") + " "
+	}
+
+	if !elm.Model.MatchString(seq) {
+		errs = append(errs, "<"+name+"> content ("+strings.Join(children, ", ")+") does not match model")
+	}
+
+	return errs
+}
+
+// DTD PARSER
+
+// maxEntityExpansions stops self-referential or exponentially nested parameter entities
+const maxEntityExpansions = 100000
+
+// expandDTD removes comments, handles conditional sections, and expands parameter entities
+func expandDTD(text, dir string, depth int) string {
+
+	if depth > 20 {
+		fmt.Fprintf(os.Stderr, "\nERROR: DTD parameter entities nested too deeply\n")
+		os.Exit(1)
+	}
+
+	// remove comments
+	for {
+		start := strings.Index(text, "<!--")
+		if start < 0 {
+			break
+		}
+		stop := strings.Index(text[start:], "-->")
+		if stop < 0 {
+			text = text[:start]
+			break
+		}
+		text = text[:start] + text[start+stop+3:]
+	}
+
+	entities := make(map[string]string)
+	expansions := 0
+
+	var buffer strings.Builder
+
+	for len(text) > 0 {
+
+		pos := strings.IndexAny(text, "<%")
+		if pos < 0 {
+			buffer.WriteString(text)
+			break
+		}
+		buffer.WriteString(text[:pos])
+		text = text[pos:]
+
+		if strings.HasPrefix(text, "<!ENTITY") {
+			// parameter entity declaration, general entities are not needed for content models
+			stop := declEnd(text)
+			decl := text[:stop]
+			text = text[stop:]
+
+			flds := strings.Fields(decl[len("<!ENTITY") : len(decl)-1])
+			if len(flds) < 3 || flds[0] != "%" {
+				continue
+			}
+			name := flds[1]
+			if _, ok := entities[name]; ok {
+				// first declaration is binding
+				continue
+			}
+			rest := strings.TrimSpace(decl[len("<!ENTITY") : len(decl)-1])
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, "%"))
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, name))
+			if strings.HasPrefix(rest, "SYSTEM") || strings.HasPrefix(rest, "PUBLIC") {
+				// external entity, read from file next to the DTD if available
+				quoted := quotedStrings(rest)
+				if len(quoted) > 0 {
+					fname := quoted[len(quoted)-1]
+					if !filepath.IsAbs(fname) {
+						fname = filepath.Join(dir, filepath.Base(fname))
+					}
+					data, err := os.ReadFile(fname)
+					if err == nil {
+						entities[name] = expandDTD(string(data), dir, depth+1)
+					} else {
+						entities[name] = ""
+					}
+				}
+				continue
+			}
+			quoted := quotedStrings(rest)
+			if len(quoted) > 0 {
+				entities[name] = quoted[0]
+			}
+			continue
+		}
+
+		if strings.HasPrefix(text, "<![") {
+			// conditional section, keyword may itself be an entity reference
+			open := strings.Index(text[3:], "[")
+			if open < 0 {
+				break
+			}
+			keyword := strings.TrimSpace(text[3 : 3+open])
+			if strings.HasPrefix(keyword, "%") {
+				keyword = strings.TrimSpace(entities[strings.TrimSuffix(keyword[1:], ";")])
+			}
+			body := text[3+open+1:]
+			stop := strings.Index(body, "]]>")
+			if stop < 0 {
+				break
+			}
+			if keyword == "INCLUDE" {
+				text = body[:stop] + body[stop+3:]
+			} else {
+				text = body[stop+3:]
+			}
+			continue
+		}
+
+		if text[0] == '%' {
+			semi := strings.Index(text, ";")
+			if semi > 1 && !strings.ContainsAny(text[1:semi], " \t\r\n") {
+				name := text[1:semi]
+				val, ok := entities[name]
+				text = text[semi+1:]
+				if ok {
+					expansions++
+					if expansions > maxEntityExpansions {
+						fmt.Fprintf(os.Stderr, "\nERROR: DTD parameter entity %%%s; expanded too many times, may be self-referential\n", name)
+						os.Exit(1)
+					}
+					// re-scan replacement text for nested references
+					text = " " + val + " " + text
+				}
+				continue
+			}
+		}
+
+		buffer.WriteByte(text[0])
+		text = text[1:]
+	}
+
+	return buffer.String()
+}
+
+// declEnd finds the closing angle bracket of a markup declaration, skipping quoted strings
+func declEnd(text string) int {
+
+	var quote byte
+
+	for i := 2; i < len(text); i++ {
+		ch := text[i]
+		if quote != 0 {
+			if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+		if ch == '"' || ch == '\'' {
+			quote = ch
+			continue
+		}
+		if ch == '>' {
+			return i + 1
+		}
+	}
+
+	return len(text)
+}
+
+// quotedStrings returns the contents of single- or double-quoted strings
+func quotedStrings(text string) []string {
+
+	var res []string
+
+	for len(text) > 0 {
+		pos := strings.IndexAny(text, "\"'")
+		if pos < 0 {
+			break
+		}
+		quote := text[pos]
+		text = text[pos+1:]
+		stop := strings.IndexByte(text, quote)
+		if stop < 0 {
+			break
+		}
+		res = append(res, text[:stop])
+		text = text[stop+1:]
+	}
+
+	return res
+}
+
+// parseDTDModel converts a children content model such as (a, (b | c)*, d?) into a regular expression
+func parseDTDModel(model string) string {
+
+	str := strings.Join(strings.Fields(model), "")
+	pos := 0
+
+	var particle func() string
+
+	particle = func() string {
+
+		atom := ""
+
+		if pos < len(str) && str[pos] == '(' {
+			pos++
+			var items []string
+			sep := byte(',')
+			for pos < len(str) && str[pos] != ')' {
+				items = append(items, particle())
+				if pos < len(str) && (str[pos] == ',' || str[pos] == '|') {
+					sep = str[pos]
+					pos++
+				}
+			}
+			// skip closing parenthesis
+			pos++
+			if sep == '|' {
+				atom = "(?:" + strings.Join(items, "|") + ")"
+			} else {
+				atom = "(?:" + strings.Join(items, "") + ")"
+			}
+		} else {
+			start := pos
+			for pos < len(str) && !strings.ContainsRune("(),|?*+", rune(str[pos])) {
+				pos++
+			}
+			atom = modelName(str[start:pos])
+		}
+
+		if pos < len(str) {
+			switch str[pos] {
+			case '?', '*', '+':
+				atom += string(str[pos])
+				pos++
+			}
+		}
+
+		return atom
+	}
+
+	return particle()
+}
+
+// LoadDTD reads element and attribute declarations from a DTD file
+func LoadDTD(fname string) *XMLSchema {
+
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to read DTD file '%s'\n", fname)
+		os.Exit(1)
+	}
+
+	text := expandDTD(string(data), filepath.Dir(fname), 0)
+
+	sch := &XMLSchema{Elements: make(map[string]*schemaElem)}
+
+	getElem := func(name string) *schemaElem {
+		elm, ok := sch.Elements[name]
+		if !ok {
+			elm = newSchemaElem(name)
+			sch.Elements[name] = elm
+		}
+		return elm
+	}
+
+	for {
+		pos := strings.Index(text, "<!")
+		if pos < 0 {
+			break
+		}
+		text = text[pos:]
+		stop := declEnd(text)
+		decl := strings.TrimSpace(text[2 : stop-1])
+		text = text[stop:]
+
+		switch {
+		case strings.HasPrefix(decl, "ELEMENT"):
+			flds := strings.Fields(decl[len("ELEMENT"):])
+			if len(flds) < 2 {
+				continue
+			}
+			elm := getElem(flds[0])
+			model := strings.Join(flds[1:], " ")
+			compact := strings.Join(strings.Fields(model), "")
+			switch {
+			case compact == "EMPTY":
+				elm.Empty = true
+			case compact == "ANY":
+				elm.Any = true
+			case strings.HasPrefix(compact, "(#PCDATA"):
+				elm.Mixed = true
+				// mixed content allows the listed children in any order and number
+				inner := strings.TrimSuffix(strings.TrimSuffix(compact, "*"), ")")
+				names := strings.Split(inner[1:], "|")[1:]
+				if len(names) > 0 {
+					var atoms []string
+					for _, nm := range names {
+						atoms = append(atoms, modelName(nm))
+					}
+					elm.Pattern = "(?:" + strings.Join(atoms, "|") + ")*"
+				}
+			default:
+				elm.Pattern = parseDTDModel(compact)
+			}
+			elm.compileModel()
+
+		case strings.HasPrefix(decl, "ATTLIST"):
+			body := decl[len("ATTLIST"):]
+			flds := strings.Fields(body)
+			if len(flds) < 1 {
+				continue
+			}
+			elm := getElem(flds[0])
+			body = strings.TrimSpace(body)[len(flds[0]):]
+			parseAttlist(elm, body)
+		}
+	}
+
+	return sch
+}
+
+// parseAttlist reads name, type, and default triples from an ATTLIST declaration
+func parseAttlist(elm *schemaElem, body string) {
+
+	// tokenize, keeping parenthesized enumerations and quoted defaults intact
+	var tokens []string
+	for len(body) > 0 {
+		body = strings.TrimLeft(body, " \t\r\n")
+		if body == "" {
+			break
+		}
+		switch body[0] {
+		case '(':
+			stop := strings.IndexByte(body, ')')
+			if stop < 0 {
+				stop = len(body) - 1
+			}
+			tokens = append(tokens, body[:stop+1])
+			body = body[stop+1:]
+		case '"', '\'':
+			stop := strings.IndexByte(body[1:], body[0])
+			if stop < 0 {
+				stop = len(body) - 2
+			}
+			tokens = append(tokens, body[:stop+2])
+			body = body[stop+2:]
+		default:
+			stop := strings.IndexAny(body, " \t\r\n")
+			if stop < 0 {
+				stop = len(body)
+			}
+			tokens = append(tokens, body[:stop])
+			body = body[stop:]
+		}
+	}
+
+	for len(tokens) >= 3 {
+		att := &schemaAttr{Name: tokens[0]}
+		typ := tokens[1]
+		tokens = tokens[2:]
+		if typ == "NOTATION" && len(tokens) > 0 {
+			typ = tokens[0]
+			tokens = tokens[1:]
+		}
+		if strings.HasPrefix(typ, "(") {
+			for _, str := range strings.Split(strings.Trim(typ, "()"), "|") {
+				att.Values = append(att.Values, strings.TrimSpace(str))
+			}
+		}
+		if len(tokens) == 0 {
+			break
+		}
+		dflt := tokens[0]
+		tokens = tokens[1:]
+		switch dflt {
+		case "#REQUIRED":
+			att.Required = true
+		case "#IMPLIED":
+		case "#FIXED":
+			if len(tokens) > 0 {
+				att.Fixed = strings.Trim(tokens[0], "\"'")
+				tokens = tokens[1:]
+			}
+		}
+		if _, ok := elm.Attrs[att.Name]; !ok {
+			// first declaration is binding
+			elm.Attrs[att.Name] = att
+		}
+	}
+}
+
+// XSD PARSER
+
+// xsdNode is a generic element in an XML Schema document
+type xsdNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []xsdNode  `xml:",any"`
+}
+
+// attr returns the value of the named attribute
+func (nd *xsdNode) attr(name string) string {
+
+	for _, at := range nd.Attrs {
+		if at.Name.Local == name {
+			return at.Value
+		}
+	}
+
+	return ""
+}
+
+// localName removes any namespace prefix from a QName reference
+func localName(str string) string {
+
+	if pos := strings.LastIndex(str, ":"); pos >= 0 {
+		return str[pos+1:]
+	}
+
+	return str
+}
+
+// LoadXSD reads element and attribute declarations from an XML Schema file, supporting
+// sequence, choice, all, group, complexContent and simpleContent extension, and enumerations
+func LoadXSD(fname string) *XMLSchema {
+
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to read XSD file '%s'\n", fname)
+		os.Exit(1)
+	}
+
+	var root xsdNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse XSD file '%s': %s\n", fname, err.Error())
+		os.Exit(1)
+	}
+
+	sch := &XMLSchema{Elements: make(map[string]*schemaElem)}
+
+	complexTypes := make(map[string]*xsdNode)
+	simpleTypes := make(map[string]*xsdNode)
+	groups := make(map[string]*xsdNode)
+	attrGroups := make(map[string]*xsdNode)
+	globalElems := make(map[string]*xsdNode)
+	globalAttrs := make(map[string]*xsdNode)
+
+	// included schema documents add their top-level definitions
+	var collect func(nd *xsdNode, dir string, depth int)
+	collect = func(nd *xsdNode, dir string, depth int) {
+		for i := range nd.Children {
+			chld := &nd.Children[i]
+			name := chld.attr("name")
+			switch chld.XMLName.Local {
+			case "complexType":
+				complexTypes[name] = chld
+			case "simpleType":
+				simpleTypes[name] = chld
+			case "group":
+				groups[name] = chld
+			case "attributeGroup":
+				attrGroups[name] = chld
+			case "element":
+				globalElems[name] = chld
+			case "attribute":
+				globalAttrs[name] = chld
+			case "include", "import", "redefine":
+				loc := chld.attr("schemaLocation")
+				if loc == "" || depth > 10 {
+					continue
+				}
+				if !filepath.IsAbs(loc) {
+					loc = filepath.Join(dir, filepath.Base(loc))
+				}
+				inc, err := os.ReadFile(loc)
+				if err != nil {
+					continue
+				}
+				var sub xsdNode
+				if xml.Unmarshal(inc, &sub) == nil {
+					collect(&sub, filepath.Dir(loc), depth+1)
+				}
+			}
+		}
+	}
+	collect(&root, filepath.Dir(fname), 0)
+
+	// enumerations returns restricted values of a simple type
+	var enumerations func(nd *xsdNode) []string
+	enumerations = func(nd *xsdNode) []string {
+		var vals []string
+		if nd == nil {
+			return vals
+		}
+		for i := range nd.Children {
+			chld := &nd.Children[i]
+			switch chld.XMLName.Local {
+			case "restriction":
+				if base, ok := simpleTypes[localName(chld.attr("base"))]; ok {
+					vals = append(vals, enumerations(base)...)
+				}
+				for j := range chld.Children {
+					if chld.Children[j].XMLName.Local == "enumeration" {
+						vals = append(vals, chld.Children[j].attr("value"))
+					}
+				}
+			case "simpleType":
+				vals = append(vals, enumerations(chld)...)
+			}
+		}
+		return vals
+	}
+
+	// addAttributes records attribute declarations found directly under a node
+	var addAttributes func(elm *schemaElem, nd *xsdNode)
+	addAttributes = func(elm *schemaElem, nd *xsdNode) {
+		for i := range nd.Children {
+			chld := &nd.Children[i]
+			switch chld.XMLName.Local {
+			case "attribute":
+				src := chld
+				name := chld.attr("name")
+				if ref := chld.attr("ref"); ref != "" {
+					name = localName(ref)
+					if glb, ok := globalAttrs[name]; ok {
+						src = glb
+					}
+				}
+				if name == "" || chld.attr("use") == "prohibited" {
+					continue
+				}
+				att := &schemaAttr{Name: name, Required: chld.attr("use") == "required", Fixed: src.attr("fixed")}
+				if typ, ok := simpleTypes[localName(src.attr("type"))]; ok {
+					att.Values = enumerations(typ)
+				} else {
+					att.Values = enumerations(src)
+				}
+				elm.Attrs[name] = att
+			case "attributeGroup":
+				if grp, ok := attrGroups[localName(chld.attr("ref"))]; ok {
+					addAttributes(elm, grp)
+				}
+			case "anyAttribute":
+				elm.AnyAttr = true
+			}
+		}
+	}
+
+	var defineElement func(nd *xsdNode) *schemaElem
+	var particle func(nd *xsdNode) string
+	var applyType func(elm *schemaElem, ctype *xsdNode, depth int) string
+
+	// particle converts a content model node into a regular expression fragment
+	particle = func(nd *xsdNode) string {
+
+		min := nd.attr("minOccurs")
+		max := nd.attr("maxOccurs")
+
+		switch nd.XMLName.Local {
+		case "element":
+			name := nd.attr("name")
+			if ref := nd.attr("ref"); ref != "" {
+				name = localName(ref)
+				if glb, ok := globalElems[name]; ok {
+					defineElement(glb)
+				}
+			} else {
+				defineElement(nd)
+			}
+			return modelRepeat(modelName(name), min, max)
+		case "sequence", "choice", "all":
+			var items []string
+			for i := range nd.Children {
+				chld := &nd.Children[i]
+				switch chld.XMLName.Local {
+				case "element", "sequence", "choice", "group", "any":
+					items = append(items, particle(chld))
+				}
+			}
+			if len(items) == 0 {
+				return ""
+			}
+			switch nd.XMLName.Local {
+			case "choice":
+				return modelRepeat("(?:"+strings.Join(items, "|")+")", min, max)
+			case "all":
+				// members of all may appear in any order, approximated as a repeated choice
+				for i, itm := range items {
+					items[i] = strings.TrimRight(itm, "?*+")
+				}
+				return "(?:" + strings.Join(items, "|") + ")*"
+			}
+			return modelRepeat("(?:"+strings.Join(items, "")+")", min, max)
+		case "group":
+			if grp, ok := groups[localName(nd.attr("ref"))]; ok {
+				for i := range grp.Children {
+					chld := &grp.Children[i]
+					switch chld.XMLName.Local {
+					case "sequence", "choice", "all":
+						return modelRepeat("(?:"+particle(chld)+")", min, max)
+					}
+				}
+			}
+			return ""
+		case "any":
+			return modelRepeat("(?:[^ ]+ )", min, max)
+		}
+
+		return ""
+	}
+
+	// applyType fills in content and attributes from a complexType node, returning the content pattern
+	applyType = func(elm *schemaElem, ctype *xsdNode, depth int) string {
+
+		if ctype == nil || depth > 20 {
+			return ""
+		}
+
+		if ctype.attr("mixed") == "true" {
+			elm.Mixed = true
+		}
+
+		pattern := ""
+
+		for i := range ctype.Children {
+			chld := &ctype.Children[i]
+			switch chld.XMLName.Local {
+			case "sequence", "choice", "all", "group":
+				pattern += particle(chld)
+			case "simpleContent":
+				elm.Mixed = true
+				for j := range chld.Children {
+					ext := &chld.Children[j]
+					if base, ok := complexTypes[localName(ext.attr("base"))]; ok {
+						applyType(elm, base, depth+1)
+					}
+					addAttributes(elm, ext)
+				}
+			case "complexContent":
+				if chld.attr("mixed") == "true" {
+					elm.Mixed = true
+				}
+				for j := range chld.Children {
+					ext := &chld.Children[j]
+					if ext.XMLName.Local == "extension" {
+						if base, ok := complexTypes[localName(ext.attr("base"))]; ok {
+							pattern += applyType(elm, base, depth+1)
+						}
+					}
+					for k := range ext.Children {
+						switch ext.Children[k].XMLName.Local {
+						case "sequence", "choice", "all", "group":
+							pattern += particle(&ext.Children[k])
+						}
+					}
+					addAttributes(elm, ext)
+				}
+			}
+		}
+
+		addAttributes(elm, ctype)
+
+		return pattern
+	}
+
+	// defineElement registers an element declaration once
+	defineElement = func(nd *xsdNode) *schemaElem {
+
+		name := nd.attr("name")
+		if elm, ok := sch.Elements[name]; ok {
+			return elm
+		}
+
+		elm := newSchemaElem(name)
+		sch.Elements[name] = elm
+
+		var ctype *xsdNode
+		isSimple := false
+
+		if typ := nd.attr("type"); typ != "" {
+			if ct, ok := complexTypes[localName(typ)]; ok {
+				ctype = ct
+			} else {
+				// built-in or simple type allows text only
+				isSimple = true
+			}
+		}
+		for i := range nd.Children {
+			switch nd.Children[i].XMLName.Local {
+			case "complexType":
+				ctype = &nd.Children[i]
+			case "simpleType":
+				isSimple = true
+			}
+		}
+
+		switch {
+		case ctype != nil:
+			elm.Pattern = applyType(elm, ctype, 0)
+			if elm.Pattern == "" && !elm.Mixed {
+				elm.Empty = true
+			}
+		case isSimple:
+			elm.Mixed = true
+		default:
+			// no type is anyType
+			elm.Any = true
+		}
+
+		elm.compileModel()
+
+		return elm
+	}
+
+	for _, nd := range globalElems {
+		defineElement(nd)
+	}
+
+	return sch
+}
+
+// LoadSchema reads a DTD or XSD, choosing the parser by file extension unless forced
+func LoadSchema(fname, kind string) *XMLSchema {
+
+	if kind == "" {
+		kind = strings.ToLower(strings.TrimPrefix(filepath.Ext(fname), "."))
+	}
+
+	switch kind {
+	case "dtd":
+		return LoadDTD(fname)
+	case "xsd":
+		return LoadXSD(fname)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized schema type '%s', expected dtd or xsd\n", kind)
+	os.Exit(1)
+
+	return nil
+}
@@ -33,6 +33,8 @@ package eutils
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -448,14 +450,15 @@ func fsmSearcher(patterns []string, caseSensitive, wholeWord, relaxed, compress,
 
 		alias := ""
 
-		if !caseSensitive {
-			txt = strings.ToLower(txt)
-		}
-
 		if isSequence {
+			// separate alias first to preserve its case
 			txt, alias = SplitInTwoLeft(txt, ":")
 		}
 
+		if !caseSensitive {
+			txt = strings.ToLower(txt)
+		}
+
 		if relaxed {
 			txt = RelaxString(txt)
 		} else if compress {
@@ -670,7 +673,7 @@ func SequenceSearcher(patterns []string, isProtein, isCircular, topStrandOnly bo
 		}
 	}
 
-	return fsmSearcher(arry, false, false, false, true, true, isCircular, !topStrandOnly)
+	return fsmSearcher(arry, false, false, false, true, isCircular, true, !topStrandOnly)
 }
 
 // Search uses precomputed Searcher tables to search a string or sequence
@@ -760,3 +763,370 @@ func (srch *FSMSearcher) Search(text string, proc func(string, string, int) bool
 		}
 	}
 }
+
+// Approximate sequence searching compares each pattern against every text position,
+// counting only substitutions, or, if insertions and deletions are also allowed,
+// uses the edit distance variant that lets an alignment start anywhere in the text,
+// as described in Peter H. Sellers, The Theory and Computation of Evolutionary
+// Distances: Pattern Recognition, Journal of Algorithms, 1980, 1:359-373.
+
+// SequenceHit reports the location and differences of one approximate match
+type SequenceHit struct {
+	Pattern    string
+	Start      int
+	Stop       int
+	Mismatches int
+	Cigar      string
+}
+
+type approxEntry struct {
+	pattern string
+	alias   string
+}
+
+// ApproxSearcher for mismatch-tolerant sequence search
+type ApproxSearcher struct {
+	protein   bool
+	circular  bool
+	indels    bool
+	maxdiffs  int
+	masks     [256]byte
+	entries   []approxEntry
+	maxpatlen int
+}
+
+// ApproximateSearcher prepares nucleotide or protein patterns for finding hits with up to
+// maxDiffs substitutions, or substitutions, insertions, and deletions if allowIndels is set
+func ApproximateSearcher(patterns []string, isProtein, isCircular, topStrandOnly bool, maxDiffs int, allowIndels bool) *ApproxSearcher {
+
+	if patterns == nil {
+		return nil
+	}
+
+	if isProtein {
+		topStrandOnly = true
+	}
+
+	if maxDiffs < 0 {
+		maxDiffs = 0
+	}
+
+	srch := &ApproxSearcher{
+		protein:  isProtein,
+		circular: isCircular,
+		indels:   allowIndels,
+		maxdiffs: maxDiffs,
+	}
+
+	if !isProtein {
+		// ambiguity characters become sets of bits, with A = 1, C = 2, G = 4, and T = 8
+		for ltr, bases := range expandNuc {
+			var msk byte
+			for _, ch := range strings.ToUpper(bases) {
+				switch ch {
+				case 'A':
+					msk |= 1
+				case 'C':
+					msk |= 2
+				case 'G':
+					msk |= 4
+				case 'T':
+					msk |= 8
+				}
+			}
+			srch.masks[ltr[0]] = msk
+		}
+		srch.masks['U'] = 8
+		srch.masks['u'] = 8
+	}
+
+	seen := make(map[string]int)
+
+	addEntry := func(txt, alias string, isRev bool) {
+
+		if idx, ok := seen[txt]; ok {
+			// top strand pattern replaces reverse complement generated from an earlier pattern
+			if !isRev && strings.HasPrefix(srch.entries[idx].alias, "(") {
+				srch.entries[idx].alias = alias
+			}
+			return
+		}
+		seen[txt] = len(srch.entries)
+
+		srch.entries = append(srch.entries, approxEntry{pattern: txt, alias: alias})
+
+		if srch.maxpatlen < len(txt) {
+			srch.maxpatlen = len(txt)
+		}
+	}
+
+	for _, pat := range patterns {
+
+		// each pattern can optionally be followed by a colon and an alias
+		txt, alias := SplitInTwoLeft(pat, ":")
+
+		txt = strings.ToUpper(strings.TrimSpace(txt))
+		if txt == "" {
+			continue
+		}
+
+		// instantiated matches are shown in the alignment, so the + and - aliases print the pattern
+		if alias == "" || alias == "+" || alias == "-" {
+			alias = txt
+		}
+
+		addEntry(txt, alias, false)
+
+		if !topStrandOnly {
+			// also search for non-palindromic reverse complement
+			rev := ReverseComplement(txt)
+			if rev != txt {
+				addEntry(rev, "("+alias+")", true)
+			}
+		}
+	}
+
+	return srch
+}
+
+// residueMatches allows ambiguity characters in the pattern to match any of their bases
+func (srch *ApproxSearcher) residueMatches(pat, txt byte) bool {
+
+	if srch.protein {
+		if txt >= 'a' && txt <= 'z' {
+			txt -= 'a' - 'A'
+		}
+		return pat == txt || pat == 'X'
+	}
+
+	// text base must be a subset of the pattern ambiguity set
+	msk := srch.masks[txt]
+
+	return msk != 0 && msk&^srch.masks[pat] == 0
+}
+
+// compressCigar converts a list of alignment operations into an extended CIGAR string
+func compressCigar(ops []byte) string {
+
+	var buffer strings.Builder
+
+	for i := 0; i < len(ops); {
+		j := i
+		for j < len(ops) && ops[j] == ops[i] {
+			j++
+		}
+		buffer.WriteString(strconv.Itoa(j - i))
+		buffer.WriteByte(ops[i])
+		i = j
+	}
+
+	return buffer.String()
+}
+
+// mismatchHits slides a pattern along the text, counting substitutions at each offset
+func (srch *ApproxSearcher) mismatchHits(text string, ent approxEntry, cutoff int, hits []SequenceHit) []SequenceHit {
+
+	pat := ent.pattern
+	m := len(pat)
+	k := srch.maxdiffs
+
+	ops := make([]byte, m)
+
+	for i := 0; i+m <= len(text) && i < cutoff; i++ {
+
+		diffs := 0
+		for j := 0; j < m && diffs <= k; j++ {
+			if srch.residueMatches(pat[j], text[i+j]) {
+				ops[j] = '='
+			} else {
+				ops[j] = 'X'
+				diffs++
+			}
+		}
+
+		if diffs > k {
+			continue
+		}
+
+		hits = append(hits, SequenceHit{Pattern: ent.alias, Start: i, Stop: i + m - 1, Mismatches: diffs, Cigar: compressCigar(ops)})
+	}
+
+	return hits
+}
+
+// alignmentHit recomputes the edit distance matrix for a window ending at the best
+// position in a run of qualifying ends, and traces back to find the start and alignment
+func (srch *ApproxSearcher) alignmentHit(text string, ent approxEntry, stop, cutoff int, hits []SequenceHit) []SequenceHit {
+
+	pat := ent.pattern
+	m := len(pat)
+
+	// alignment with at most k differences cannot span more than m + k text characters
+	start := stop - m - srch.maxdiffs + 1
+	if start < 0 {
+		start = 0
+	}
+	seg := text[start : stop+1]
+	w := len(seg)
+
+	dist := make([][]int, m+1)
+	for i := range dist {
+		dist[i] = make([]int, w+1)
+		dist[i][0] = i
+	}
+
+	for i := 1; i <= m; i++ {
+		for j := 1; j <= w; j++ {
+			cost := 1
+			if srch.residueMatches(pat[i-1], seg[j-1]) {
+				cost = 0
+			}
+			val := dist[i-1][j-1] + cost
+			if dist[i-1][j]+1 < val {
+				val = dist[i-1][j] + 1
+			}
+			if dist[i][j-1]+1 < val {
+				val = dist[i][j-1] + 1
+			}
+			dist[i][j] = val
+		}
+	}
+
+	// trace back from last pattern character, preferring diagonal steps
+	var ops []byte
+	i, j := m, w
+	for i > 0 {
+		if j > 0 {
+			cost := 1
+			op := byte('X')
+			if srch.residueMatches(pat[i-1], seg[j-1]) {
+				cost = 0
+				op = '='
+			}
+			if dist[i][j] == dist[i-1][j-1]+cost {
+				ops = append(ops, op)
+				i--
+				j--
+				continue
+			}
+			if dist[i][j] == dist[i][j-1]+1 {
+				// extra base in text is a deletion from the pattern
+				ops = append(ops, 'D')
+				j--
+				continue
+			}
+		}
+		// pattern base absent from text is an insertion
+		ops = append(ops, 'I')
+		i--
+	}
+
+	if start+j >= cutoff {
+		// circular overhang duplicates a hit already reported at the beginning
+		return hits
+	}
+
+	// operations were collected from the end
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+
+	return append(hits, SequenceHit{Pattern: ent.alias, Start: start + j, Stop: stop, Mismatches: dist[m][w], Cigar: compressCigar(ops)})
+}
+
+// editHits computes one column of the edit distance matrix per text character, with free
+// starting positions, and reports the best end in each run of positions within the limit
+func (srch *ApproxSearcher) editHits(text string, ent approxEntry, cutoff int, hits []SequenceHit) []SequenceHit {
+
+	pat := ent.pattern
+	m := len(pat)
+	k := srch.maxdiffs
+
+	prev := make([]int, m+1)
+	curr := make([]int, m+1)
+	for i := range prev {
+		prev[i] = i
+	}
+
+	best := -1
+	score := 0
+
+	for j := 0; j < len(text); j++ {
+
+		curr[0] = 0
+		for i := 1; i <= m; i++ {
+			cost := 1
+			if srch.residueMatches(pat[i-1], text[j]) {
+				cost = 0
+			}
+			val := prev[i-1] + cost
+			if prev[i]+1 < val {
+				val = prev[i] + 1
+			}
+			if curr[i-1]+1 < val {
+				val = curr[i-1] + 1
+			}
+			curr[i] = val
+		}
+
+		if curr[m] <= k {
+			if best < 0 || curr[m] < score {
+				best = j
+				score = curr[m]
+			}
+		} else if best >= 0 {
+			hits = srch.alignmentHit(text, ent, best, cutoff, hits)
+			best = -1
+		}
+
+		prev, curr = curr, prev
+	}
+
+	if best >= 0 {
+		hits = srch.alignmentHit(text, ent, best, cutoff, hits)
+	}
+
+	return hits
+}
+
+// Search sends approximate matches to a callback in order of starting position
+func (srch *ApproxSearcher) Search(text string, proc func(SequenceHit) bool) {
+
+	if srch == nil || text == "" || proc == nil {
+		return
+	}
+
+	// original length of text before any duplication to simulate circularity
+	cutoff := len(text)
+
+	if srch.circular {
+		// hits with insertions can extend past the longest pattern
+		overhang := srch.maxpatlen + srch.maxdiffs
+		if overhang > cutoff {
+			overhang = cutoff
+		}
+		text += text[:overhang]
+	}
+
+	var hits []SequenceHit
+
+	for _, ent := range srch.entries {
+		if srch.indels {
+			hits = srch.editHits(text, ent, cutoff, hits)
+		} else {
+			hits = srch.mismatchHits(text, ent, cutoff, hits)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Start < hits[j].Start })
+
+	for _, hit := range hits {
+		if hit.Stop >= cutoff {
+			// match spans origin of circular molecule
+			hit.Stop -= cutoff
+		}
+		if !proc(hit) {
+			return
+		}
+	}
+}
@@ -310,3 +310,271 @@ func SequenceReverse(seq string) string {
 
 	return seq
 }
+
+// VARIANT NORMALIZATION
+
+// trimSPDI removes bases shared by deletion and insertion, suffix first and then prefix
+func trimSPDI(pos int, del, ins string) (int, string, string) {
+
+	for len(del) > 0 && len(ins) > 0 && del[len(del)-1] == ins[len(ins)-1] {
+		del = del[:len(del)-1]
+		ins = ins[:len(ins)-1]
+	}
+
+	for len(del) > 0 && len(ins) > 0 && del[0] == ins[0] {
+		del = del[1:]
+		ins = ins[1:]
+		pos++
+	}
+
+	return pos, del, ins
+}
+
+// ParseVariant converts an SPDI (NC_000001.11:12344:A:G) or genomic HGVS (NC_000001.11:g.12345A>G)
+// expression into an SPDI object, using the reference sequence to fill in unstated deleted or duplicated bases
+func ParseVariant(str, seq string) *SPDI {
+
+	str = strings.TrimSpace(str)
+	seq = strings.ToUpper(seq)
+
+	accn, rest := SplitInTwoLeft(str, ":")
+	if accn == "" || rest == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' is missing accession\n", str)
+		return nil
+	}
+
+	// baseRange returns the reference bases from 1-based start to stop
+	baseRange := func(start, stop int) (string, bool) {
+		if start < 1 || stop < start || stop > len(seq) {
+			fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' is outside of reference sequence length %d\n", str, len(seq))
+			return "", false
+		}
+		return seq[start-1 : stop], true
+	}
+
+	flds := strings.Split(rest, ":")
+
+	if len(flds) == 3 {
+
+		// SPDI has 0-based position, deleted sequence or count, and inserted sequence
+		pos, err := strconv.Atoi(flds[0])
+		if err != nil || pos < 0 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' has unrecognized position\n", str)
+			return nil
+		}
+		del := strings.ToUpper(flds[1])
+		ins := strings.ToUpper(flds[2])
+		if IsAllDigits(del) {
+			num, _ := strconv.Atoi(del)
+			del = ""
+			if num > 0 {
+				ext, ok := baseRange(pos+1, pos+num)
+				if !ok {
+					return nil
+				}
+				del = ext
+			}
+		}
+		return &SPDI{Accession: accn, Position: pos, Deleted: del, Inserted: ins}
+	}
+
+	if len(rest) < 3 || rest[1] != '.' || !strings.ContainsRune("gmn", rune(rest[0])) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' is not SPDI or genomic HGVS\n", str)
+		return nil
+	}
+	rest = rest[2:]
+
+	// leading position or range
+	idx := 0
+	for idx < len(rest) && (rest[idx] >= '0' && rest[idx] <= '9' || rest[idx] == '_') {
+		idx++
+	}
+	rng, chng := rest[:idx], rest[idx:]
+
+	frst, scnd := SplitInTwoLeft(rng, "_")
+	start, err := strconv.Atoi(frst)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' has unrecognized position\n", str)
+		return nil
+	}
+	stop := start
+	if scnd != "" {
+		stop, err = strconv.Atoi(scnd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' has unrecognized position\n", str)
+			return nil
+		}
+	}
+
+	chng = strings.ToUpper(chng)
+
+	switch {
+	case chng == "=":
+		return &SPDI{Accession: accn, Position: start - 1}
+	case len(chng) == 3 && chng[1] == '>':
+		// substitution
+		return &SPDI{Accession: accn, Position: start - 1, Deleted: chng[:1], Inserted: chng[2:]}
+	case strings.HasPrefix(chng, "DELINS"):
+		del, ok := baseRange(start, stop)
+		if !ok {
+			return nil
+		}
+		return &SPDI{Accession: accn, Position: start - 1, Deleted: del, Inserted: chng[6:]}
+	case strings.HasPrefix(chng, "DEL"):
+		del, ok := baseRange(start, stop)
+		if !ok {
+			return nil
+		}
+		return &SPDI{Accession: accn, Position: start - 1, Deleted: del}
+	case strings.HasPrefix(chng, "INS"):
+		// insertion lies between two adjacent positions
+		if stop != start+1 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' insertion must be between adjacent positions\n", str)
+			return nil
+		}
+		return &SPDI{Accession: accn, Position: start, Inserted: chng[3:]}
+	case strings.HasPrefix(chng, "DUP"):
+		dup, ok := baseRange(start, stop)
+		if !ok {
+			return nil
+		}
+		return &SPDI{Accession: accn, Position: stop, Inserted: dup}
+	}
+
+	fmt.Fprintf(os.Stderr, "\nERROR: Variant '%s' has unsupported change '%s'\n", str, chng)
+	return nil
+}
+
+// NormalizeSPDI returns the minimal, left-aligned representation of a variant on a reference sequence,
+// and fills in the HGVS expression, which by convention is instead shifted toward the 3' end
+func NormalizeSPDI(seq string, spdi *SPDI) *SPDI {
+
+	if spdi == nil {
+		return nil
+	}
+
+	seq = strings.ToUpper(seq)
+
+	pos := spdi.Position
+	del := strings.ToUpper(spdi.Deleted)
+	ins := strings.ToUpper(spdi.Inserted)
+
+	if pos < 0 || pos+len(del) > len(seq) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Variant position %d is outside of reference sequence length %d\n", pos, len(seq))
+		return nil
+	}
+	if seq[pos:pos+len(del)] != del {
+		fmt.Fprintf(os.Stderr, "\nERROR: Deleted sequence %s does not match reference %s at position %d\n", del, seq[pos:pos+len(del)], pos)
+		return nil
+	}
+
+	pos, del, ins = trimSPDI(pos, del, ins)
+
+	// only pure deletions and insertions can move within a repeat
+	lft, rgt := pos, pos
+	allele := del + ins
+	if (del == "") != (ins == "") {
+
+		// rotate left while the base before the allele matches its last base
+		lal := allele
+		for lft > 0 && seq[lft-1] == lal[len(lal)-1] {
+			lal = seq[lft-1:lft] + lal[:len(lal)-1]
+			lft--
+		}
+
+		// rotate right while the base after the event matches the first base of the allele
+		ral := allele
+		end := rgt + len(del)
+		for end < len(seq) && seq[end] == ral[0] {
+			ral = ral[1:] + seq[end:end+1]
+			rgt++
+			end++
+		}
+
+		if del != "" {
+			del = lal
+		} else {
+			ins = lal
+		}
+
+		// HGVS uses the 3'-most position, and calls an insertion of the preceding bases a duplication
+		hgvs := ""
+		if del != "" {
+			hgvs = SpdiToHgvs(spdi.Accession, rgt, ral, "")
+		} else if rgt >= len(ral) && seq[rgt-len(ral):rgt] == ral {
+			start := strconv.Itoa(rgt - len(ral) + 1)
+			stop := strconv.Itoa(rgt)
+			if len(ral) == 1 {
+				hgvs = spdi.Accession + ":g." + stop + "dup"
+			} else {
+				hgvs = spdi.Accession + ":g." + start + "_" + stop + "dup"
+			}
+		} else {
+			hgvs = SpdiToHgvs(spdi.Accession, rgt, "", ral)
+		}
+
+		typ := DEL
+		if del == "" {
+			typ = INS
+			if strings.HasSuffix(hgvs, "dup") {
+				typ = DUP
+			}
+		}
+
+		return &SPDI{Class: GENOMIC, Type: typ, Accession: spdi.Accession, Position: lft, Deleted: del, Inserted: ins, Hgvs: hgvs}
+	}
+
+	typ := INDEL
+	if len(del) == 1 && len(ins) == 1 {
+		typ = SUBS
+	}
+
+	return &SPDI{Class: GENOMIC, Type: typ, Accession: spdi.Accession, Position: pos, Deleted: del, Inserted: ins, Hgvs: SpdiToHgvs(spdi.Accession, pos, del, ins)}
+}
+
+// VariantsEquivalent reports whether two variants produce the same sequence when applied to the reference
+func VariantsEquivalent(seq string, a, b *SPDI) bool {
+
+	if a == nil || b == nil {
+		return false
+	}
+
+	seq = strings.ToUpper(seq)
+
+	// restrict to the region spanned by both variants plus one flanking base on each side,
+	// so that pure insertions at the same position still leave sequence to compare
+	lo := a.Position
+	if b.Position < lo {
+		lo = b.Position
+	}
+	hi := a.Position + len(a.Deleted)
+	if b.Position+len(b.Deleted) > hi {
+		hi = b.Position + len(b.Deleted)
+	}
+	if lo < 0 || hi > len(seq) {
+		return false
+	}
+	if lo > 0 {
+		lo--
+	}
+	if hi < len(seq) {
+		hi++
+	}
+	window := seq[lo:hi]
+
+	// apply splices the variant into the window, deleted bases must match the reference
+	apply := func(v *SPDI) (string, bool) {
+		del := strings.ToUpper(v.Deleted)
+		ins := strings.ToUpper(v.Inserted)
+		pos := v.Position - lo
+		if !strings.HasPrefix(window[pos:], del) {
+			return "", false
+		}
+		return window[:pos] + ins + window[pos+len(del):], true
+	}
+
+	seqA, okA := apply(a)
+	seqB, okB := apply(b)
+
+	return okA && okB && seqA == seqB
+}
@@ -0,0 +1,384 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  taxcode.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GENETIC CODE INFERENCE FROM LOCAL TAXONOMY
+
+// GeneticCodeLookup finds nuclear and organelle genetic codes for a TaxID or organism name,
+// using the local taxonomy archive, a taxdump directory, or a saved TaxNodeSet file
+type GeneticCodeLookup struct {
+	archive string
+	source  string
+	index   *TaxonIndex
+	loaded  bool
+	codes   map[string]TaxCodes
+	names   map[string]string
+	mutex   sync.Mutex
+}
+
+// NewGeneticCodeLookup uses the given taxonomy path or, if empty, EDIRECT_TAXONOMY_MASTER,
+// returning nil if no local taxonomy data is available
+func NewGeneticCodeLookup(path string) *GeneticCodeLookup {
+
+	gl := &GeneticCodeLookup{
+		codes: make(map[string]TaxCodes),
+		names: make(map[string]string),
+	}
+
+	if path == "" {
+		path = os.Getenv("EDIRECT_TAXONOMY_MASTER")
+	}
+	if path == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	if !fi.IsDir() {
+		// saved rchive -taxon output
+		gl.source = path
+		return gl
+	}
+
+	_, err = os.Stat(filepath.Join(path, "nodes.dmp"))
+	if err == nil {
+		// taxdump directory
+		gl.source = path
+		return gl
+	}
+
+	// master area, individual records in Archive, complete node set in Data for name lookups
+	archive := filepath.Join(path, "Archive")
+	_, err = os.Stat(archive)
+	if err == nil {
+		gl.archive = archive
+	}
+	data := filepath.Join(path, "Data", "taxnodes.xml")
+	_, err = os.Stat(data)
+	if err == nil {
+		gl.source = data
+	}
+
+	if gl.archive == "" && gl.source == "" {
+		return nil
+	}
+
+	return gl
+}
+
+// taxonIndex loads the full taxonomy on first use, since it is only needed for name
+// lookups or when individual archive records are not available
+func (gl *GeneticCodeLookup) taxonIndex() *TaxonIndex {
+
+	if !gl.loaded {
+		gl.loaded = true
+		if gl.source != "" {
+			gl.index = NewTaxonIndex(gl.source)
+		}
+	}
+
+	return gl.index
+}
+
+// fetchCodes reads genetic codes from an archived TaxNode record
+func (gl *GeneticCodeLookup) fetchCodes(taxID string) (TaxCodes, bool) {
+
+	var codes TaxCodes
+
+	var buf bytes.Buffer
+
+	str := fetchOneXMLRecord(taxID, gl.archive, "", ".xml", true, buf)
+	if str == "" {
+		return codes, false
+	}
+
+	found := false
+
+	StreamValues(str, "TaxNode", func(tag, attr, content string) {
+		switch tag {
+		case "TaxID":
+			found = true
+		case "Nuclear":
+			codes.Nuclear = content
+		case "Mitochondrial":
+			codes.Mitochondrial = content
+		case "Plastid":
+			codes.Plastid = content
+		case "Hydrogenosome":
+			codes.Hydrogenosome = content
+		}
+	})
+
+	return codes, found
+}
+
+// Codes returns the genetic codes recorded for a TaxID, or for an organism name
+func (gl *GeneticCodeLookup) Codes(taxID string) (TaxCodes, bool) {
+
+	var codes TaxCodes
+
+	if gl == nil {
+		return codes, false
+	}
+
+	taxID = strings.TrimSpace(taxID)
+	if taxID == "" {
+		return codes, false
+	}
+
+	gl.mutex.Lock()
+	defer gl.mutex.Unlock()
+
+	if !IsAllDigits(taxID) {
+		id, ok := gl.names[taxID]
+		if !ok {
+			tx := gl.taxonIndex()
+			matches := tx.LookupName(taxID)
+			// ambiguous names, e.g., homonyms in different kingdoms, are not resolved
+			if len(matches) == 1 {
+				id = matches[0].TaxID
+			}
+			gl.names[taxID] = id
+		}
+		if id == "" {
+			return codes, false
+		}
+		taxID = id
+	}
+
+	codes, ok := gl.codes[taxID]
+	if ok {
+		return codes, true
+	}
+
+	if gl.archive != "" {
+		codes, ok = gl.fetchCodes(taxID)
+	}
+	if !ok {
+		tn := gl.taxonIndex().Node(taxID)
+		if tn != nil {
+			codes = tn.Codes
+			ok = true
+		}
+	}
+
+	if ok {
+		gl.codes[taxID] = codes
+	}
+
+	return codes, ok
+}
+
+// GeneticCode picks the code for the genome in which a sequence resides, given a TaxID or
+// organism name and an organelle qualifier, returning 0 if the organism is not found
+func (gl *GeneticCodeLookup) GeneticCode(taxID, organelle string) int {
+
+	codes, ok := gl.Codes(taxID)
+	if !ok {
+		return 0
+	}
+
+	return SelectGeneticCode(codes, organelle)
+}
+
+// SelectGeneticCode chooses among nuclear, mitochondrial, plastid, and hydrogenosome codes
+// using INSDSeq organelle qualifier values, e.g., mitochondrion:kinetoplast or plastid:chloroplast
+func SelectGeneticCode(codes TaxCodes, organelle string) int {
+
+	str := ""
+
+	org := strings.ToLower(strings.TrimSpace(organelle))
+
+	switch {
+	case org == "", strings.HasPrefix(org, "nuc"), org == "genomic", org == "macronuclear":
+		str = codes.Nuclear
+	case strings.HasPrefix(org, "mito"), strings.Contains(org, "kinetoplast"):
+		str = codes.Mitochondrial
+	case strings.Contains(org, "plast"), org == "cyanelle", org == "chromatophore":
+		str = codes.Plastid
+		if str == "" {
+			// bacterial code is used by plastids unless otherwise recorded
+			str = "11"
+		}
+	case strings.HasPrefix(org, "hydro"):
+		str = codes.Hydrogenosome
+	default:
+		str = codes.Nuclear
+	}
+
+	// organisms without a recorded organelle code fall back to the nuclear code
+	if str == "" {
+		str = codes.Nuclear
+	}
+
+	code, err := strconv.Atoi(str)
+	if err != nil || code < 1 {
+		return 1
+	}
+
+	return code
+}
+
+// insdSeqToProteins translates each coding region, using transl_table if present, otherwise
+// the code inferred from source feature db_xref taxon or organism, and organelle qualifiers
+func insdSeqToProteins(text string, gl *GeneticCodeLookup, genCode int, includeStop bool) string {
+
+	seq := ParseRecord(text, "INSDSeq")
+	if seq == nil {
+		return ""
+	}
+
+	nucs := strings.ToUpper(xmlChildText(seq, "INSDSeq_sequence"))
+	if nucs == "" {
+		return ""
+	}
+
+	accn := xmlChildText(seq, "INSDSeq_accession-version")
+	if accn == "" {
+		accn = xmlChildText(seq, "INSDSeq_primary-accession")
+	}
+
+	taxID := ""
+	organism := xmlChildText(seq, "INSDSeq_organism")
+	organelle := ""
+
+	feats := parseINSDFeatures(seq, map[string]bool{"source": true, "CDS": true})
+
+	for _, feat := range feats {
+		if feat.Key != "source" {
+			continue
+		}
+		for _, qual := range feat.Quals {
+			switch qual.Name {
+			case "db_xref":
+				if strings.HasPrefix(qual.Value, "taxon:") {
+					taxID = strings.TrimPrefix(qual.Value, "taxon:")
+				}
+			case "organism":
+				if organism == "" {
+					organism = qual.Value
+				}
+			case "organelle":
+				organelle = qual.Value
+			}
+		}
+		break
+	}
+
+	// inferred code is shared by all coding regions without transl_table
+	inferred := 0
+	if genCode == 0 && gl != nil {
+		if taxID != "" {
+			inferred = gl.GeneticCode(taxID, organelle)
+		}
+		if inferred == 0 && organism != "" {
+			inferred = gl.GeneticCode(organism, organelle)
+		}
+	}
+
+	var buffer strings.Builder
+
+	for _, feat := range feats {
+
+		if feat.Key != "CDS" {
+			continue
+		}
+
+		code := genCode
+		offset := 0
+		skip := false
+
+		for _, qual := range feat.Quals {
+			switch qual.Name {
+			case "transl_table":
+				if val, err := strconv.Atoi(qual.Value); err == nil && code == 0 {
+					code = val
+				}
+			case "codon_start":
+				if val, err := strconv.Atoi(qual.Value); err == nil && val > 1 && val < 4 {
+					offset = val - 1
+				}
+			case "pseudo", "pseudogene":
+				skip = true
+			}
+		}
+		if skip {
+			continue
+		}
+
+		if code == 0 {
+			code = inferred
+		}
+		if code == 0 {
+			code = 1
+		}
+
+		cds, ok := featureSequence(nucs, feat)
+		if !ok || len(cds) <= offset {
+			continue
+		}
+
+		prot := TranslateCdRegion(cds, code, offset, includeStop, false, false, !feat.Partial5, !feat.Partial3, "")
+
+		buffer.WriteString(accn)
+		buffer.WriteString("\t")
+		buffer.WriteString(featureName(feat))
+		buffer.WriteString("\t")
+		buffer.WriteString(strconv.Itoa(code))
+		buffer.WriteString("\t")
+		buffer.WriteString(strings.TrimSpace(prot))
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
+
+// CreateTranslators runs concurrent INSDSeq coding region translators, with genCode 0
+// selecting the code from each record and the local taxonomy
+func CreateTranslators(gl *GeneticCodeLookup, genCode int, includeStop bool, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	return createINSDSeqConverters("coding region translator",
+		func(text string) string {
+			return insdSeqToProteins(text, gl, genCode, includeStop)
+		}, inp)
+}
@@ -0,0 +1,589 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  taxindex.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/pgzip"
+)
+
+// TAXONOMY QUERY ENGINE
+
+// TaxonIndex holds TaxNode records in memory, with child lists and a name lookup table,
+// for lowest common ancestor, subtree, lineage, and name queries
+type TaxonIndex struct {
+	nodes    map[string]*TaxNode
+	children map[string][]string
+	names    map[string][]string
+	merged   map[string]string
+}
+
+// taxonNameKey normalizes scientific or alternative names for case-insensitive lookup
+func taxonNameKey(str string) string {
+
+	if IsNotASCII(str) {
+		str = TransformAccents(str, true, false)
+	}
+	if HasAdjacentSpaces(str) {
+		str = CompressRunsOfSpaces(str)
+	}
+
+	return strings.ToLower(strings.TrimSpace(str))
+}
+
+// readMergedTaxa reads merged.dmp, which maps retired identifiers to their current TaxID
+func readMergedTaxa(path string) map[string]string {
+
+	merged := make(map[string]string)
+
+	inFile, err := os.Open(filepath.Join(path, "merged.dmp"))
+	if err != nil {
+		// optional file
+		return merged
+	}
+
+	defer inFile.Close()
+
+	scant := bufio.NewScanner(inFile)
+
+	for scant.Scan() {
+
+		cols := strings.Split(scant.Text(), "\t")
+		if len(cols) < 3 || cols[0] == "" || cols[2] == "" {
+			continue
+		}
+
+		merged[cols[0]] = cols[2]
+	}
+
+	return merged
+}
+
+// readTaxonNodeSet reads the TaxNodeSet XML produced by rchive -taxon, optionally compressed,
+// so the dump files do not need to be parsed each time
+func readTaxonNodeSet(fpath string) map[string]*TaxNode {
+
+	taxNodeMap := make(map[string]*TaxNode)
+
+	inFile, err := os.Open(fpath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open taxonomy node file %s - %s\n", fpath, err.Error())
+		os.Exit(1)
+	}
+
+	defer inFile.Close()
+
+	var in io.Reader
+
+	in = inFile
+
+	if strings.HasSuffix(fpath, ".gz") {
+		zpr, err := pgzip.NewReader(bufio.NewReader(inFile))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create decompressor on %s - %s\n", fpath, err.Error())
+			os.Exit(1)
+		}
+
+		defer zpr.Close()
+
+		in = zpr
+	}
+
+	rdr := CreateXMLStreamer(in)
+
+	if rdr == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create XML Block Reader\n")
+		os.Exit(1)
+	}
+
+	PartitionXML("TaxNode", "", false, rdr,
+		func(str string) {
+
+			tn := &TaxNode{}
+			nam := &tn.Names
+			rnk := &tn.Levels
+			gcs := &tn.Codes
+			flg := &tn.Flags
+
+			StreamValues(str[:], "TaxNode", func(tag, attr, content string) {
+
+				content = html.UnescapeString(content)
+
+				switch tag {
+				case "TaxID":
+					tn.TaxID = content
+				case "Rank":
+					tn.Rank = content
+				case "Scientific":
+					tn.Scientific = content
+				case "Division":
+					tn.Division = content
+				case "Lineage":
+					tn.Lineage = content
+				case "Common":
+					nam.Common = append(nam.Common, content)
+				case "GenBank":
+					nam.GenBank = append(nam.GenBank, content)
+				case "Synonym":
+					nam.Synonym = append(nam.Synonym, content)
+				case "Equivalent":
+					nam.Equivalent = append(nam.Equivalent, content)
+				case "Includes":
+					nam.Includes = append(nam.Includes, content)
+				case "Authority":
+					nam.Authority = append(nam.Authority, content)
+				case "Other":
+					nam.Other = append(nam.Other, content)
+				case "Species":
+					rnk.Species = content
+				case "Genus":
+					rnk.Genus = content
+				case "Family":
+					rnk.Family = content
+				case "Order":
+					rnk.Order = content
+				case "Class":
+					rnk.Class = content
+				case "Phylum":
+					rnk.Phylum = content
+				case "Kingdom":
+					rnk.Kingdom = content
+				case "Superkingdom":
+					rnk.Superkingdom = content
+				case "Nuclear":
+					gcs.Nuclear = content
+				case "Mitochondrial":
+					gcs.Mitochondrial = content
+				case "Plastid":
+					gcs.Plastid = content
+				case "Hydrogenosome":
+					gcs.Hydrogenosome = content
+				case "InheritsDiv":
+					flg.InheritDiv = true
+				case "InheritsNuc":
+					flg.InheritNuc = true
+				case "InheritsMito":
+					flg.InheritMito = true
+				case "InheritsPlast":
+					flg.InheritPlast = true
+				case "InheritsHydro":
+					flg.InheritHydro = true
+				case "ParentID":
+					tn.ParentID = content
+				default:
+				}
+			})
+
+			if tn.TaxID != "" {
+				taxNodeMap[tn.TaxID] = tn
+			}
+		})
+
+	return taxNodeMap
+}
+
+// NewTaxonIndex loads a taxonomy dump directory (names.dmp, nodes.dmp, etc.), or a
+// TaxNodeSet XML file saved from rchive -taxon, and builds the query tables
+func NewTaxonIndex(path string) *TaxonIndex {
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to find taxonomy path '%s'\n", path)
+		os.Exit(1)
+	}
+
+	tx := &TaxonIndex{
+		children: make(map[string][]string),
+		names:    make(map[string][]string),
+	}
+
+	if fi.IsDir() {
+		tx.nodes = readTaxonDumps(path)
+		tx.merged = readMergedTaxa(path)
+	} else {
+		tx.nodes = readTaxonNodeSet(path)
+		tx.merged = make(map[string]string)
+	}
+
+	addName := func(name, taxID string) {
+		key := taxonNameKey(name)
+		if key == "" {
+			return
+		}
+		for _, id := range tx.names[key] {
+			if id == taxID {
+				return
+			}
+		}
+		tx.names[key] = append(tx.names[key], taxID)
+	}
+
+	for id, tn := range tx.nodes {
+
+		// root node is its own parent
+		if tn.ParentID != "" && tn.ParentID != id {
+			tx.children[tn.ParentID] = append(tx.children[tn.ParentID], id)
+		}
+
+		addName(tn.Scientific, id)
+
+		nam := &tn.Names
+
+		for _, lst := range [][]string{nam.Common, nam.GenBank, nam.Synonym, nam.Equivalent} {
+			for _, str := range lst {
+				addName(str, id)
+			}
+		}
+	}
+
+	for _, ids := range tx.children {
+		sortTaxIDs(ids)
+	}
+	for _, ids := range tx.names {
+		sortTaxIDs(ids)
+	}
+
+	return tx
+}
+
+// Node returns the record for a TaxID, following merged identifiers to the current node
+func (tx *TaxonIndex) Node(taxID string) *TaxNode {
+
+	if tx == nil {
+		return nil
+	}
+
+	taxID = strings.TrimSpace(taxID)
+
+	tn, ok := tx.nodes[taxID]
+	if ok {
+		return tn
+	}
+
+	curr, ok := tx.merged[taxID]
+	if ok {
+		return tx.nodes[curr]
+	}
+
+	return nil
+}
+
+// Lineage returns the path from the root to the given node, inclusive
+func (tx *TaxonIndex) Lineage(taxID string) []*TaxNode {
+
+	var path []*TaxNode
+
+	tn := tx.Node(taxID)
+
+	// depth limit protects against cycles in damaged dump files
+	for depth := 0; tn != nil && depth < 256; depth++ {
+
+		path = append(path, tn)
+
+		if tn.ParentID == "" || tn.ParentID == tn.TaxID {
+			break
+		}
+
+		tn = tx.nodes[tn.ParentID]
+	}
+
+	// reverse to root-first order
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
+
+// LowestCommonAncestor returns the deepest node shared by the lineages of all
+// recognized identifiers, ignoring any that are not in the index
+func (tx *TaxonIndex) LowestCommonAncestor(taxIDs []string) *TaxNode {
+
+	var common []*TaxNode
+
+	for _, id := range taxIDs {
+
+		path := tx.Lineage(id)
+		if len(path) < 1 {
+			continue
+		}
+
+		if common == nil {
+			common = path
+			continue
+		}
+
+		// lineages are root-first, so shared prefix ends at the ancestor
+		i := 0
+		for i < len(common) && i < len(path) && common[i] == path[i] {
+			i++
+		}
+		common = common[:i]
+
+		if len(common) < 1 {
+			return nil
+		}
+	}
+
+	if len(common) < 1 {
+		return nil
+	}
+
+	return common[len(common)-1]
+}
+
+// Subtree returns all descendants of a node, optionally restricted to a list of ranks
+func (tx *TaxonIndex) Subtree(taxID string, ranks []string) []*TaxNode {
+
+	var res []*TaxNode
+
+	wanted := make(map[string]bool)
+	for _, rnk := range ranks {
+		rnk = strings.ToLower(strings.TrimSpace(rnk))
+		if rnk != "" {
+			wanted[rnk] = true
+		}
+	}
+
+	tn := tx.Node(taxID)
+	if tn == nil {
+		return nil
+	}
+
+	queue := append([]string{}, tx.children[tn.TaxID]...)
+
+	for len(queue) > 0 {
+
+		id := queue[0]
+		queue = queue[1:]
+
+		chld := tx.nodes[id]
+		if chld == nil {
+			continue
+		}
+
+		if len(wanted) == 0 || wanted[strings.ToLower(chld.Rank)] {
+			res = append(res, chld)
+		}
+
+		queue = append(queue, tx.children[id]...)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return lessTaxID(res[i].TaxID, res[j].TaxID) })
+
+	return res
+}
+
+// RankedLineage returns the scientific names at the requested ranks, with empty
+// strings for ranks not present in the lineage
+func (tx *TaxonIndex) RankedLineage(taxID string, ranks []string) []string {
+
+	byRank := make(map[string]string)
+
+	for _, tn := range tx.Lineage(taxID) {
+		rnk := strings.ToLower(tn.Rank)
+		if rnk == "" || rnk == "no rank" || rnk == "clade" {
+			continue
+		}
+		byRank[rnk] = tn.Scientific
+	}
+
+	// newer dumps use domain instead of superkingdom
+	if byRank["superkingdom"] == "" {
+		byRank["superkingdom"] = byRank["domain"]
+	}
+	if byRank["domain"] == "" {
+		byRank["domain"] = byRank["superkingdom"]
+	}
+
+	var res []string
+
+	for _, rnk := range ranks {
+		res = append(res, byRank[strings.ToLower(strings.TrimSpace(rnk))])
+	}
+
+	return res
+}
+
+// LookupName finds identifiers whose scientific, common, GenBank, synonym, or equivalent name matches
+func (tx *TaxonIndex) LookupName(name string) []*TaxNode {
+
+	if tx == nil {
+		return nil
+	}
+
+	var res []*TaxNode
+
+	for _, id := range tx.names[taxonNameKey(name)] {
+		tn := tx.nodes[id]
+		if tn != nil {
+			res = append(res, tn)
+		}
+	}
+
+	return res
+}
+
+// defaultTaxonRanks are reported by -taxon-lineage unless other ranks are requested
+var defaultTaxonRanks = []string{"superkingdom", "phylum", "class", "order", "family", "genus", "species"}
+
+// TaxonQuery handles rchive -taxon-lca, -taxon-subtree, -taxon-lineage, and -taxon-name,
+// reading identifiers or names from the command line or, if absent, one query per input line
+func TaxonQuery(args []string, in io.Reader) int {
+
+	recordCount := 0
+
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Taxonomy path is missing after %s\n", args[0])
+		os.Exit(1)
+	}
+
+	cmd := args[0]
+	path := args[1]
+	args = args[2:]
+
+	var subRanks []string
+	ranks := defaultTaxonRanks
+
+	var items []string
+
+	for len(args) > 0 {
+		switch args[0] {
+		case "-rank", "-ranks":
+			if len(args) < 2 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Rank is missing after %s\n", args[0])
+				os.Exit(1)
+			}
+			ranks = strings.Split(args[1], ",")
+			subRanks = ranks
+			args = args[1:]
+		default:
+			items = append(items, args[0])
+		}
+		args = args[1:]
+	}
+
+	tx := NewTaxonIndex(path)
+
+	wrtr := bufio.NewWriter(os.Stdout)
+
+	defer wrtr.Flush()
+
+	printNode := func(tn *TaxNode) {
+		fmt.Fprintf(wrtr, "%s\t%s\t%s\n", tn.TaxID, tn.Rank, tn.Scientific)
+	}
+
+	// separate multiple identifiers on a line by tab, comma, or space
+	splitIDs := func(line string) []string {
+		return strings.FieldsFunc(line, func(c rune) bool {
+			return c == '\t' || c == ',' || c == ' '
+		})
+	}
+
+	doQuery := func(line string) {
+
+		switch cmd {
+		case "-taxon-lca":
+			tn := tx.LowestCommonAncestor(splitIDs(line))
+			if tn != nil {
+				printNode(tn)
+			} else {
+				fmt.Fprintf(wrtr, "\t\t\n")
+			}
+		case "-taxon-subtree":
+			for _, id := range splitIDs(line) {
+				if tx.Node(id) == nil {
+					fmt.Fprintf(os.Stderr, "Unrecognized TaxID %s\n", id)
+					continue
+				}
+				for _, tn := range tx.Subtree(id, subRanks) {
+					printNode(tn)
+				}
+			}
+		case "-taxon-lineage":
+			for _, id := range splitIDs(line) {
+				fmt.Fprintf(wrtr, "%s\t%s\n", id, strings.Join(tx.RankedLineage(id, ranks), "\t"))
+			}
+		case "-taxon-name":
+			name := strings.TrimSpace(line)
+			matches := tx.LookupName(name)
+			if len(matches) < 1 {
+				fmt.Fprintf(wrtr, "%s\t\t\n", name)
+			}
+			for _, tn := range matches {
+				fmt.Fprintf(wrtr, "%s\t%s\t%s\n", name, tn.TaxID, tn.Scientific)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized taxonomy query %s\n", cmd)
+			os.Exit(1)
+		}
+
+		recordCount++
+	}
+
+	// command-line identifiers are a single LCA query, or separate subtree, lineage, or name queries
+	if len(items) > 0 {
+		if cmd == "-taxon-lca" {
+			doQuery(strings.Join(items, "\t"))
+		} else {
+			for _, item := range items {
+				doQuery(item)
+			}
+		}
+		return recordCount
+	}
+
+	if in == nil {
+		return recordCount
+	}
+
+	scanr := bufio.NewScanner(in)
+
+	for scanr.Scan() {
+
+		line := scanr.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		doQuery(line)
+	}
+
+	return recordCount
+}
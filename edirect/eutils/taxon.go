@@ -93,10 +93,8 @@ type TaxNode struct {
 	ParentID   string
 }
 
-// CreateTaxonRecords reads taxonomy files and create TaxNode records
-func CreateTaxonRecords(path string) int {
-
-	recordCount := 0
+// readTaxonDumps reads taxonomy dump files into a map of TaxNode records, keyed by TaxID
+func readTaxonDumps(path string) map[string]*TaxNode {
 
 	taxNodeMap := make(map[string]*TaxNode)
 
@@ -438,26 +436,46 @@ func CreateTaxonRecords(path string) int {
 	readRankedLineage("rankedlineage.dmp")
 	readNodeTable("nodes.dmp")
 
+	return taxNodeMap
+}
+
+// lessTaxID compares numeric taxonomy identifiers, or other digit-only keys, without conversion
+func lessTaxID(a, b string) bool {
+
+	// numeric sort on strings checks lengths first
+	if IsAllDigits(a) && IsAllDigits(b) {
+		lni := len(a)
+		lnj := len(b)
+		// shorter string is numerically less, assuming no leading zeros
+		if lni < lnj {
+			return true
+		}
+		if lni > lnj {
+			return false
+		}
+	}
+	// same length or non-numeric, can now do string comparison on contents
+	return a < b
+}
+
+// sortTaxIDs sorts numeric taxonomy identifiers in place, non-numeric keys alphabetically
+func sortTaxIDs(keys []string) {
+
+	sort.Slice(keys, func(i, j int) bool { return lessTaxID(keys[i], keys[j]) })
+}
+
+// CreateTaxonRecords reads taxonomy files and create TaxNode records
+func CreateTaxonRecords(path string) int {
+
+	recordCount := 0
+
+	taxNodeMap := readTaxonDumps(path)
+
 	var keys []string
 	for _, tn := range taxNodeMap {
 		keys = append(keys, tn.TaxID)
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		// numeric sort on strings checks lengths first
-		if IsAllDigits(keys[i]) && IsAllDigits(keys[j]) {
-			lni := len(keys[i])
-			lnj := len(keys[j])
-			// shorter string is numerically less, assuming no leading zeros
-			if lni < lnj {
-				return true
-			}
-			if lni > lnj {
-				return false
-			}
-		}
-		// same length or non-numeric, can now do string comparison on contents
-		return keys[i] < keys[j]
-	})
+	sortTaxIDs(keys)
 
 	var buffer strings.Builder
 	count := 0
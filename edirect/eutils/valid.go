@@ -36,8 +36,8 @@ import (
 	"strings"
 )
 
-// ValidateXML checks for well-formed XML
-func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int) int {
+// ValidateXML checks for well-formed XML, and against a DTD or XSD content model if provided
+func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int, schema *XMLSchema) int {
 
 	if rdr == nil {
 		return 0
@@ -170,12 +170,57 @@ func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int) int {
 
 	currID := ""
 
+	// problems are held until the next record identifier is read, then divided at the
+	// start of that record, the shallowest element opened since the previous identifier,
+	// so that a problem found before a record's identifier is not given the prior one
+	type verifyProblem struct {
+		line int
+		msg  string
+	}
+
+	var pending []verifyProblem
+	split := 0
+	minDepth := -1
+
+	report := func(line int, msg string) {
+		if find == nil || find.Index == "" {
+			fmt.Fprintf(os.Stdout, "%s%8d\t%s\n", currID, line, msg)
+			return
+		}
+		pending = append(pending, verifyProblem{line: line, msg: msg})
+	}
+
+	flush := func(upto int, id string) {
+		for _, prob := range pending[:upto] {
+			fmt.Fprintf(os.Stdout, "%s%8d\t%s\n", id, prob.line, prob.msg)
+		}
+		pending = pending[upto:]
+	}
+
+	// startElement notes the earliest pending problem that may belong to the next record
+	startElement := func(depth int) {
+		if minDepth < 0 || depth <= minDepth {
+			minDepth = depth
+			split = len(pending)
+		}
+	}
+
 	// verifyLevel recursive definition
 	var verifyLevel func(string, string, int)
 
 	// verify integrity of XML object nesting (well-formed)
 	verifyLevel = func(parent, prev string, level int) {
 
+		// child element names and text presence are collected for the content model check
+		var children []string
+		hasText := false
+
+		reportSchema := func(errs []string, line int) {
+			for _, str := range errs {
+				report(line, str)
+			}
+		}
+
 		status := START
 		for tkn := range tknq {
 
@@ -192,20 +237,34 @@ func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int) int {
 
 			switch tag {
 			case STARTTAG:
-				if status == CHAR && !doMixed {
-					fmt.Fprintf(os.Stdout, "%s%8d\t<%s> not expected after contents\n", currID, line, name)
+				startElement(level + 1)
+				if status == CHAR && !doMixed && (schema == nil || !schema.IsMixed(parent)) {
+					report(line, "<"+name+"> not expected after contents")
+				}
+				if schema != nil {
+					children = append(children, name)
+					reportSchema(schema.CheckElement(name, tkn.Attr), line)
 				}
 				verifyLevel(name, parent, level+1)
 				// returns here after recursion
 				status = STOP
 			case SELFTAG:
+				startElement(level + 1)
+				if schema != nil {
+					children = append(children, name)
+					reportSchema(schema.CheckElement(name, tkn.Attr), line)
+					reportSchema(schema.CheckContent(name, nil, false), line)
+				}
 				status = OTHER
 			case STOPTAG:
 				if parent != name && parent != "" {
-					fmt.Fprintf(os.Stdout, "%s%8d\tExpected </%s>, found </%s>\n", currID, line, parent, name)
+					report(line, "Expected </"+parent+">, found </"+name+">")
 				}
 				if level < 1 {
-					fmt.Fprintf(os.Stdout, "%s%8d\tUnexpected </%s> at end of XML\n", currID, line, name)
+					report(line, "Unexpected </"+name+"> at end of XML")
+				}
+				if schema != nil && parent != "" {
+					reportSchema(schema.CheckContent(parent, children, hasText), line)
 				}
 				// break recursion
 				return
@@ -214,21 +273,30 @@ func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int) int {
 				if find != nil && find.Index != "" {
 					if parent == find.Match || find.Match == "" {
 						if find.Parent == "" || prev == find.Parent {
+							if currID == "" {
+								// everything before the first identifier is in the first record
+								split = 0
+							}
+							flush(split, currID)
 							currID = name + "\t"
+							flush(len(pending), currID)
+							split = 0
+							minDepth = -1
 						}
 					}
 				}
-				if status != START && !doMixed {
-					fmt.Fprintf(os.Stdout, "%s%8d\tContents not expected before </%s>\n", currID, line, parent)
+				if status != START && !doMixed && (schema == nil || !schema.IsMixed(parent)) {
+					report(line, "Contents not expected before </"+parent+">")
 				}
 				if allowEmbed {
 					if unbalancedHTML(name) {
-						fmt.Fprintf(os.Stdout, "%s%8d\tUnbalanced mixed-content tags in <%s>\n", currID, line, parent)
+						report(line, "Unbalanced mixed-content tags in <"+parent+">")
 					}
 					if html && encodedHTML(name) {
-						fmt.Fprintf(os.Stdout, "%s%8d\tEncoded mixed-content markup in <%s>\n", currID, line, parent)
+						report(line, "Encoded mixed-content markup in <"+parent+">")
 					}
 				}
+				hasText = true
 				status = CHAR
 			case CDATATAG, COMMENTTAG:
 				status = OTHER
@@ -237,7 +305,7 @@ func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int) int {
 			case NOTAG:
 			case ISCLOSED:
 				if level > 0 {
-					fmt.Fprintf(os.Stdout, "%s%8d\tUnexpected end of data\n", currID, line)
+					report(line, "Unexpected end of data")
 				}
 				return
 			default:
@@ -248,6 +316,9 @@ func ValidateXML(rdr <-chan XMLBlock, fnd string, html bool, max int) int {
 
 	verifyLevel("", "", 0)
 
+	// problems after the last identifier belong to the last record
+	flush(len(pending), currID)
+
 	// raised maxDepth test to 100 because of PMC nesting depth of 97 levels
 	// in oa_comm_xml.PMC009xxxxxx.baseline.2022-12-18.tar.gz release file,
 	// from complex math formulae markup in PMC9439944
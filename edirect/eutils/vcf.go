@@ -0,0 +1,334 @@
+// ===========================================================================
+//
+//                            PUBLIC DOMAIN NOTICE
+//            National Center for Biotechnology Information (NCBI)
+//
+//  This software/database is a "United States Government Work" under the
+//  terms of the United States Copyright Act. It was written as part of
+//  the author's official duties as a United States Government employee and
+//  thus cannot be copyrighted. This software/database is freely available
+//  to the public for use. The National Library of Medicine and the U.S.
+//  Government do not place any restriction on its use or reproduction.
+//  We would, however, appreciate having the NCBI and the author cited in
+//  any work or product based on this material.
+//
+//  Although all reasonable efforts have been taken to ensure the accuracy
+//  and reliability of the software and data, the NLM and the U.S.
+//  Government do not and cannot warrant the performance or results that
+//  may be obtained by using this software or data. The NLM and the U.S.
+//  Government disclaim all warranties, express or implied, including
+//  warranties of performance, merchantability or fitness for any particular
+//  purpose.
+//
+// ===========================================================================
+//
+// File Name:  vcf.go
+//
+// Author:  Jonathan Kans
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// VCF TO SPDI / HGVS XML CONVERTER
+
+// TrimAlleles removes bases shared by REF and ALT, suffix first and then prefix,
+// returning the 0-based SPDI position and the minimal deleted and inserted strings
+func TrimAlleles(pos int, ref, alt string) (int, string, string) {
+
+	// VCF position is 1-based, SPDI position is 0-based
+	return trimSPDI(pos-1, strings.ToUpper(ref), strings.ToUpper(alt))
+}
+
+// VariantType classifies a trimmed REF/ALT pair
+func VariantType(del, ins string) string {
+
+	switch {
+	case len(del) == 1 && len(ins) == 1:
+		return "SNV"
+	case del == "" && ins == "":
+		return "Identity"
+	case ins == "":
+		return "Deletion"
+	case del == "":
+		return "Insertion"
+	case len(del) == len(ins):
+		return "MNV"
+	}
+
+	return "Indel"
+}
+
+// SpdiToHgvs writes a genomic HGVS expression for a trimmed SPDI variant
+func SpdiToHgvs(accn string, pos int, del, ins string) string {
+
+	// first and last 1-based positions of deleted bases
+	start := strconv.Itoa(pos + 1)
+	stop := strconv.Itoa(pos + len(del))
+
+	rng := start
+	if len(del) > 1 {
+		rng = start + "_" + stop
+	}
+
+	switch {
+	case len(del) == 1 && len(ins) == 1:
+		return accn + ":g." + start + del + ">" + ins
+	case del == "" && ins == "":
+		return accn + ":g." + start + "="
+	case ins == "":
+		return accn + ":g." + rng + "del"
+	case del == "":
+		// insertion lies between the flanking bases
+		return accn + ":g." + strconv.Itoa(pos) + "_" + strconv.Itoa(pos+1) + "ins" + ins
+	}
+
+	return accn + ":g." + rng + "delins" + ins
+}
+
+// vcfElementName converts an INFO or FORMAT key into a legal XML element name
+func vcfElementName(str string) string {
+
+	var buffer strings.Builder
+
+	for i, ch := range str {
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch == '_':
+			buffer.WriteRune(ch)
+		case ch >= '0' && ch <= '9', ch == '-', ch == '.':
+			if i == 0 {
+				buffer.WriteRune('_')
+			}
+			buffer.WriteRune(ch)
+		default:
+			buffer.WriteRune('_')
+		}
+	}
+
+	return buffer.String()
+}
+
+// vcfZygosity summarizes a GT genotype string
+func vcfZygosity(gt string) string {
+
+	alleles := strings.FieldsFunc(gt, func(c rune) bool { return c == '/' || c == '|' })
+
+	if len(alleles) == 0 {
+		return ""
+	}
+
+	for _, al := range alleles {
+		if al == "." {
+			return "nocall"
+		}
+	}
+
+	if len(alleles) == 1 {
+		if alleles[0] == "0" {
+			return "reference"
+		}
+		return "hemizygous"
+	}
+
+	for _, al := range alleles[1:] {
+		if al != alleles[0] {
+			return "heterozygous"
+		}
+	}
+
+	if alleles[0] == "0" {
+		return "reference"
+	}
+
+	return "homozygous"
+}
+
+// VCFtoXML reads VCF rows and sends one XML record per row, with each ALT allele
+// normalized to SPDI and HGVS, and INFO and per-sample FORMAT fields split out
+func VCFtoXML(inp io.Reader) <-chan string {
+
+	if inp == nil {
+		return nil
+	}
+
+	out := make(chan string, chanDepth)
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create VCF to XML converter channel\n")
+		os.Exit(1)
+	}
+
+	convertVCF := func(inp io.Reader, out chan<- string) {
+
+		// close channel when all records have been sent
+		defer close(out)
+
+		var rec strings.Builder
+
+		// writeValues splits comma-separated values into repeated elements
+		writeValues := func(spaces, tag, value string) {
+
+			tag = vcfElementName(tag)
+			for _, val := range strings.Split(value, ",") {
+				writeXMLElement(&rec, spaces, tag, val)
+			}
+		}
+
+		scanr := bufio.NewScanner(inp)
+		scanr.Buffer(make([]byte, 0, 65536), 64*1024*1024)
+
+		var samples []string
+
+		row := 0
+
+		for scanr.Scan() {
+
+			row++
+			line := strings.TrimRight(scanr.Text(), "\r")
+
+			if line == "" || strings.HasPrefix(line, "##") {
+				continue
+			}
+
+			cols := strings.Split(line, "\t")
+
+			if strings.HasPrefix(line, "#") {
+				// header line names the samples after the FORMAT column
+				if len(cols) > 9 {
+					samples = cols[9:]
+				}
+				continue
+			}
+
+			if len(cols) < 8 {
+				fmt.Fprintf(os.Stderr, "\nERROR: VCF line %d has %d columns, at least 8 required\n", row, len(cols))
+				continue
+			}
+
+			chrom := cols[0]
+			pos, err := strconv.Atoi(cols[1])
+			if err != nil || pos < 0 {
+				fmt.Fprintf(os.Stderr, "\nERROR: VCF line %d has invalid position '%s'\n", row, cols[1])
+				continue
+			}
+			ref := cols[3]
+
+			rec.Reset()
+
+			rec.WriteString("  <VCFRecord>\n")
+
+			writeXMLElement(&rec, "    ", "Chrom", chrom)
+			writeXMLElement(&rec, "    ", "Pos", cols[1])
+			if cols[2] != "." {
+				for _, id := range strings.Split(cols[2], ";") {
+					writeXMLElement(&rec, "    ", "ID", id)
+				}
+			}
+			writeXMLElement(&rec, "    ", "Ref", ref)
+			if cols[5] != "." {
+				writeXMLElement(&rec, "    ", "Qual", cols[5])
+			}
+			if cols[6] != "." {
+				for _, flt := range strings.Split(cols[6], ";") {
+					writeXMLElement(&rec, "    ", "Filter", flt)
+				}
+			}
+
+			// one Variant block per ALT allele
+			for i, alt := range strings.Split(cols[4], ",") {
+
+				rec.WriteString("    <Variant>\n")
+
+				writeXMLElement(&rec, "      ", "Allele", strconv.Itoa(i+1))
+				writeXMLElement(&rec, "      ", "Alt", alt)
+
+				// symbolic, breakend, and missing alleles cannot be expressed as SPDI
+				if alt == "." || alt == "*" || strings.ContainsAny(alt, "<>[]") || strings.Trim(strings.ToUpper(ref+alt), "ACGTN") != "" {
+					writeXMLElement(&rec, "      ", "Type", "Symbolic")
+					rec.WriteString("    </Variant>\n")
+					continue
+				}
+
+				spos, del, ins := TrimAlleles(pos, ref, alt)
+
+				writeXMLElement(&rec, "      ", "Type", VariantType(del, ins))
+
+				rec.WriteString("      <SPDI>\n")
+				writeXMLElement(&rec, "        ", "Accession", chrom)
+				writeXMLElement(&rec, "        ", "Position", strconv.Itoa(spos))
+				writeXMLElement(&rec, "        ", "Deleted", del)
+				writeXMLElement(&rec, "        ", "Inserted", ins)
+				writeXMLElement(&rec, "        ", "Spdi", chrom+":"+strconv.Itoa(spos)+":"+del+":"+ins)
+				rec.WriteString("      </SPDI>\n")
+
+				writeXMLElement(&rec, "      ", "Hgvs", SpdiToHgvs(chrom, spos, del, ins))
+
+				rec.WriteString("    </Variant>\n")
+			}
+
+			if cols[7] != "." && cols[7] != "" {
+				rec.WriteString("    <Info>\n")
+				for _, item := range strings.Split(cols[7], ";") {
+					if item == "" {
+						continue
+					}
+					key, val := SplitInTwoLeft(item, "=")
+					if val == "" && !strings.Contains(item, "=") {
+						// flag field
+						val = "true"
+					}
+					writeValues("      ", key, val)
+				}
+				rec.WriteString("    </Info>\n")
+			}
+
+			if len(cols) > 9 {
+
+				keys := strings.Split(cols[8], ":")
+
+				for j, smp := range cols[9:] {
+
+					rec.WriteString("    <Sample>\n")
+
+					if j < len(samples) {
+						writeXMLElement(&rec, "      ", "Name", samples[j])
+					}
+
+					vals := strings.Split(smp, ":")
+					for k, key := range keys {
+						if k >= len(vals) || vals[k] == "." {
+							continue
+						}
+						if key == "GT" {
+							writeXMLElement(&rec, "      ", "GT", vals[k])
+							if zyg := vcfZygosity(vals[k]); zyg != "" {
+								writeXMLElement(&rec, "      ", "Zygosity", zyg)
+							}
+							continue
+						}
+						writeValues("      ", key, vals[k])
+					}
+
+					rec.WriteString("    </Sample>\n")
+				}
+			}
+
+			rec.WriteString("  </VCFRecord>\n")
+
+			out <- rec.String()
+		}
+	}
+
+	// launch single converter goroutine
+	go convertVCF(inp, out)
+
+	return out
+}
@@ -945,6 +945,19 @@ func CreateXMLUnshuffler(inp <-chan XMLRecord) <-chan XMLRecord {
 // CreateXMLConsumers runs multiple query processing go routines
 func CreateXMLConsumers(cmds *Block, parent, hd, tl string, transform map[string]string, forClassify bool, histogram map[string]int, inp <-chan XMLRecord) <-chan XMLRecord {
 
+	return createConsumers(cmds, parent, hd, tl, transform, forClassify, false, false, histogram, inp)
+}
+
+// CreateJSONConsumers runs multiple query processing go routines for xtract -output json,
+// typed writes numbers, true, false, and null as JSON literals instead of strings
+func CreateJSONConsumers(cmds *Block, parent string, typed bool, transform map[string]string, forClassify bool, histogram map[string]int, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	return createConsumers(cmds, parent, "", "", transform, forClassify, true, typed, histogram, inp)
+}
+
+// createConsumers is the common implementation for text and JSON extraction
+func createConsumers(cmds *Block, parent, hd, tl string, transform map[string]string, forClassify, asJSON, typed bool, histogram map[string]int, inp <-chan XMLRecord) <-chan XMLRecord {
+
 	if inp == nil {
 		return nil
 	}
@@ -985,7 +998,12 @@ func CreateXMLConsumers(cmds *Block, parent, hd, tl string, transform map[string
 				continue
 			}
 
-			str := ProcessExtract(text[:], parent, idx, hd, tl, transform, srchr, histogram, cmds)
+			str := ""
+			if asJSON {
+				str = ProcessExtractJSON(text[:], parent, idx, typed, transform, srchr, histogram, cmds)
+			} else {
+				str = ProcessExtract(text[:], parent, idx, hd, tl, transform, srchr, histogram, cmds)
+			}
 
 			// send even if empty to get all record counts for reordering
 			out <- XMLRecord{Index: idx, Ident: ident, Text: str}
@@ -1353,6 +1371,61 @@ func DrainExtractions(head, tail, posn string, mpty, idnt bool, histogram map[st
 	return recordCount, byteCount
 }
 
+// WRITE XML RECORDS GENERATED BY FORMAT CONVERTERS
+
+// writeXMLElement adds an indented element with escaped contents to a record under construction
+func writeXMLElement(buffer *strings.Builder, spaces, tag, value string) {
+
+	buffer.WriteString(spaces)
+	buffer.WriteString("<")
+	buffer.WriteString(tag)
+	buffer.WriteString(">")
+	buffer.WriteString(html.EscapeString(value))
+	buffer.WriteString("</")
+	buffer.WriteString(tag)
+	buffer.WriteString(">\n")
+}
+
+// DrainConverter writes XML records from a format converter, printing head before the
+// first record and tail after the last, so that empty input produces no output
+func DrainConverter(head, tail string, inp <-chan string) (int, int) {
+
+	if inp == nil {
+		return 0, 0
+	}
+
+	recordCount := 0
+	byteCount := 0
+
+	for str := range inp {
+
+		if str == "" {
+			continue
+		}
+
+		if recordCount == 0 {
+			os.Stdout.WriteString(head)
+		}
+
+		recordCount++
+		byteCount += len(str)
+
+		// send result to stdout
+		os.Stdout.WriteString(str)
+		if !strings.HasSuffix(str, "\n") {
+			os.Stdout.WriteString("\n")
+		}
+
+		runtime.Gosched()
+	}
+
+	if recordCount > 0 {
+		os.Stdout.WriteString(tail)
+	}
+
+	return recordCount, byteCount
+}
+
 // PARSE XML INTO TOKENS, IDENTIFIERS, OR STRUCTURED RECORD OBJECT
 
 // XML token type
@@ -165,6 +165,9 @@ const (
 	DEF
 	REG
 	EXP
+	PROBE
+	MISMATCH
+	EDITS
 	COLOR
 	POSITION
 	SELECT
@@ -226,7 +229,13 @@ const (
 	NCBI2NA
 	NCBI4NA
 	MOLWT
+	ISOELECTRIC
+	EXTINCT
+	GRAVY
+	INSTABILITY
+	COMPOSITION
 	HGVS
+	MOTIF
 	ELSE
 	VARIABLE
 	ACCUMULATOR
@@ -429,7 +438,13 @@ var argTypeIs = map[string]ArgumentType{
 	"-ncbi2na":      EXTRACTION,
 	"-ncbi4na":      EXTRACTION,
 	"-molwt":        EXTRACTION,
+	"-pi":           EXTRACTION,
+	"-extinct":      EXTRACTION,
+	"-gravy":        EXTRACTION,
+	"-instability":  EXTRACTION,
+	"-composition":  EXTRACTION,
 	"-hgvs":         EXTRACTION,
+	"-motif":        EXTRACTION,
 	"-else":         EXTRACTION,
 	"-pfx":          CUSTOMIZATION,
 	"-sfx":          CUSTOMIZATION,
@@ -457,6 +472,9 @@ var argTypeIs = map[string]ArgumentType{
 	"-def":          CUSTOMIZATION,
 	"-reg":          CUSTOMIZATION,
 	"-exp":          CUSTOMIZATION,
+	"-probe":        CUSTOMIZATION,
+	"-mismatches":   CUSTOMIZATION,
+	"-edits":        CUSTOMIZATION,
 	"-color":        CUSTOMIZATION,
 }
 
@@ -538,6 +556,9 @@ var opTypeIs = map[string]OpType{
 	"-def":          DEF,
 	"-reg":          REG,
 	"-exp":          EXP,
+	"-probe":        PROBE,
+	"-mismatches":   MISMATCH,
+	"-edits":        EDITS,
 	"-color":        COLOR,
 	"-position":     POSITION,
 	"-select":       SELECT,
@@ -605,7 +626,13 @@ var opTypeIs = map[string]OpType{
 	"-ncbi2na":      NCBI2NA,
 	"-ncbi4na":      NCBI4NA,
 	"-molwt":        MOLWT,
+	"-pi":           ISOELECTRIC,
+	"-extinct":      EXTINCT,
+	"-gravy":        GRAVY,
+	"-instability":  INSTABILITY,
+	"-composition":  COMPOSITION,
 	"-hgvs":         HGVS,
+	"-motif":        MOTIF,
 	"-else":         ELSE,
 }
 
@@ -743,6 +770,73 @@ type Limiter struct {
 	Lvl int
 }
 
+// jsonSep separates multiple values returned by processClause for -output json
+const jsonSep = "\x1e"
+
+// jsonField holds the values collected under one key, either strings or nested objects
+type jsonField struct {
+	Key    string
+	Values []interface{}
+}
+
+// jsonObject accumulates xtract -output json results while keeping keys in order of first appearance
+type jsonObject struct {
+	Fields []*jsonField
+	index  map[string]*jsonField
+}
+
+func newJSONObject() *jsonObject {
+
+	return &jsonObject{index: make(map[string]*jsonField)}
+}
+
+// add appends a string or nested object to the values for a key
+func (obj *jsonObject) add(key string, val interface{}) {
+
+	fld, ok := obj.index[key]
+	if !ok {
+		fld = &jsonField{Key: key}
+		obj.index[key] = fld
+		obj.Fields = append(obj.Fields, fld)
+	}
+
+	fld.Values = append(fld.Values, val)
+}
+
+// write prints the object on one line, values are always collected into an array so
+// that each key keeps the same shape in every record, and are strings unless typed is set
+func (obj *jsonObject) write(buffer *strings.Builder, typed bool) {
+
+	writeOne := func(val interface{}) {
+		switch v := val.(type) {
+		case string:
+			writeJSONValue(buffer, v, !typed)
+		case *jsonObject:
+			v.write(buffer, typed)
+		}
+	}
+
+	buffer.WriteString("{")
+
+	for i, fld := range obj.Fields {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		writeJSONString(buffer, fld.Key)
+		buffer.WriteString(":")
+		buffer.WriteString("[")
+		for j, val := range fld.Values {
+			if j > 0 {
+				buffer.WriteString(",")
+			}
+			writeOne(val)
+		}
+		buffer.WriteString("]")
+	}
+
+	buffer.WriteString("}")
+}
+
 // DebugBlock examines structure of parsed arguments (undocumented)
 /*
 func DebugBlock(blk *Block, depth int) {
@@ -1508,7 +1602,7 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 				comm = append(comm, op)
 				status = UNSET
 			case ELEMENT:
-			case TAB, RET, PFX, SFX, SEP, LBL, TAG, ATT, ATR, END, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, REG, EXP, COLOR:
+			case TAB, RET, PFX, SFX, SEP, LBL, TAG, ATT, ATR, END, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, REG, EXP, PROBE, MISMATCH, EDITS, COLOR:
 			case CLS:
 				op := &Operation{Type: LBL, Value: ">"}
 				comm = append(comm, op)
@@ -1707,7 +1801,7 @@ func ParseArguments(cmdargs []string, pttrn string) *Block {
 			switch status {
 			case UNSET:
 				status, isExtraction = nextStatus(str)
-			case TAB, RET, PFX, SFX, SEP, LBL, CLS, SLF, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, REG, EXP, COLOR:
+			case TAB, RET, PFX, SFX, SEP, LBL, CLS, SLF, PFC, DEQ, PLG, ELG, WRP, ENC, DEF, REG, EXP, PROBE, MISMATCH, EDITS, COLOR:
 				op := &Operation{Type: status, Value: ConvertSlash(str)}
 				comm = append(comm, op)
 				status = UNSET
@@ -2386,8 +2480,82 @@ func printJSONtree(node *XMLNode, proc func(string)) {
 var (
 	rlock sync.Mutex
 	replx map[string]*regexp.Regexp
+	apxsr map[string]*ApproxSearcher
 )
 
+// indexPairs returns the overlapping adjacent word pairs generated by -pairs, and by -pairx,
+// which also adds isolated singletons between stop words, for the PAIR index and its queries
+func indexPairs(str string, doSingle, deStop, doStem bool) []string {
+
+	var arry []string
+
+	if str == "" {
+		return nil
+	}
+
+	if doSingle {
+		str = PrepareForIndexing(str, true, false, true, true, true)
+	}
+
+	// break clauses at punctuation other than space, and at non-ASCII characters
+	clauses := strings.FieldsFunc(str, func(c rune) bool {
+		return (!unicode.IsLetter(c) && !unicode.IsDigit(c)) && c != ' ' || c > 127
+	})
+
+	// plus sign separates runs of unpunctuated words
+	phrases := strings.Join(clauses, " + ")
+
+	// break phrases into individual words
+	words := strings.FieldsFunc(phrases, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+
+	// word pairs (or isolated singletons) separated by stop words
+	if len(words) > 1 {
+		past := ""
+		run := 0
+		for _, item := range words {
+			if item == "+" {
+				if doSingle && run == 1 && past != "" {
+					arry = append(arry, past)
+				}
+				past = ""
+				run = 0
+				continue
+			}
+			item = strings.ToLower(item)
+			if deStop {
+				if IsStopWord(item) {
+					if doSingle && run == 1 && past != "" {
+						arry = append(arry, past)
+					}
+					past = ""
+					run = 0
+					continue
+				}
+			}
+			if doStem {
+				item = porter2.Stem(item)
+				item = strings.TrimSpace(item)
+			}
+			if item == "" {
+				past = ""
+				continue
+			}
+			if past != "" {
+				arry = append(arry, past+" "+item)
+			}
+			past = item
+			run++
+		}
+		if doSingle && run == 1 && past != "" {
+			arry = append(arry, past)
+		}
+	}
+
+	return arry
+}
+
 // processClause handles comma-separated -element arguments
 func processClause(
 	curr *XMLNode,
@@ -2401,6 +2569,9 @@ func processClause(
 	def string,
 	reg string,
 	exp string,
+	prb string,
+	mis int,
+	ind bool,
 	wrp bool,
 	status OpType,
 	index int,
@@ -2419,6 +2590,7 @@ func processClause(
 		rlock.Lock()
 		if replx == nil {
 			replx = make(map[string]*regexp.Regexp)
+			apxsr = make(map[string]*ApproxSearcher)
 		}
 		rlock.Unlock()
 	}
@@ -3947,6 +4119,37 @@ func processClause(
 			}
 		})
 
+	case ISOELECTRIC, EXTINCT, GRAVY, INSTABILITY:
+		processElement(func(str string) {
+			switch status {
+			case ISOELECTRIC:
+				str = ProteinIsoelectricPoint(str)
+			case EXTINCT:
+				str = ProteinExtinction(str, false)
+			case GRAVY:
+				str = ProteinHydropathy(str)
+			case INSTABILITY:
+				str = ProteinInstability(str)
+			}
+			if str != "" {
+				ok = true
+				buffer.WriteString(between)
+				buffer.WriteString(str)
+				between = sep
+			}
+		})
+
+	case COMPOSITION:
+		processElement(func(str string) {
+			// always reports all 20 standard residues, in the same order
+			for _, item := range ProteinComposition(str) {
+				ok = true
+				buffer.WriteString(between)
+				buffer.WriteString(item)
+				between = sep
+			}
+		})
+
 	case HGVS:
 		processElement(func(str string) {
 			if str != "" {
@@ -3958,6 +4161,41 @@ func processClause(
 			}
 		})
 
+	case MOTIF:
+		if prb == "" {
+			break
+		}
+		// searchers are shared by all records with the same probe settings
+		key := prb + "\t" + strconv.Itoa(mis) + "\t" + strconv.FormatBool(ind)
+		rlock.Lock()
+		apx, found := apxsr[key]
+		if !found {
+			apx = ApproximateSearcher(strings.Fields(prb), false, false, false, mis, ind)
+			apxsr[key] = apx
+		}
+		rlock.Unlock()
+		processElement(func(str string) {
+			if str != "" {
+				// each hit is reported as start..stop:differences:alignment:pattern
+				apx.Search(str,
+					func(hit SequenceHit) bool {
+						ok = true
+						buffer.WriteString(between)
+						buffer.WriteString(strconv.Itoa(hit.Start))
+						buffer.WriteString("..")
+						buffer.WriteString(strconv.Itoa(hit.Stop))
+						buffer.WriteString(":")
+						buffer.WriteString(strconv.Itoa(hit.Mismatches))
+						buffer.WriteString(":")
+						buffer.WriteString(hit.Cigar)
+						buffer.WriteString(":")
+						buffer.WriteString(hit.Pattern)
+						between = sep
+						return true
+					})
+			}
+		})
+
 	case INDICES, ARTICLE, ABSTRACT, PARAGRAPH, STEMMED:
 		// build positional index with a choice of TITL, TIAB, ABST, TEXT, and STEM field names
 		indices := make(map[string][]string)
@@ -4248,81 +4486,11 @@ func processClause(
 
 	case PAIRS, PAIRX:
 		processElement(func(str string) {
-			if str != "" {
-
-				doSingle := (status == PAIRX)
-
-				if doSingle {
-					str = PrepareForIndexing(str, true, false, true, true, true)
-				}
-
-				// break clauses at punctuation other than space, and at non-ASCII characters
-				clauses := strings.FieldsFunc(str, func(c rune) bool {
-					return (!unicode.IsLetter(c) && !unicode.IsDigit(c)) && c != ' ' || c > 127
-				})
-
-				// plus sign separates runs of unpunctuated words
-				phrases := strings.Join(clauses, " + ")
-
-				// break phrases into individual words
-				words := strings.FieldsFunc(phrases, func(c rune) bool {
-					return !unicode.IsLetter(c) && !unicode.IsDigit(c)
-				})
-
-				// word pairs (or isolated singletons) separated by stop words
-				if len(words) > 1 {
-					past := ""
-					run := 0
-					for _, item := range words {
-						if item == "+" {
-							if doSingle && run == 1 && past != "" {
-								ok = true
-								buffer.WriteString(between)
-								buffer.WriteString(past)
-								between = sep
-							}
-							past = ""
-							run = 0
-							continue
-						}
-						item = strings.ToLower(item)
-						if deStop {
-							if IsStopWord(item) {
-								if doSingle && run == 1 && past != "" {
-									ok = true
-									buffer.WriteString(between)
-									buffer.WriteString(past)
-									between = sep
-								}
-								past = ""
-								run = 0
-								continue
-							}
-						}
-						if doStem {
-							item = porter2.Stem(item)
-							item = strings.TrimSpace(item)
-						}
-						if item == "" {
-							past = ""
-							continue
-						}
-						if past != "" {
-							ok = true
-							buffer.WriteString(between)
-							buffer.WriteString(past + " " + item)
-							between = sep
-						}
-						past = item
-						run++
-					}
-					if doSingle && run == 1 && past != "" {
-						ok = true
-						buffer.WriteString(between)
-						buffer.WriteString(past)
-						between = sep
-					}
-				}
+			for _, item := range indexPairs(str, status == PAIRX, deStop, doStem) {
+				ok = true
+				buffer.WriteString(between)
+				buffer.WriteString(item)
+				between = sep
 			}
 		})
 
@@ -4795,6 +4963,7 @@ func processInstructions(
 	transform map[string]string,
 	srchr *FSMSearcher,
 	histogram map[string]int,
+	obj *jsonObject,
 	accum func(string),
 ) (string, string) {
 
@@ -4814,6 +4983,11 @@ func processInstructions(
 	reg := ""
 	exp := ""
 
+	// -probe patterns for -motif, with -mismatches or -edits limit
+	prb := ""
+	mis := 0
+	ind := false
+
 	col := "\t"
 	lin := "\n"
 
@@ -4822,6 +4996,23 @@ func processInstructions(
 
 	wrp := false
 
+	// -lbl value becomes the key for the next -output json result
+	key := ""
+
+	// addToObject sends individual values to the -output json object, ignoring separators and wrappers
+	addToObject := func(op *Operation) {
+		txt, ok := processClause(curr, op.Stages, mask, "", "", "", "", jsonSep, def, reg, exp, prb, mis, ind, false, op.Type, index, level, variables, transform, srchr, histogram)
+		if ok {
+			if key == "" {
+				key = op.Value
+			}
+			for _, str := range strings.Split(txt, jsonSep) {
+				obj.add(key, html.UnescapeString(str))
+			}
+			key = ""
+		}
+	}
+
 	plain := true
 	var currColor *color.Color
 
@@ -4841,9 +5032,30 @@ func processInstructions(
 
 		str := op.Value
 
+		if obj != nil {
+			switch op.Type {
+			case LBL:
+				// labels that construct XML tags or attributes are ignored
+				if !strings.ContainsAny(str, "<>\"=") {
+					key = strings.TrimSpace(str)
+				}
+				continue
+			case TAG, HISTOGRAM, TAB, RET, PFX, SFX, SEP, PFC, CLR, DEQ, PLG, ELG, WRP, ENC, COLOR:
+				if op.Type == HISTOGRAM {
+					processClause(curr, op.Stages, mask, "", "", "", "", "", "", "", "", "", 0, false, false, op.Type, index, level, variables, transform, srchr, histogram)
+				}
+				continue
+			case DEF, REG, EXP, PROBE, MISMATCH, EDITS, RST, ACCUMULATOR, VARIABLE, VALUE:
+				// handled below
+			default:
+				addToObject(op)
+				continue
+			}
+		}
+
 		switch op.Type {
 		case ELEMENT:
-			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, def, reg, exp, wrp, op.Type, index, level, variables, transform, srchr, histogram)
+			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, def, reg, exp, prb, mis, ind, wrp, op.Type, index, level, variables, transform, srchr, histogram)
 			if ok {
 				plg = ""
 				lst = elg
@@ -4856,7 +5068,7 @@ func processInstructions(
 				}
 			}
 		case HISTOGRAM:
-			txt, ok := processClause(curr, op.Stages, mask, "", "", "", "", "", "", "", "", wrp, op.Type, index, level, variables, transform, srchr, histogram)
+			txt, ok := processClause(curr, op.Stages, mask, "", "", "", "", "", "", "", "", "", 0, false, wrp, op.Type, index, level, variables, transform, srchr, histogram)
 			if ok {
 				accum(txt)
 			}
@@ -4988,6 +5200,11 @@ func processInstructions(
 			reg = str
 		case EXP:
 			exp = str
+		case PROBE:
+			prb = str
+		case MISMATCH, EDITS:
+			mis, _ = strconv.Atoi(str)
+			ind = (op.Type == EDITS)
 		case COLOR:
 			currColor = color.New()
 			if str == "-" || str == "reset" || str == "clear" {
@@ -5033,7 +5250,7 @@ func processInstructions(
 				// -if "&VARIABLE" will fail if initialized with empty string ""
 				delete(variables, varname)
 			} else {
-				txt, ok := processClause(curr, op.Stages, mask, "", pfx, sfx, plg, sep, def, reg, exp, wrp, op.Type, index, level, variables, transform, srchr, histogram)
+				txt, ok := processClause(curr, op.Stages, mask, "", pfx, sfx, plg, sep, def, reg, exp, prb, mis, ind, wrp, op.Type, index, level, variables, transform, srchr, histogram)
 				if ok {
 					plg = ""
 					lst = elg
@@ -5051,7 +5268,7 @@ func processInstructions(
 			varname = ""
 			isAccum = false
 		default:
-			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, def, reg, exp, wrp, op.Type, index, level, variables, transform, srchr, histogram)
+			txt, ok := processClause(curr, op.Stages, mask, tab, pfx, sfx, plg, sep, def, reg, exp, prb, mis, ind, wrp, op.Type, index, level, variables, transform, srchr, histogram)
 			if ok {
 				plg = ""
 				lst = elg
@@ -5582,6 +5799,7 @@ func processCommands(
 	transform map[string]string,
 	srchr *FSMSearcher,
 	histogram map[string]int,
+	obj *jsonObject,
 	accum func(string),
 ) (string, string) {
 
@@ -5595,26 +5813,36 @@ func processCommands(
 	// closure passes local variables to callback, which can modify caller tab and ret values
 	processNode := func(node *XMLNode, idx, lvl int) {
 
+		// -output json collects results for each visited node in a nested object
+		var chld *jsonObject
+		if obj != nil {
+			chld = newJSONObject()
+		}
+
 		// apply -if or -unless tests
 		if conditionsAreSatisfied(cmds.Conditions, node, match, idx, lvl, variables) {
 
 			// execute data extraction commands
 			if len(cmds.Commands) > 0 {
-				tab, ret = processInstructions(cmds.Commands, node, match, tab, ret, idx, lvl, variables, transform, srchr, histogram, accum)
+				tab, ret = processInstructions(cmds.Commands, node, match, tab, ret, idx, lvl, variables, transform, srchr, histogram, chld, accum)
 			}
 
 			// process sub commands on child node
 			for _, sub := range cmds.Subtasks {
-				tab, ret = processCommands(sub, node, tab, ret, 1, lvl, variables, transform, srchr, histogram, accum)
+				tab, ret = processCommands(sub, node, tab, ret, 1, lvl, variables, transform, srchr, histogram, chld, accum)
 			}
 
 		} else {
 
 			// execute commands after -else statement
 			if len(cmds.Failure) > 0 {
-				tab, ret = processInstructions(cmds.Failure, node, match, tab, ret, idx, lvl, variables, transform, srchr, histogram, accum)
+				tab, ret = processInstructions(cmds.Failure, node, match, tab, ret, idx, lvl, variables, transform, srchr, histogram, chld, accum)
 			}
 		}
+
+		if chld != nil && len(chld.Fields) > 0 {
+			obj.add(node.Name, chld)
+		}
 	}
 
 	// explorePath recursive definition
@@ -5836,7 +6064,7 @@ func ProcessExtract(text, parent string, index int, hd, tl string, transform map
 	} else {
 
 		// start processing at top of command tree and top of XML subregion selected by -pattern
-		_, ret = processCommands(cmds, pat, "", "", index, 1, variables, transform, srchr, histogram,
+		_, ret = processCommands(cmds, pat, "", "", index, 1, variables, transform, srchr, histogram, nil,
 			func(str string) {
 				if str != "" {
 					ok = true
@@ -5869,97 +6097,138 @@ func ProcessExtract(text, parent string, index int, hd, tl string, transform map
 	return txt
 }
 
+// ProcessExtractJSON performs data extraction and returns each record as a one-line JSON object
+func ProcessExtractJSON(text, parent string, index int, typed bool, transform map[string]string, srchr *FSMSearcher, histogram map[string]int, cmds *Block) string {
+
+	if text == "" || cmds == nil {
+		return ""
+	}
+
+	pat := ParseRecord(text, parent)
+
+	if pat == nil {
+		return ""
+	}
+
+	variables := make(map[string]string)
+
+	// top-level object receives the record node as its only field
+	top := newJSONObject()
+
+	processCommands(cmds, pat, "", "", index, 1, variables, transform, srchr, histogram, top,
+		func(str string) {
+			// text output is not used in JSON mode
+		})
+
+	if len(top.Fields) < 1 {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	for _, val := range top.Fields[0].Values {
+		rec, ok := val.(*jsonObject)
+		if !ok {
+			continue
+		}
+		rec.write(&buffer, typed)
+		buffer.WriteString("\n")
+	}
+
+	return buffer.String()
+}
+
 // INSDSEQ EXTRACTION COMMAND GENERATOR
 
+// legal GenBank / GenPept / RefSeq features
+
+var insdFeatureKeys = []string{
+	"-10_signal",
+	"-35_signal",
+	"3'clip",
+	"3'UTR",
+	"5'clip",
+	"5'UTR",
+	"allele",
+	"assembly_gap",
+	"attenuator",
+	"Bond",
+	"C_region",
+	"CAAT_signal",
+	"CDS",
+	"centromere",
+	"conflict",
+	"D_segment",
+	"D-loop",
+	"enhancer",
+	"exon",
+	"gap",
+	"GC_signal",
+	"gene",
+	"iDNA",
+	"intron",
+	"J_segment",
+	"LTR",
+	"mat_peptide",
+	"misc_binding",
+	"misc_difference",
+	"misc_feature",
+	"misc_recomb",
+	"misc_RNA",
+	"misc_signal",
+	"misc_structure",
+	"mobile_element",
+	"modified_base",
+	"mRNA",
+	"mutation",
+	"N_region",
+	"ncRNA",
+	"old_sequence",
+	"operon",
+	"oriT",
+	"polyA_signal",
+	"polyA_site",
+	"precursor_RNA",
+	"prim_transcript",
+	"primer_bind",
+	"promoter",
+	"propeptide",
+	"protein_bind",
+	"Protein",
+	"RBS",
+	"Region",
+	"regulatory",
+	"rep_origin",
+	"repeat_region",
+	"repeat_unit",
+	"rRNA",
+	"S_region",
+	"satellite",
+	"scRNA",
+	"sig_peptide",
+	"Site",
+	"snoRNA",
+	"snRNA",
+	"source",
+	"stem_loop",
+	"STS",
+	"TATA_signal",
+	"telomere",
+	"terminator",
+	"tmRNA",
+	"transit_peptide",
+	"tRNA",
+	"unsure",
+	"V_region",
+	"V_segment",
+	"variation",
+}
+
 // e.g., xtract -insd complete mat_peptide "%peptide" product peptide
 
 // ProcessINSD generates extraction commands for GenBank/RefSeq records in INSDSet format
 func ProcessINSD(args []string, isPipe, addDash, doIndex bool) []string {
 
-	// legal GenBank / GenPept / RefSeq features
-
-	features := []string{
-		"-10_signal",
-		"-35_signal",
-		"3'clip",
-		"3'UTR",
-		"5'clip",
-		"5'UTR",
-		"allele",
-		"assembly_gap",
-		"attenuator",
-		"Bond",
-		"C_region",
-		"CAAT_signal",
-		"CDS",
-		"centromere",
-		"conflict",
-		"D_segment",
-		"D-loop",
-		"enhancer",
-		"exon",
-		"gap",
-		"GC_signal",
-		"gene",
-		"iDNA",
-		"intron",
-		"J_segment",
-		"LTR",
-		"mat_peptide",
-		"misc_binding",
-		"misc_difference",
-		"misc_feature",
-		"misc_recomb",
-		"misc_RNA",
-		"misc_signal",
-		"misc_structure",
-		"mobile_element",
-		"modified_base",
-		"mRNA",
-		"mutation",
-		"N_region",
-		"ncRNA",
-		"old_sequence",
-		"operon",
-		"oriT",
-		"polyA_signal",
-		"polyA_site",
-		"precursor_RNA",
-		"prim_transcript",
-		"primer_bind",
-		"promoter",
-		"propeptide",
-		"protein_bind",
-		"Protein",
-		"RBS",
-		"Region",
-		"regulatory",
-		"rep_origin",
-		"repeat_region",
-		"repeat_unit",
-		"rRNA",
-		"S_region",
-		"satellite",
-		"scRNA",
-		"sig_peptide",
-		"Site",
-		"snoRNA",
-		"snRNA",
-		"source",
-		"stem_loop",
-		"STS",
-		"TATA_signal",
-		"telomere",
-		"terminator",
-		"tmRNA",
-		"transit_peptide",
-		"tRNA",
-		"unsure",
-		"V_region",
-		"V_segment",
-		"variation",
-	}
-
 	// legal GenBank / GenPept / RefSeq qualifiers
 
 	qualifiers := []string{
@@ -6364,7 +6633,7 @@ func ProcessINSD(args []string, isPipe, addDash, doIndex bool) []string {
 			comma := strings.Split(pls, ",")
 			for _, cma := range comma {
 
-				checkAgainstVocabulary(cma, "feature", features)
+				checkAgainstVocabulary(cma, "feature", insdFeatureKeys)
 				acc = append(acc, fcmd, "INSDFeature_key", "-equals", cma)
 
 				fcmd = "-or"